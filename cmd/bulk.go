@@ -0,0 +1,96 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/kubefirst/git-helper/internal/sync"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var bulkOpts *sync.BulkOptions = &sync.BulkOptions{}
+
+// syncWebhookBulkCmd represents the sync webhook bulk command
+var syncWebhookBulkCmd = &cobra.Command{
+	Use:       "bulk <create|update|delete|sync>",
+	Short:     "Create, update, delete or sync a webhook across many repositories/projects",
+	ValidArgs: sync.AllowedBulkOperations,
+	Args:      cobra.ExactValidArgs(1),
+	Long: `Apply a webhook operation to every selected repository/project
+
+Repositories are selected with --repository, --repositories-file, --all-repositories,
+--topic, --team (GitHub) or --subgroup (GitLab), and optionally filtered with
+--match-repository. Up to --concurrency repositories are processed at once. A failure on
+one repository does not stop the others, a result per repository and a summary
+are printed at the end.
+
+  create  create a hook for --url where none exists
+  update  update the hooks delivering to --url
+  delete  delete the hooks delivering to --url
+  sync    update or create the hook for --url and delete hooks for --old-url`,
+	Run: func(cmd *cobra.Command, args []string) {
+		bulkOpts.Operation = args[0]
+		results, err := sync.RunBulk(*syncWebhookOpts, *bulkOpts)
+		if err != nil {
+			log.Fatalf("error running command: %s", err)
+		}
+
+		failed := 0
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "REPOSITORY\tRESULT\tHOOK IDS\tERROR")
+		for _, result := range results {
+			message := "-"
+			if result.Err != nil {
+				message = result.Err.Error()
+				failed++
+			}
+			fmt.Fprintf(w, "%s\t%s\t%v\t%s\n", result.Repository, result.Action, result.HookIDs, message)
+		}
+		w.Flush()
+		fmt.Println(sync.BulkSummary(results))
+
+		if failed > 0 {
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	syncWebhookCmd.AddCommand(syncWebhookBulkCmd)
+
+	syncWebhookBulkCmd.Flags().StringVar(&syncWebhookOpts.Owner, "owner", syncWebhookOpts.Owner, "Owner - organization or primary group (required)")
+	err := syncWebhookBulkCmd.MarkFlagRequired("owner")
+	if err != nil {
+		log.Fatal(err)
+	}
+	syncWebhookBulkCmd.Flags().StringVar(&syncWebhookOpts.Provider, "provider", syncWebhookOpts.Provider, fmt.Sprintf("Provider - one of %s (required)", allowedGitProviders))
+	err = syncWebhookBulkCmd.MarkFlagRequired("provider")
+	if err != nil {
+		log.Fatal(err)
+	}
+	syncWebhookBulkCmd.Flags().StringVar(&syncWebhookOpts.Url, "url", syncWebhookOpts.Url, "URL endpoint to provide to webhook (required)")
+	err = syncWebhookBulkCmd.MarkFlagRequired("url")
+	if err != nil {
+		log.Fatal(err)
+	}
+	syncWebhookBulkCmd.Flags().StringVar(&syncWebhookOpts.OldUrl, "old-url", syncWebhookOpts.OldUrl, "URL of hooks to remove when using sync")
+	syncWebhookBulkCmd.Flags().StringVar(&syncWebhookOpts.Token, "token", syncWebhookOpts.Token, "Secret token to provide to webhook")
+	syncWebhookBulkCmd.Flags().BoolVar(&syncWebhookOpts.Strict, "strict", false, "Fail a repository instead of acting on every hook when more than one hook matches the url")
+	syncWebhookBulkCmd.Flags().IntVar(&bulkOpts.Concurrency, "concurrency", 8, "Number of repositories to process at once")
+
+	addRepositorySelectorFlags(syncWebhookBulkCmd, &bulkOpts.RepositorySelector)
+}
+
+// addRepositorySelectorFlags binds the repository selection flags of a
+// command operating on many repositories
+func addRepositorySelectorFlags(command *cobra.Command, selector *sync.RepositorySelector) {
+	command.Flags().StringSliceVar(&selector.Repositories, "repository", selector.Repositories, "Repository or project, may be repeated")
+	command.Flags().StringVar(&selector.File, "repositories-file", selector.File, "File listing one repository or project per line")
+	command.Flags().BoolVar(&selector.AllRepositories, "all-repositories", false, "Select every repository of the owner")
+	command.Flags().StringVar(&selector.Topic, "topic", selector.Topic, "Select repositories tagged with this topic")
+	command.Flags().StringVar(&selector.Team, "team", selector.Team, "Select the repositories of this GitHub team (slug)")
+	command.Flags().StringVar(&selector.Subgroup, "subgroup", selector.Subgroup, "Select the projects of this GitLab subgroup of the owner")
+	command.Flags().StringVar(&selector.Match, "match-repository", selector.Match, "Only select repositories whose name matches this regular expression")
+}
@@ -0,0 +1,88 @@
+package cmd
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/kubefirst/git-helper/internal/controller"
+	"github.com/kubefirst/git-helper/internal/kubernetes"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var (
+	controllerOpts     *controller.Options       = &controller.Options{}
+	controllerKubeOpts *kubernetes.ClientOptions = &kubernetes.ClientOptions{}
+	controllerLease    *kubernetes.LeaseOptions  = &kubernetes.LeaseOptions{}
+	controllerElect    bool
+)
+
+// controllerCmd represents the controller command
+var controllerCmd = &cobra.Command{
+	Use:   "controller",
+	Short: "Run the WebhookBinding controller",
+	Long: `Run the WebhookBinding controller
+
+Watches WebhookBinding resources and keeps the repository/project webhook
+they describe pointed at their url source. Deleting a WebhookBinding
+removes its webhook.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		// An explicit kubeconfig or context implies a local configuration
+		kubeOpts := *controllerKubeOpts
+		kubeOpts.InCluster = kubeOpts.InCluster && kubeOpts.Kubeconfig == "" && kubeOpts.Context == ""
+		kube, err := kubernetes.NewClient(kubeOpts)
+		if err != nil {
+			log.Fatalf("error running command: %s", err)
+		}
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		c := controller.NewController(kube, *controllerOpts)
+		if controllerElect {
+			err = kube.RunWithLeaderElection(ctx, *controllerLease, c.Run)
+		} else {
+			err = c.Run(ctx)
+		}
+		if err != nil {
+			log.Fatalf("error running command: %s", err)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(controllerCmd)
+
+	controllerCmd.Flags().StringVar(&controllerOpts.Namespace, "namespace", controllerOpts.Namespace, "Only watch WebhookBindings in this Namespace (default all)")
+	controllerCmd.Flags().IntVar(&controllerOpts.Workers, "workers", 2, "Number of WebhookBindings to reconcile in parallel")
+	controllerCmd.Flags().DurationVar(&controllerOpts.ResyncPeriod, "resync-period", time.Minute, "How often to reconcile every WebhookBinding to pick up url changes")
+
+	controllerCmd.Flags().BoolVar(&controllerOpts.Lock, "lock", false, "Hold the sync Lease while replacing a hook, serializing with sync runs that use --lock")
+	controllerCmd.Flags().StringVar(&controllerOpts.LockName, "lock-name", "git-helper-sync", "Name of the Lease used by --lock, shared with the sync commands")
+	controllerCmd.Flags().StringVar(&controllerOpts.LockNamespace, "lock-namespace", "atlantis", "Namespace of the Lease used by --lock")
+	controllerCmd.Flags().DurationVar(&controllerOpts.LockTimeout, "lock-timeout", 5*time.Minute, "How long to wait for the Lease when using --lock")
+
+	controllerCmd.Flags().BoolVar(&controllerElect, "leader-elect", false, "Only reconcile while holding the leader Lease, for running several replicas")
+	controllerCmd.Flags().StringVar(&controllerLease.Name, "leader-election-id", "git-helper-controller", "Name of the Lease used by --leader-elect")
+	controllerCmd.Flags().StringVar(&controllerLease.Namespace, "leader-election-namespace", defaultNamespace(), "Namespace of the Lease used by --leader-elect")
+
+	controllerCmd.Flags().BoolVar(&controllerKubeOpts.InCluster, "use-kubeconfig-in-cluster", true, "kube config type - in-cluster (default), set to false to use local")
+	controllerCmd.Flags().StringVar(&controllerKubeOpts.Kubeconfig, "kubeconfig", controllerKubeOpts.Kubeconfig, "Path to a local kubeconfig (defaults to $KUBECONFIG or ~/.kube/config)")
+	controllerCmd.Flags().StringVar(&controllerKubeOpts.Context, "context", controllerKubeOpts.Context, "kubeconfig context to use instead of the current context")
+}
+
+// defaultNamespace returns the Namespace the process runs in when in-cluster,
+// falling back to default
+func defaultNamespace() string {
+	if namespace := os.Getenv("POD_NAMESPACE"); namespace != "" {
+		return namespace
+	}
+	data, err := os.ReadFile("/var/run/secrets/kubernetes.io/serviceaccount/namespace")
+	if err == nil && len(data) > 0 {
+		return string(data)
+	}
+	return "default"
+}
@@ -0,0 +1,120 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/kubefirst/git-helper/internal/sync"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var (
+	deliveriesOpts *sync.DeliveriesOptions = &sync.DeliveriesOptions{}
+	redeliverOpts  *sync.RedeliverOptions  = &sync.RedeliverOptions{}
+
+	redeliverYes bool
+)
+
+// syncWebhookDeliveriesCmd represents the sync webhook deliveries command
+var syncWebhookDeliveriesCmd = &cobra.Command{
+	Use:   "deliveries",
+	Short: "List recent deliveries of a webhook",
+	Long: `List the most recent deliveries of a webhook with their event, status
+code, timestamp and duration, using the GitHub hook deliveries api or the
+GitLab hook events api (GitLab 17.8 or later).
+
+The hook is selected with --hook-id or --url, or is the only hook of the
+repository/project.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		hookID, deliveries, err := sync.ListWebhookDeliveries(*syncWebhookOpts, *deliveriesOpts)
+		if err != nil {
+			log.Fatalf("error running command: %s", err)
+		}
+		if len(deliveries) == 0 {
+			fmt.Printf("no deliveries found for hook %d\n", hookID)
+			return
+		}
+		printDeliveries(deliveries)
+	},
+}
+
+// syncWebhookRedeliverCmd represents the sync webhook deliveries redeliver command
+var syncWebhookRedeliverCmd = &cobra.Command{
+	Use:   "redeliver",
+	Short: "Send failed webhook deliveries again",
+	Long: `Send the failed deliveries of a webhook again, e.g. after the tunnel url
+has been fixed.
+
+Only the most recent attempt of each event is considered, and events that
+were later delivered successfully are skipped. Deliveries are listed and sent
+after confirmation, or right away when --yes is given.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		hookID, deliveries, err := sync.FindRedeliveries(*syncWebhookOpts, *redeliverOpts)
+		if err != nil {
+			log.Fatalf("error running command: %s", err)
+		}
+		if len(deliveries) == 0 {
+			fmt.Printf("no failed deliveries found for hook %d\n", hookID)
+			return
+		}
+		printDeliveries(deliveries)
+
+		if !redeliverYes && !confirm(fmt.Sprintf("Redeliver %d deliveries?", len(deliveries))) {
+			fmt.Println("aborted, nothing redelivered")
+			return
+		}
+
+		err = sync.RedeliverWebhookDeliveries(*syncWebhookOpts, hookID, deliveries)
+		if err != nil {
+			log.Fatalf("error running command: %s", err)
+		}
+	},
+}
+
+// printDeliveries writes deliveries as a table to stdout
+func printDeliveries(deliveries []sync.Delivery) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tEVENT\tSTATUS CODE\tSTATUS\tDELIVERED AT\tDURATION\tREDELIVERY")
+	for _, delivery := range deliveries {
+		fmt.Fprintf(w, "%d\t%s\t%d\t%s\t%s\t%s\t%t\n", delivery.ID, delivery.Event, delivery.StatusCode, delivery.Status, delivery.DeliveredAt.Format(time.RFC3339), delivery.Duration.Round(time.Millisecond), delivery.Redelivery)
+	}
+	w.Flush()
+}
+
+func init() {
+	syncWebhookCmd.AddCommand(syncWebhookDeliveriesCmd)
+	syncWebhookDeliveriesCmd.AddCommand(syncWebhookRedeliverCmd)
+
+	for _, command := range []*cobra.Command{syncWebhookDeliveriesCmd, syncWebhookRedeliverCmd} {
+		command.Flags().StringVar(&syncWebhookOpts.Owner, "owner", syncWebhookOpts.Owner, "Owner - organization or primary group (required)")
+		err := command.MarkFlagRequired("owner")
+		if err != nil {
+			log.Fatal(err)
+		}
+		command.Flags().StringVar(&syncWebhookOpts.Provider, "provider", syncWebhookOpts.Provider, fmt.Sprintf("Provider - one of %s (required)", allowedGitProviders))
+		err = command.MarkFlagRequired("provider")
+		if err != nil {
+			log.Fatal(err)
+		}
+		command.Flags().StringVar(&syncWebhookOpts.Repository, "repository", syncWebhookOpts.Repository, "Repository or project (required)")
+		err = command.MarkFlagRequired("repository")
+		if err != nil {
+			log.Fatal(err)
+		}
+		command.Flags().BoolVar(&syncWebhookOpts.GitLabRecursive, "gitlab-recursive", false, "Include subgroups of the owner group when looking up GitLab projects by name")
+	}
+
+	syncWebhookDeliveriesCmd.Flags().Int64Var(&deliveriesOpts.HookID, "hook-id", 0, "ID of the hook to inspect")
+	syncWebhookDeliveriesCmd.Flags().StringVar(&deliveriesOpts.URL, "url", deliveriesOpts.URL, "Inspect the hook delivering to this url")
+	syncWebhookDeliveriesCmd.Flags().IntVar(&deliveriesOpts.Limit, "limit", 30, "Number of most recent deliveries to list")
+	syncWebhookDeliveriesCmd.Flags().BoolVar(&deliveriesOpts.FailedOnly, "failed", false, "Only list failed deliveries")
+
+	syncWebhookRedeliverCmd.Flags().Int64Var(&redeliverOpts.HookID, "hook-id", 0, "ID of the hook to redeliver")
+	syncWebhookRedeliverCmd.Flags().StringVar(&redeliverOpts.URL, "url", redeliverOpts.URL, "Redeliver for the hook delivering to this url")
+	syncWebhookRedeliverCmd.Flags().IntVar(&redeliverOpts.Limit, "limit", 100, "Number of most recent deliveries to consider")
+	syncWebhookRedeliverCmd.Flags().DurationVar(&redeliverOpts.Since, "since", 24*time.Hour, "Only redeliver deliveries made within this duration, 0 for all")
+	syncWebhookRedeliverCmd.Flags().BoolVarP(&redeliverYes, "yes", "y", false, "Redeliver without asking for confirmation")
+}
@@ -0,0 +1,83 @@
+package cmd
+
+import (
+	"fmt"
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/kubefirst/git-helper/internal/fakeforge"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var (
+	fakeForgeAddr           string
+	fakeForgeGitHubRepos    []string
+	fakeForgeGitLabProjects []string
+	fakeForgeTunnelURL      string
+	fakeForgeTunnelAddr     string
+)
+
+// devCmd represents the dev command
+var devCmd = &cobra.Command{
+	Use:   "dev",
+	Short: "Tools for local development",
+}
+
+// devFakeForgeCmd represents the dev fake-forge command
+var devFakeForgeCmd = &cobra.Command{
+	Use:   "fake-forge",
+	Short: "Serve an in-memory fake of the GitHub, GitLab and ngrok apis",
+	Long: `Serve an in-memory subset of the GitHub and GitLab REST apis covering
+repositories, groups, projects, hooks and deliveries, plus a fake ngrok agent
+tunnels endpoint.
+
+Point git-helper at it with GITHUB_API_URL, GITLAB_API_URL and
+--ngrok-api-url to run the webhook commands end to end without network access.
+Nothing is persisted, all state is lost when the server stops.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		forge := fakeforge.New()
+		for _, repo := range fakeForgeGitHubRepos {
+			owner, name, ok := strings.Cut(repo, "/")
+			if !ok || owner == "" || name == "" {
+				log.Fatalf("invalid --github-repo %s, must be owner/repository", repo)
+			}
+			forge.AddGitHubRepository(owner, name)
+		}
+		for _, project := range fakeForgeGitLabProjects {
+			namespace, name := path.Split(project)
+			if namespace == "" || name == "" {
+				log.Fatalf("invalid --gitlab-project %s, must be group/project", project)
+			}
+			forge.AddGitLabProject(strings.TrimSuffix(namespace, "/"), name)
+		}
+		forge.SetTunnels(fakeforge.Tunnel{Name: "atlantis", PublicURL: fakeForgeTunnelURL, Proto: "https", Addr: fakeForgeTunnelAddr})
+
+		baseURL := "http://" + fakeForgeAddr
+		if strings.HasPrefix(fakeForgeAddr, ":") {
+			baseURL = "http://localhost" + fakeForgeAddr
+		}
+		fmt.Printf("export GIT_TOKEN=fake\n")
+		fmt.Printf("export GITHUB_API_URL=%s%s\n", baseURL, fakeforge.GitHubPrefix)
+		fmt.Printf("export GITLAB_API_URL=%s%s\n", baseURL, fakeforge.GitLabPrefix)
+		fmt.Printf("# pass --ngrok-api-url %s%s to the sync commands\n", baseURL, fakeforge.NgrokPath)
+
+		log.Infof("serving fake forge on %s", fakeForgeAddr)
+		err := http.ListenAndServe(fakeForgeAddr, forge.Handler())
+		if err != nil {
+			log.Fatalf("error running command: %s", err)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(devCmd)
+	devCmd.AddCommand(devFakeForgeCmd)
+
+	devFakeForgeCmd.Flags().StringVar(&fakeForgeAddr, "addr", "localhost:8900", "Address to listen on")
+	devFakeForgeCmd.Flags().StringArrayVar(&fakeForgeGitHubRepos, "github-repo", []string{}, "GitHub repository to create as owner/repository, repeatable")
+	devFakeForgeCmd.Flags().StringArrayVar(&fakeForgeGitLabProjects, "gitlab-project", []string{}, "GitLab project to create as group[/subgroup]/project, repeatable")
+	devFakeForgeCmd.Flags().StringVar(&fakeForgeTunnelURL, "tunnel-url", "https://fake.ngrok.io", "Public url reported by the fake ngrok tunnel")
+	devFakeForgeCmd.Flags().StringVar(&fakeForgeTunnelAddr, "tunnel-addr", "http://atlantis:4141", "Local address reported by the fake ngrok tunnel")
+}
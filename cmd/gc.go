@@ -0,0 +1,150 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/kubefirst/git-helper/internal/state"
+	"github.com/kubefirst/git-helper/internal/sync"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var (
+	gcOpts     *sync.GCOptions     = &sync.GCOptions{}
+	dedupeOpts *sync.DedupeOptions = &sync.DedupeOptions{}
+
+	gcYes bool
+)
+
+// syncWebhookGCCmd represents the sync webhook gc command
+var syncWebhookGCCmd = &cobra.Command{
+	Use:   "gc",
+	Short: "Delete orphaned webhooks left behind by previous tunnels",
+	Long: `Scan repositories/projects for webhooks whose url matches a host or url
+pattern, ngrok domains by default, but is not the currently active url.
+
+Matching webhooks are listed and deleted after confirmation, or right away
+when --yes is given. The active url of each repository is read from the
+state store unless --from-state=false, and --keep-url adds further urls to
+keep.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		orphaned, err := sync.FindOrphanedWebhooks(*syncWebhookOpts, *gcOpts)
+		if err != nil {
+			log.Fatalf("error running command: %s", err)
+		}
+		if len(orphaned) == 0 {
+			fmt.Println("no orphaned webhooks found")
+			return
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "REPOSITORY\tHOOK ID\tURL")
+		for _, hook := range orphaned {
+			fmt.Fprintf(w, "%s\t%d\t%s\n", hook.Repository, hook.HookID, hook.URL)
+		}
+		w.Flush()
+
+		if !gcYes && !confirm(fmt.Sprintf("Delete %d webhooks?", len(orphaned))) {
+			fmt.Println("aborted, no webhooks deleted")
+			return
+		}
+
+		err = sync.DeleteWebhooks(*syncWebhookOpts, orphaned)
+		if err != nil {
+			log.Fatalf("error running command: %s", err)
+		}
+	},
+}
+
+// syncWebhookDedupeCmd represents the sync webhook dedupe command
+var syncWebhookDedupeCmd = &cobra.Command{
+	Use:   "dedupe",
+	Short: "Collapse duplicate webhooks to a single canonical one",
+	Long: `Find webhooks on a repository/project that deliver to the same url and
+delete all but one of them.
+
+The hook recorded in the state store is kept when it is one of the
+duplicates, otherwise the most recently created hook is kept. Duplicates are
+listed and deleted after confirmation, or right away when --yes is given.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		duplicates, err := sync.FindDuplicateWebhooks(*syncWebhookOpts, *dedupeOpts)
+		if err != nil {
+			log.Fatalf("error running command: %s", err)
+		}
+		if len(duplicates) == 0 {
+			fmt.Println("no duplicate webhooks found")
+			return
+		}
+
+		hooks := make([]sync.RepositoryWebhook, 0, len(duplicates))
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "REPOSITORY\tHOOK ID\tKEPT HOOK ID\tURL")
+		for _, hook := range duplicates {
+			fmt.Fprintf(w, "%s\t%d\t%d\t%s\n", hook.Repository, hook.HookID, hook.KeptHookID, hook.URL)
+			hooks = append(hooks, hook.RepositoryWebhook)
+		}
+		w.Flush()
+
+		if !gcYes && !confirm(fmt.Sprintf("Delete %d duplicate webhooks?", len(hooks))) {
+			fmt.Println("aborted, no webhooks deleted")
+			return
+		}
+
+		err = sync.DeleteWebhooks(*syncWebhookOpts, hooks)
+		if err != nil {
+			log.Fatalf("error running command: %s", err)
+		}
+	},
+}
+
+func init() {
+	syncWebhookCmd.AddCommand(syncWebhookGCCmd)
+	syncWebhookCmd.AddCommand(syncWebhookDedupeCmd)
+
+	for _, command := range []*cobra.Command{syncWebhookGCCmd, syncWebhookDedupeCmd} {
+		command.Flags().StringVar(&syncWebhookOpts.Owner, "owner", syncWebhookOpts.Owner, "Owner - organization or primary group (required)")
+		err := command.MarkFlagRequired("owner")
+		if err != nil {
+			log.Fatal(err)
+		}
+		command.Flags().StringVar(&syncWebhookOpts.Provider, "provider", syncWebhookOpts.Provider, fmt.Sprintf("Provider - one of %s (required)", allowedGitProviders))
+		err = command.MarkFlagRequired("provider")
+		if err != nil {
+			log.Fatal(err)
+		}
+		command.Flags().BoolVarP(&gcYes, "yes", "y", false, "Delete without asking for confirmation")
+
+		command.Flags().BoolVar(&syncWebhookOpts.KubeInClusterConfig, "use-kubeconfig-in-cluster", true, "kube config type - in-cluster (default), set to false to use local")
+		command.Flags().StringVar(&syncWebhookOpts.Kubeconfig, "kubeconfig", syncWebhookOpts.Kubeconfig, "Path to a local kubeconfig (defaults to $KUBECONFIG or ~/.kube/config)")
+		command.Flags().StringVar(&syncWebhookOpts.KubeContext, "context", syncWebhookOpts.KubeContext, "kubeconfig context to use instead of the current context")
+		command.Flags().StringVar(&syncWebhookOpts.StateBackend, "state-backend", state.BackendConfigMap, fmt.Sprintf("Where the registered webhook state is kept - one of %s", state.AllowedBackends))
+		command.Flags().StringVar(&syncWebhookOpts.StateNamespace, "state-namespace", "atlantis", "Namespace of the state ConfigMap or Secret")
+		command.Flags().StringVar(&syncWebhookOpts.StateName, "state-name", "ngrok", "Name of the state ConfigMap or Secret")
+		command.Flags().StringVar(&syncWebhookOpts.StatePath, "state-path", syncWebhookOpts.StatePath, "Path of the state file when using the file backend")
+	}
+
+	addRepositorySelectorFlags(syncWebhookGCCmd, &gcOpts.RepositorySelector)
+	syncWebhookGCCmd.Flags().StringVar(&gcOpts.Host, "host", gcOpts.Host, "Only consider webhooks whose url host matches this glob, e.g. *.ngrok.io")
+	syncWebhookGCCmd.Flags().StringVar(&gcOpts.Match, "match", gcOpts.Match, "Only consider webhooks whose url matches this regular expression (ngrok domains if neither --host nor --match is given)")
+	syncWebhookGCCmd.Flags().StringSliceVar(&gcOpts.KeepURLs, "keep-url", gcOpts.KeepURLs, "Webhook url to keep, may be repeated")
+	syncWebhookGCCmd.Flags().BoolVar(&gcOpts.FromState, "from-state", true, "Keep the active url recorded in the state store for each repository")
+
+	addRepositorySelectorFlags(syncWebhookDedupeCmd, &dedupeOpts.RepositorySelector)
+	syncWebhookDedupeCmd.Flags().StringVar(&dedupeOpts.URL, "url", dedupeOpts.URL, "Only collapse webhooks delivering to this url")
+	syncWebhookDedupeCmd.Flags().BoolVar(&dedupeOpts.FromState, "from-state", true, "Keep the hook recorded in the state store when it is one of the duplicates")
+}
+
+// confirm asks a yes/no question on stdin, defaulting to no
+func confirm(question string) bool {
+	fmt.Printf("%s [y/N]: ", question)
+	answer, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil {
+		return false
+	}
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
@@ -0,0 +1,66 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/kubefirst/git-helper/internal/sync"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var rollbackTo int
+
+// syncWebhookHistoryCmd represents the sync webhook history command
+var syncWebhookHistoryCmd = &cobra.Command{
+	Use:   "history",
+	Short: "Show the webhooks previously registered for a repository/project",
+	Long:  `Show the current and previously registered webhook urls and hook IDs for a repository/project`,
+	Run: func(cmd *cobra.Command, args []string) {
+		existing, err := sync.WebhookHistory(*syncWebhookOpts)
+		if err != nil {
+			log.Fatalf("error running command: %s", err)
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "#\tHOOK ID\tURL\tREGISTERED\tREPLACED")
+		fmt.Fprintf(w, "current\t%d\t%s\t%s\t-\n", existing.HookID, existing.URL, formatTime(existing.UpdatedAt))
+		for i, entry := range existing.History {
+			fmt.Fprintf(w, "%d\t%d\t%s\t%s\t%s\n", i+1, entry.HookID, entry.URL, formatTime(entry.RegisteredAt), formatTime(entry.ReplacedAt))
+		}
+		w.Flush()
+	},
+}
+
+// syncWebhookRollbackCmd represents the sync webhook rollback command
+var syncWebhookRollbackCmd = &cobra.Command{
+	Use:   "rollback",
+	Short: "Re-point a repository/project webhook to a previously registered url",
+	Long: `Re-point a repository/project webhook to a previously registered url
+
+The target is selected with --to, the history entry number shown by the
+history command, or with --url.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		err := sync.RollbackAtlantisWebhook(*syncWebhookOpts, rollbackTo)
+		if err != nil {
+			log.Fatalf("error running command: %s", err)
+		}
+	},
+}
+
+func init() {
+	syncWebhookCmd.AddCommand(syncWebhookHistoryCmd)
+	syncWebhookCmd.AddCommand(syncWebhookRollbackCmd)
+
+	syncWebhookRollbackCmd.Flags().IntVar(&rollbackTo, "to", 1, "History entry to roll back to, 1 being the most recent")
+}
+
+// formatTime renders a timestamp for tabular output
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	return t.Local().Format(time.RFC3339)
+}
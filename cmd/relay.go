@@ -0,0 +1,210 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/kubefirst/git-helper/internal/relay"
+	"github.com/kubefirst/git-helper/internal/state"
+	"github.com/kubefirst/git-helper/internal/sync"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var (
+	relayOpts       *relay.Options       = &relay.Options{}
+	relaySecretOpts *sync.WebhookOptions = &sync.WebhookOptions{}
+	relaySyncOpts   *sync.WebhookOptions = &sync.WebhookOptions{}
+
+	relayAddr         string
+	relayPath         string
+	relayChannel      string
+	relayPublicURL    string
+	relayTargetSecret string
+	relaySyncWebhook  bool
+)
+
+// relayCmd represents the relay command
+var relayCmd = &cobra.Command{
+	Use:   "relay",
+	Short: "Forward verified webhook deliveries to an in-cluster service",
+	Long: `Relay webhook deliveries to a service that is not reachable from the
+internet, as an alternative to an ngrok tunnel.
+
+Deliveries are received on a public-facing listener, or read from a
+smee-style channel with --channel for clusters without inbound connectivity.
+Each delivery is verified against the webhook secret, signed again with the
+secret of the target and forwarded to --target, e.g.
+http://atlantis.atlantis.svc/events. The incoming secrets come from
+--github-secret and --gitlab-secret, or from --token or a Kubernetes Secret
+with --use-secret, the same as serve-hooks. A target secret without an
+incoming secret is refused unless --insecure-skip-verify is set.
+
+Deliveries are buffered while the target is down and retried with backoff,
+in the order they were received. The buffer is kept in memory only, so
+deliveries still pending when the relay stops or restarts are lost and
+logged, and have to be redelivered from the provider.
+
+With --sync-webhook the repository webhook is pointed at the relay (the
+--public-url listener or the channel) through the Atlantis webhook sync
+before relaying starts.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		relaySecretOpts.KubeInClusterConfig = relaySyncOpts.KubeInClusterConfig
+		relaySecretOpts.Kubeconfig = relaySyncOpts.Kubeconfig
+		relaySecretOpts.KubeContext = relaySyncOpts.KubeContext
+		secrets, err := sync.WebhookSecrets(*relaySecretOpts)
+		if err != nil {
+			log.Fatalf("error running command: %s", err)
+		}
+		if relayOpts.GitHubSecret == "" {
+			relayOpts.GitHubSecret = secrets["github"]
+		}
+		if relayOpts.GitLabSecret == "" {
+			relayOpts.GitLabSecret = secrets["gitlab"]
+		}
+		if relayTargetSecret != "" {
+			if relayOpts.TargetGitHubSecret == "" {
+				relayOpts.TargetGitHubSecret = relayTargetSecret
+			}
+			if relayOpts.TargetGitLabSecret == "" {
+				relayOpts.TargetGitLabSecret = relayTargetSecret
+			}
+		}
+		if relayOpts.GitHubSecret == "" {
+			log.Warn("no GitHub secret configured, GitHub deliveries are relayed unverified")
+		}
+		if relayOpts.GitLabSecret == "" {
+			log.Warn("no GitLab secret configured, GitLab deliveries are relayed unverified")
+		}
+
+		r, err := relay.New(*relayOpts)
+		if err != nil {
+			log.Fatalf("error running command: %s", err)
+		}
+
+		if relaySyncWebhook {
+			relaySyncOpts.URLSource = sync.URLSourceURL
+			relaySyncOpts.Url = relayChannel
+			if relaySyncOpts.Url == "" {
+				if relayPublicURL == "" {
+					log.Fatalf("error running command: --sync-webhook requires --public-url or --channel")
+				}
+				relaySyncOpts.Url = strings.TrimSuffix(relayPublicURL, "/") + relayPath
+			}
+			err = sync.SynchronizeAtlantisWebhook(*relaySyncOpts)
+			if err != nil {
+				log.Fatalf("error running command: %s", err)
+			}
+		}
+
+		mux := http.NewServeMux()
+		mux.Handle(relayPath, r.Handler())
+		mux.HandleFunc("/healthz", func(w http.ResponseWriter, req *http.Request) {
+			fmt.Fprintf(w, "ok, %d deliveries pending\n", r.Pending())
+		})
+		server := &http.Server{Addr: relayAddr, Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		go func() {
+			<-ctx.Done()
+			shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			server.Shutdown(shutdownCtx)
+		}()
+
+		// Forwarding and the channel stop the relay when they fail, and are
+		// waited for so pending deliveries are logged before exiting
+		errs := make(chan error, 2)
+		workers := 1
+		go func() {
+			err := r.Run(ctx)
+			if err != nil {
+				stop()
+			}
+			errs <- err
+		}()
+		if relayChannel != "" {
+			workers++
+			go func() {
+				err := r.Subscribe(ctx, relayChannel)
+				if err != nil {
+					stop()
+				}
+				errs <- err
+			}()
+		}
+
+		log.Infof("relaying webhook deliveries on %s%s to %s", relayAddr, relayPath, relayOpts.Target)
+		err = server.ListenAndServe()
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatalf("error running command: %s", err)
+		}
+		for i := 0; i < workers; i++ {
+			err = <-errs
+			if err != nil {
+				log.Fatalf("error running command: %s", err)
+			}
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(relayCmd)
+
+	relayCmd.Flags().StringVar(&relayOpts.Target, "target", relayOpts.Target, "Url deliveries are forwarded to, e.g. http://atlantis.atlantis.svc/events (required)")
+	err := relayCmd.MarkFlagRequired("target")
+	if err != nil {
+		log.Fatal(err)
+	}
+	relayCmd.Flags().StringVar(&relayAddr, "addr", ":8080", "Address to listen on")
+	relayCmd.Flags().StringVar(&relayPath, "path", "/events", "Path deliveries are accepted on")
+	relayCmd.Flags().StringVar(&relayChannel, "channel", relayChannel, "Url of a smee-style channel to read deliveries from instead of receiving them directly")
+	relayCmd.Flags().StringVar(&relayPublicURL, "public-url", relayPublicURL, "Public url of the listener, used by --sync-webhook")
+
+	// Buffering and retries
+	relayCmd.Flags().IntVar(&relayOpts.QueueSize, "queue-size", 1000, "Number of deliveries buffered in memory while the target is down, lost on restart")
+	relayCmd.Flags().DurationVar(&relayOpts.RetryInterval, "retry-interval", time.Second, "Delay before retrying a failed forward, doubled after each attempt")
+	relayCmd.Flags().DurationVar(&relayOpts.MaxRetryInterval, "max-retry-interval", time.Minute, "Longest delay between forwarding attempts")
+	relayCmd.Flags().DurationVar(&relayOpts.MaxAge, "max-age", 24*time.Hour, "Drop deliveries that could not be forwarded within this duration, 0 to retry forever")
+	relayCmd.Flags().DurationVar(&relayOpts.Timeout, "target-timeout", 10*time.Second, "Timeout of each forwarding attempt")
+
+	// Secrets
+	relayCmd.Flags().StringVar(&relayOpts.GitHubSecret, "github-secret", relayOpts.GitHubSecret, "Secret incoming GitHub deliveries are signed with")
+	relayCmd.Flags().StringVar(&relayOpts.GitLabSecret, "gitlab-secret", relayOpts.GitLabSecret, "Secret token incoming GitLab deliveries carry")
+	relayCmd.Flags().StringVar(&relaySecretOpts.Token, "token", relaySecretOpts.Token, "Secret of incoming deliveries for both providers unless set per provider")
+	relayCmd.Flags().StringVar(&relayOpts.TargetGitHubSecret, "target-github-secret", relayOpts.TargetGitHubSecret, "Secret to sign forwarded GitHub deliveries with (defaults to the incoming secret)")
+	relayCmd.Flags().StringVar(&relayOpts.TargetGitLabSecret, "target-gitlab-secret", relayOpts.TargetGitLabSecret, "Secret token to send with forwarded GitLab deliveries (defaults to the incoming secret)")
+	relayCmd.Flags().StringVar(&relayTargetSecret, "target-secret", relayTargetSecret, "Secret of forwarded deliveries for both providers unless set per provider")
+	relayCmd.Flags().BoolVar(&relayOpts.InsecureSkipVerify, "insecure-skip-verify", false, "Allow a target secret without an incoming secret, signing unverified deliveries for the target")
+	relayCmd.Flags().BoolVar(&relaySecretOpts.UseSecret, "use-secret", false, "Read the incoming secrets from a Kubernetes Secret")
+	relayCmd.Flags().StringVar(&relaySecretOpts.SecretName, "secret-name", "atlantis-secrets", "Name of the Secret holding the webhook secrets")
+	relayCmd.Flags().StringVar(&relaySecretOpts.SecretNamespace, "namespace", "atlantis", "Namespace of the Secret holding the webhook secrets")
+	relayCmd.Flags().StringVar(&relaySecretOpts.SecretValues, "secret-values", relaySecretOpts.SecretValues, "Key of the webhook secrets in the Secret (defaults to the Atlantis key of each provider)")
+
+	// Webhook synchronization
+	relayCmd.Flags().BoolVar(&relaySyncWebhook, "sync-webhook", false, "Point the repository webhook at the relay before relaying")
+	relayCmd.Flags().StringVar(&relaySyncOpts.Owner, "owner", relaySyncOpts.Owner, "Owner - organization or primary group, used by --sync-webhook")
+	relayCmd.Flags().StringVar(&relaySyncOpts.Provider, "provider", relaySyncOpts.Provider, fmt.Sprintf("Provider - one of %s, used by --sync-webhook", allowedGitProviders))
+	relayCmd.Flags().StringVar(&relaySyncOpts.Repository, "repository", relaySyncOpts.Repository, "Repository or project, used by --sync-webhook")
+	relayCmd.Flags().BoolVar(&relaySyncOpts.GitLabRecursive, "gitlab-recursive", false, "Include subgroups of the owner group when looking up GitLab projects by name")
+	relayCmd.Flags().StringVar(&relaySyncOpts.StateBackend, "state-backend", state.BackendConfigMap, fmt.Sprintf("Where to keep the registered webhook state - one of %s", state.AllowedBackends))
+	relayCmd.Flags().StringVar(&relaySyncOpts.StateNamespace, "state-namespace", "atlantis", "Namespace of the state ConfigMap or Secret")
+	relayCmd.Flags().StringVar(&relaySyncOpts.StateName, "state-name", "ngrok", "Name of the state ConfigMap or Secret")
+	relayCmd.Flags().StringVar(&relaySyncOpts.StatePath, "state-path", relaySyncOpts.StatePath, "Path of the state file when using the file backend")
+	relayCmd.Flags().IntVar(&relaySyncOpts.HistoryLimit, "history-limit", state.DefaultHistoryLimit, "Number of previously registered webhooks to keep per repository")
+	relayCmd.Flags().BoolVar(&relaySyncOpts.RecordEvents, "record-events", false, "Record Kubernetes Events and last-sync annotations for the sync")
+	relayCmd.Flags().StringVar(&relaySyncOpts.EventObjectKind, "event-object-kind", "ConfigMap", "Kind of the object in the atlantis Namespace to record Events on - ConfigMap or Deployment")
+	relayCmd.Flags().StringVar(&relaySyncOpts.EventObjectName, "event-object-name", "ngrok", "Name of the object in the atlantis Namespace to record Events on")
+
+	relayCmd.Flags().BoolVar(&relaySyncOpts.KubeInClusterConfig, "use-kubeconfig-in-cluster", true, "kube config type - in-cluster (default), set to false to use local")
+	relayCmd.Flags().StringVar(&relaySyncOpts.Kubeconfig, "kubeconfig", relaySyncOpts.Kubeconfig, "Path to a local kubeconfig (defaults to $KUBECONFIG or ~/.kube/config)")
+	relayCmd.Flags().StringVar(&relaySyncOpts.KubeContext, "context", relaySyncOpts.KubeContext, "kubeconfig context to use instead of the current context")
+}
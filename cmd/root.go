@@ -1,11 +1,15 @@
 package cmd
 
 import (
+	"net/http"
 	"os"
 
+	"github.com/kubefirst/git-helper/internal/cassette"
 	"github.com/spf13/cobra"
 )
 
+var cassetteOpts cassette.Options
+
 // rootCmd represents the base command when called without any subcommands
 var rootCmd = &cobra.Command{
 	Use:   "git-helper",
@@ -15,6 +19,17 @@ Can be used to update webhooks, etc.`,
 	// Uncomment the following line if your bare application
 	// has an action associated with it:
 	// Run: func(cmd *cobra.Command, args []string) { },
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		// Record or replay api interactions when a cassette is in use
+		transport, err := cassetteOpts.Transport(http.DefaultTransport)
+		if err != nil {
+			return err
+		}
+		syncWebhookOpts.Transport = transport
+		relaySyncOpts.Transport = transport
+		controllerOpts.Transport = transport
+		return nil
+	},
 }
 
 // Execute adds all child commands to the root command and sets flags appropriately.
@@ -32,6 +47,8 @@ func init() {
 	// will be global for your application.
 
 	// rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is $HOME/.git-helper.yaml)")
+	rootCmd.PersistentFlags().StringVar(&cassetteOpts.Record, "record", "", "Record GitHub/GitLab api interactions to this cassette file, with credentials scrubbed")
+	rootCmd.PersistentFlags().StringVar(&cassetteOpts.Replay, "replay", "", "Serve GitHub/GitLab api responses from this cassette file instead of the network")
 
 	// Cobra also supports local flags, which will only run
 	// when this action is called directly.
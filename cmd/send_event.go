@@ -0,0 +1,92 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/kubefirst/git-helper/internal/sender"
+	"github.com/kubefirst/git-helper/internal/sync"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var (
+	sendEventOpts       *sender.Options      = &sender.Options{}
+	sendEventSecretOpts *sync.WebhookOptions = &sync.WebhookOptions{}
+
+	sendEventURL string
+)
+
+// sendEventCmd represents the send-event command
+var sendEventCmd = &cobra.Command{
+	Use:   "send-event",
+	Short: "Send a synthetic, signed webhook event to a url",
+	Long: `Build a realistic GitHub or GitLab webhook payload for a repository and
+POST it to --url with the headers and signature the provider would use, then
+print the response.
+
+GitHub supports push, pull_request and issue_comment events, GitLab supports
+push, merge_request and note events. pull_request and merge_request, and
+issue_comment and note, can be used interchangeably.
+
+The secret comes from --token, or from a Kubernetes Secret with --use-secret
+(the Atlantis secret and the webhook key of the provider by default), the
+same as the sync commands. Events are sent unsigned when no secret is given.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		sendEventSecretOpts.Provider = sendEventOpts.Provider
+		secret, err := sync.WebhookSecret(*sendEventSecretOpts)
+		if err != nil {
+			log.Fatalf("error running command: %s", err)
+		}
+		if secret == "" {
+			log.Warn("no secret configured, sending the event unsigned")
+		}
+		sendEventOpts.Secret = secret
+
+		result, err := sender.Send(sendEventURL, *sendEventOpts)
+		if err != nil {
+			log.Fatalf("error running command: %s", err)
+		}
+		fmt.Printf("delivery %s answered %d\n", result.DeliveryID, result.StatusCode)
+		if result.Body != "" {
+			fmt.Println(result.Body)
+		}
+		if result.StatusCode < 200 || result.StatusCode > 299 {
+			log.Fatalf("error running command: %s answered %d", sendEventURL, result.StatusCode)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(sendEventCmd)
+
+	sendEventCmd.Flags().StringVar(&sendEventURL, "url", sendEventURL, "Url to deliver the event to, e.g. https://example.ngrok.io/events (required)")
+	err := sendEventCmd.MarkFlagRequired("url")
+	if err != nil {
+		log.Fatal(err)
+	}
+	sendEventCmd.Flags().StringVar(&sendEventOpts.Provider, "provider", sendEventOpts.Provider, fmt.Sprintf("Provider - one of %s (required)", allowedGitProviders))
+	err = sendEventCmd.MarkFlagRequired("provider")
+	if err != nil {
+		log.Fatal(err)
+	}
+	sendEventCmd.Flags().StringVar(&sendEventOpts.Repository, "repository", sendEventOpts.Repository, "Full name of the repository or project, e.g. owner/repo (required)")
+	err = sendEventCmd.MarkFlagRequired("repository")
+	if err != nil {
+		log.Fatal(err)
+	}
+	sendEventCmd.Flags().StringVar(&sendEventOpts.Event, "event", sender.EventPush, "Event to send - push, pull_request, issue_comment, merge_request or note")
+	sendEventCmd.Flags().StringVar(&sendEventOpts.Branch, "branch", "git-helper-test", "Pushed or source branch")
+	sendEventCmd.Flags().StringVar(&sendEventOpts.Base, "base", "main", "Target branch of pull and merge requests")
+	sendEventCmd.Flags().IntVar(&sendEventOpts.Number, "number", 1, "Pull or merge request number")
+	sendEventCmd.Flags().StringVar(&sendEventOpts.Comment, "comment", "atlantis help", "Body of issue_comment and note events")
+	sendEventCmd.Flags().StringVar(&sendEventOpts.Sender, "sender", "git-helper", "User the event is attributed to")
+
+	sendEventCmd.Flags().StringVar(&sendEventSecretOpts.Token, "token", sendEventSecretOpts.Token, "Webhook secret to sign the event with")
+	sendEventCmd.Flags().BoolVar(&sendEventSecretOpts.UseSecret, "use-secret", false, "Read the webhook secret from a Kubernetes Secret")
+	sendEventCmd.Flags().StringVar(&sendEventSecretOpts.SecretName, "secret-name", "atlantis-secrets", "Name of the Secret holding the webhook secret")
+	sendEventCmd.Flags().StringVar(&sendEventSecretOpts.SecretNamespace, "namespace", "atlantis", "Namespace of the Secret holding the webhook secret")
+	sendEventCmd.Flags().StringVar(&sendEventSecretOpts.SecretValues, "secret-values", sendEventSecretOpts.SecretValues, "Key of the webhook secret in the Secret (defaults to the Atlantis key of the provider)")
+	sendEventCmd.Flags().BoolVar(&sendEventSecretOpts.KubeInClusterConfig, "use-kubeconfig-in-cluster", true, "kube config type - in-cluster (default), set to false to use local")
+	sendEventCmd.Flags().StringVar(&sendEventSecretOpts.Kubeconfig, "kubeconfig", sendEventSecretOpts.Kubeconfig, "Path to a local kubeconfig (defaults to $KUBECONFIG or ~/.kube/config)")
+	sendEventCmd.Flags().StringVar(&sendEventSecretOpts.KubeContext, "context", sendEventSecretOpts.KubeContext, "kubeconfig context to use instead of the current context")
+}
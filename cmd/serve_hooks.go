@@ -0,0 +1,99 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/kubefirst/git-helper/internal/receiver"
+	"github.com/kubefirst/git-helper/internal/sync"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var (
+	serveHooksOpts       *receiver.Options    = &receiver.Options{}
+	serveHooksSecretOpts *sync.WebhookOptions = &sync.WebhookOptions{}
+
+	serveHooksAddr string
+	serveHooksPath string
+)
+
+// serveHooksCmd represents the serve-hooks command
+var serveHooksCmd = &cobra.Command{
+	Use:   "serve-hooks",
+	Short: "Receive webhook deliveries and verify their signatures",
+	Long: `Start an http listener that accepts GitHub and GitLab webhook deliveries,
+validates them against the webhook secret and prints the event type,
+repository and a summary of the payload.
+
+GitHub deliveries are checked against X-Hub-Signature-256 and GitLab
+deliveries against X-Gitlab-Token. The secrets come from --github-secret and
+--gitlab-secret, or from --token or a Kubernetes Secret with --use-secret
+(the Atlantis secret and the webhook key of each provider by default), the
+same as the sync commands. Deliveries are accepted unverified when no secret
+is configured for their provider.
+
+Point a tunnel at the listener to check that webhook urls and secrets line
+up end to end.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		secrets, err := sync.WebhookSecrets(*serveHooksSecretOpts)
+		if err != nil {
+			log.Fatalf("error running command: %s", err)
+		}
+		if serveHooksOpts.GitHubSecret == "" {
+			serveHooksOpts.GitHubSecret = secrets["github"]
+		}
+		if serveHooksOpts.GitLabSecret == "" {
+			serveHooksOpts.GitLabSecret = secrets["gitlab"]
+		}
+		if serveHooksOpts.GitHubSecret == "" {
+			log.Warn("no GitHub secret configured, GitHub deliveries are not verified")
+		}
+		if serveHooksOpts.GitLabSecret == "" {
+			log.Warn("no GitLab secret configured, GitLab deliveries are not verified")
+		}
+
+		mux := http.NewServeMux()
+		mux.Handle(serveHooksPath, receiver.Handler(*serveHooksOpts))
+		server := &http.Server{Addr: serveHooksAddr, Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		go func() {
+			<-ctx.Done()
+			shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			server.Shutdown(shutdownCtx)
+		}()
+
+		log.Infof("receiving webhook deliveries on %s%s", serveHooksAddr, serveHooksPath)
+		err = server.ListenAndServe()
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatalf("error running command: %s", err)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(serveHooksCmd)
+
+	serveHooksCmd.Flags().StringVar(&serveHooksAddr, "addr", ":4141", "Address to listen on")
+	serveHooksCmd.Flags().StringVar(&serveHooksPath, "path", "/events", "Path deliveries are accepted on")
+	serveHooksCmd.Flags().BoolVar(&serveHooksOpts.PrintPayload, "print-payload", false, "Print the full indented payload of each delivery")
+
+	serveHooksCmd.Flags().StringVar(&serveHooksOpts.GitHubSecret, "github-secret", serveHooksOpts.GitHubSecret, "Secret GitHub deliveries are signed with")
+	serveHooksCmd.Flags().StringVar(&serveHooksOpts.GitLabSecret, "gitlab-secret", serveHooksOpts.GitLabSecret, "Secret token GitLab deliveries carry")
+	serveHooksCmd.Flags().StringVar(&serveHooksSecretOpts.Token, "token", serveHooksSecretOpts.Token, "Webhook secret of both providers unless set per provider")
+	serveHooksCmd.Flags().BoolVar(&serveHooksSecretOpts.UseSecret, "use-secret", false, "Read the webhook secrets from a Kubernetes Secret")
+	serveHooksCmd.Flags().StringVar(&serveHooksSecretOpts.SecretName, "secret-name", "atlantis-secrets", "Name of the Secret holding the webhook secrets")
+	serveHooksCmd.Flags().StringVar(&serveHooksSecretOpts.SecretNamespace, "namespace", "atlantis", "Namespace of the Secret holding the webhook secrets")
+	serveHooksCmd.Flags().StringVar(&serveHooksSecretOpts.SecretValues, "secret-values", serveHooksSecretOpts.SecretValues, "Key of the webhook secrets in the Secret (defaults to the Atlantis key of each provider)")
+	serveHooksCmd.Flags().BoolVar(&serveHooksSecretOpts.KubeInClusterConfig, "use-kubeconfig-in-cluster", true, "kube config type - in-cluster (default), set to false to use local")
+	serveHooksCmd.Flags().StringVar(&serveHooksSecretOpts.Kubeconfig, "kubeconfig", serveHooksSecretOpts.Kubeconfig, "Path to a local kubeconfig (defaults to $KUBECONFIG or ~/.kube/config)")
+	serveHooksCmd.Flags().StringVar(&serveHooksSecretOpts.KubeContext, "context", serveHooksSecretOpts.KubeContext, "kubeconfig context to use instead of the current context")
+}
@@ -1,8 +1,16 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
+	"github.com/kubefirst/git-helper/internal/hooks"
+	"github.com/kubefirst/git-helper/internal/state"
 	"github.com/kubefirst/git-helper/internal/sync"
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
@@ -11,6 +19,10 @@ import (
 var (
 	syncWebhookOpts *sync.WebhookOptions = &sync.WebhookOptions{}
 
+	// hookActive and hookSSLVerification back the optional hook settings
+	hookActive          bool
+	hookSSLVerification bool
+
 	allowedGitProviders []string = []string{"github", "gitlab"}
 )
 
@@ -57,13 +69,20 @@ var syncWebhookDeleteCmd = &cobra.Command{
 	},
 }
 
-// syncNgrokAtlantisWebhook represents the sync webhook delete command
+// syncNgrokAtlantisWebhook represents the sync webhook ngrok-atlantis command
 var syncNgrokAtlantisWebhookCmd = &cobra.Command{
 	Use:   "ngrok-atlantis",
 	Short: "Create a webhook based on an ngrok tunnel for Atlantis",
 	Long:  `"Create a webhook based on an ngrok tunnel for Atlantis"`,
 	Run: func(cmd *cobra.Command, args []string) {
-		err := sync.SynchronizeAtlantisWebhook(*syncWebhookOpts)
+		var err error
+		if syncWebhookOpts.Watch {
+			ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+			err = sync.WatchAtlantisWebhook(ctx, *syncWebhookOpts)
+		} else {
+			err = sync.SynchronizeAtlantisWebhook(*syncWebhookOpts)
+		}
 		if err != nil {
 			log.Fatalf("error running command: %s", err)
 		}
@@ -79,7 +98,7 @@ func init() {
 
 	// Required flags
 	var attach []*cobra.Command
-	attach = append(attach, syncWebhookCreateCmd, syncWebhookDeleteCmd, syncNgrokAtlantisWebhookCmd)
+	attach = append(attach, syncWebhookCreateCmd, syncWebhookDeleteCmd, syncNgrokAtlantisWebhookCmd, syncWebhookHistoryCmd, syncWebhookRollbackCmd)
 
 	for _, command := range attach {
 		command.Flags().StringVar(&syncWebhookOpts.Owner, "owner", syncWebhookOpts.Owner, "Owner - organization or primary group")
@@ -109,8 +128,85 @@ func init() {
 		// Other options
 		command.Flags().StringVar(&syncWebhookOpts.Token, "token", syncWebhookOpts.Token, "Secret token to provide to webhook")
 		command.Flags().BoolVar(&syncWebhookOpts.Cleanup, "cleanup", false, "Remove tokens but don't add new ones")
+		command.Flags().BoolVar(&syncWebhookOpts.Strict, "strict", false, "Fail instead of acting on every hook when more than one hook matches the url")
 
 		command.Flags().BoolVar(&syncWebhookOpts.KubeInClusterConfig, "use-kubeconfig-in-cluster", true, "kube config type - in-cluster (default), set to false to use local")
+		command.Flags().StringVar(&syncWebhookOpts.Kubeconfig, "kubeconfig", syncWebhookOpts.Kubeconfig, "Path to a local kubeconfig (defaults to $KUBECONFIG or ~/.kube/config)")
+		command.Flags().StringVar(&syncWebhookOpts.KubeContext, "context", syncWebhookOpts.KubeContext, "kubeconfig context to use instead of the current context")
 		command.Flags().BoolVar(&syncWebhookOpts.Restart, "restart", false, "If provided, trigger ngrok restart via ConfigMap edit")
 	}
+
+	// GitLab project lookup
+	for _, command := range append(attach, syncWebhookGCCmd, syncWebhookDedupeCmd, syncWebhookBulkCmd) {
+		command.Flags().BoolVar(&syncWebhookOpts.GitLabRecursive, "gitlab-recursive", false, "Include subgroups of the owner group when listing GitLab projects or looking them up by name")
+	}
+
+	// Public url discovery
+	syncNgrokAtlantisWebhookCmd.Flags().StringVar(&syncWebhookOpts.URLSource, "url-source", sync.URLSourceNgrok, fmt.Sprintf("Where to discover the public url - one of %s", sync.AllowedURLSources))
+	syncNgrokAtlantisWebhookCmd.Flags().StringVar(&syncWebhookOpts.SourceName, "source-name", syncWebhookOpts.SourceName, "Name of the Ingress, HTTPRoute or Service to read the public url from")
+	syncNgrokAtlantisWebhookCmd.Flags().StringVar(&syncWebhookOpts.SourceNamespace, "source-namespace", "atlantis", "Namespace of the Ingress, HTTPRoute or Service to read the public url from")
+	syncNgrokAtlantisWebhookCmd.Flags().BoolVar(&syncWebhookOpts.Watch, "watch", false, "Keep running and re-synchronize whenever the public url changes")
+	syncNgrokAtlantisWebhookCmd.Flags().DurationVar(&syncWebhookOpts.WatchInterval, "watch-interval", 30*time.Second, "How often to check the public url when using --watch")
+
+	// State store
+	for _, command := range []*cobra.Command{syncNgrokAtlantisWebhookCmd, syncWebhookHistoryCmd, syncWebhookRollbackCmd} {
+		command.Flags().StringVar(&syncWebhookOpts.StateBackend, "state-backend", state.BackendConfigMap, fmt.Sprintf("Where to keep the registered webhook state - one of %s", state.AllowedBackends))
+		command.Flags().StringVar(&syncWebhookOpts.StateNamespace, "state-namespace", "atlantis", "Namespace of the state ConfigMap or Secret")
+		command.Flags().StringVar(&syncWebhookOpts.StateName, "state-name", "ngrok", "Name of the state ConfigMap or Secret")
+		command.Flags().StringVar(&syncWebhookOpts.StatePath, "state-path", syncWebhookOpts.StatePath, "Path of the state file when using the file backend")
+		command.Flags().IntVar(&syncWebhookOpts.HistoryLimit, "history-limit", state.DefaultHistoryLimit, "Number of previously registered webhooks to keep per repository")
+	}
+
+	// Events, sync status and locking
+	for _, command := range []*cobra.Command{syncNgrokAtlantisWebhookCmd, syncWebhookRollbackCmd} {
+		command.Flags().BoolVar(&syncWebhookOpts.RecordEvents, "record-events", false, "Record Kubernetes Events and last-sync annotations for the sync")
+		command.Flags().StringVar(&syncWebhookOpts.EventObjectKind, "event-object-kind", "ConfigMap", "Kind of the object in the atlantis Namespace to record Events on - ConfigMap or Deployment")
+		command.Flags().StringVar(&syncWebhookOpts.EventObjectName, "event-object-name", "ngrok", "Name of the object in the atlantis Namespace to record Events on")
+
+		command.Flags().BoolVar(&syncWebhookOpts.Lock, "lock", false, "Hold a Kubernetes Lease while synchronizing so concurrent runs do not race")
+		command.Flags().StringVar(&syncWebhookOpts.LockName, "lock-name", "git-helper-sync", "Name of the Lease used by --lock")
+		command.Flags().StringVar(&syncWebhookOpts.LockNamespace, "lock-namespace", "atlantis", "Namespace of the Leases used by --lock and --leader-elect")
+		command.Flags().DurationVar(&syncWebhookOpts.LockTimeout, "lock-timeout", 5*time.Minute, "How long to wait for the Lease when using --lock")
+	}
+
+	// Hook options
+	syncWebhookOpts.Hook.Active = &hookActive
+	syncWebhookOpts.Hook.GitLab.SSLVerification = &hookSSLVerification
+	for _, command := range []*cobra.Command{syncNgrokAtlantisWebhookCmd, syncWebhookRollbackCmd, syncWebhookBulkCmd} {
+		command.Flags().StringSliceVar(&syncWebhookOpts.Hook.Events, "events", syncWebhookOpts.Hook.Events, fmt.Sprintf("Events to subscribe the webhook to - any of %s or provider event names (defaults to the events Atlantis needs)", hooks.AllowedEvents))
+		command.Flags().StringVar(&syncWebhookOpts.Hook.ContentType, "content-type", hooks.ContentTypeJSON, "Payload format of GitHub webhooks - json or form")
+		command.Flags().BoolVar(&syncWebhookOpts.Hook.InsecureSSL, "insecure-ssl", false, "Skip TLS certificate verification when delivering to the webhook")
+		command.Flags().BoolVar(&hookActive, "active", true, "Create GitHub webhooks as active")
+		command.Flags().BoolVar(&syncWebhookOpts.Hook.GitLab.TagPushEvents, "gitlab-tag-push-events", false, "Also subscribe GitLab webhooks to tag push events")
+		command.Flags().BoolVar(&syncWebhookOpts.Hook.GitLab.PipelineEvents, "gitlab-pipeline-events", false, "Also subscribe GitLab webhooks to pipeline events")
+		command.Flags().BoolVar(&syncWebhookOpts.Hook.GitLab.JobEvents, "gitlab-job-events", false, "Also subscribe GitLab webhooks to job events")
+		command.Flags().BoolVar(&syncWebhookOpts.Hook.GitLab.ReleasesEvents, "gitlab-releases-events", false, "Also subscribe GitLab webhooks to release events")
+		command.Flags().BoolVar(&hookSSLVerification, "gitlab-ssl-verification", true, "Verify TLS certificates when GitLab delivers to the webhook")
+	}
+
+	// Leader election
+	syncNgrokAtlantisWebhookCmd.Flags().BoolVar(&syncWebhookOpts.LeaderElect, "leader-elect", false, "Only watch while holding the leader Lease when using --watch")
+	syncNgrokAtlantisWebhookCmd.Flags().StringVar(&syncWebhookOpts.LeaderElectionID, "leader-election-id", "git-helper-watch", "Name of the Lease used by --leader-elect")
+
+	// ngrok tunnel selection
+	syncNgrokAtlantisWebhookCmd.Flags().StringVar(&syncWebhookOpts.NgrokAPIURL, "ngrok-api-url", "http://ngrok:4040/api/tunnels", "URL of the ngrok agent tunnels api")
+	syncNgrokAtlantisWebhookCmd.Flags().StringVar(&syncWebhookOpts.TunnelName, "tunnel-name", syncWebhookOpts.TunnelName, "Name of the ngrok tunnel to use")
+	syncNgrokAtlantisWebhookCmd.Flags().StringVar(&syncWebhookOpts.TunnelProto, "tunnel-proto", syncWebhookOpts.TunnelProto, "Protocol of the ngrok tunnel to use (https is preferred if omitted)")
+	syncNgrokAtlantisWebhookCmd.Flags().StringVar(&syncWebhookOpts.TunnelAddr, "tunnel-addr", syncWebhookOpts.TunnelAddr, "Upstream address of the ngrok tunnel to use, e.g. http://atlantis:4141")
+	syncNgrokAtlantisWebhookCmd.Flags().BoolVar(&syncWebhookOpts.Wait, "wait", false, "Poll the ngrok api until a matching tunnel is available")
+	syncNgrokAtlantisWebhookCmd.Flags().DurationVar(&syncWebhookOpts.WaitTimeout, "wait-timeout", 2*time.Minute, "How long to wait for a matching tunnel when using --wait")
+
+	// Pre-flight reachability check
+	syncNgrokAtlantisWebhookCmd.Flags().BoolVar(&syncWebhookOpts.Preflight, "preflight", false, "Check that the new public url reaches Atlantis before replacing the webhook, keeping the old one if it does not")
+	syncNgrokAtlantisWebhookCmd.Flags().StringVar(&syncWebhookOpts.PreflightPath, "preflight-path", "/healthz", "Path requested through the public url when using --preflight")
+	syncNgrokAtlantisWebhookCmd.Flags().IntVar(&syncWebhookOpts.PreflightStatus, "preflight-status", http.StatusOK, "Status code expected from --preflight-path")
+	syncNgrokAtlantisWebhookCmd.Flags().DurationVar(&syncWebhookOpts.PreflightTimeout, "preflight-timeout", 30*time.Second, "How long to wait for the public url to answer when using --preflight")
+
+	// Post-sync verification
+	syncNgrokAtlantisWebhookCmd.Flags().BoolVar(&syncWebhookOpts.Verify, "verify", false, "Ping the new webhook and fail unless the endpoint answers with a 2xx status code")
+	syncNgrokAtlantisWebhookCmd.Flags().DurationVar(&syncWebhookOpts.VerifyTimeout, "verify-timeout", 30*time.Second, "How long to wait for the ping delivery, or the GitLab test event, when using --verify")
+
+	// ngrok restart
+	syncNgrokAtlantisWebhookCmd.Flags().StringVar(&syncWebhookOpts.NgrokDeployment, "ngrok-deployment", "ngrok", "Name of the ngrok Deployment to wait for when using --restart")
+	syncNgrokAtlantisWebhookCmd.Flags().DurationVar(&syncWebhookOpts.RestartTimeout, "restart-timeout", 5*time.Minute, "How long to wait for ngrok to restart and report a new tunnel when using --restart")
 }
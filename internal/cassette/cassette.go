@@ -0,0 +1,208 @@
+package cassette
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// apiBasePath matches the base path of GitHub Enterprise (/api/v3) and GitLab
+// (/api/v4) api urls, along with any prefix a proxy or install path adds
+var apiBasePath *regexp.Regexp = regexp.MustCompile(`^[^?]*?/api/v[34](/|$)`)
+
+// Transport returns a transport recording to or replaying from the cassette
+// selected by the options, or next if neither is set
+// Clients sharing the returned transport share the cassette, so a recording
+// holds the interactions of every client
+func (opts Options) Transport(next http.RoundTripper) (http.RoundTripper, error) {
+	switch {
+	case opts.Record != "" && opts.Replay != "":
+		return nil, fmt.Errorf("recording and replaying cannot be combined")
+	case opts.Record != "":
+		return NewRecorder(opts.Record, next), nil
+	case opts.Replay != "":
+		return Load(opts.Replay)
+	default:
+		return next, nil
+	}
+}
+
+// Replaying reports whether transport serves responses from a cassette, in
+// which case no credentials are needed
+func Replaying(transport http.RoundTripper) bool {
+	_, ok := transport.(*Replayer)
+	return ok
+}
+
+// NewRecorder returns a Recorder saving interactions made through next to path
+func NewRecorder(path string, next http.RoundTripper) *Recorder {
+	return &Recorder{path: path, next: next}
+}
+
+// RoundTrip performs the request through the wrapped transport and records
+// the sanitized interaction
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	reqBody, err := readBody(&req.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := r.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	respBody, err := readBody(&resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	interaction := Interaction{
+		Request: Request{
+			Method:  req.Method,
+			URL:     scrubURL(req.URL.String()),
+			Headers: scrubHeaders(req.Header),
+			Body:    scrubBody(reqBody),
+		},
+		Response: Response{
+			StatusCode: resp.StatusCode,
+			Headers:    scrubHeaders(resp.Header),
+			Body:       scrubBody(respBody),
+		},
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.cassette.Interactions = append(r.cassette.Interactions, interaction)
+	err = r.save()
+	if err != nil {
+		return nil, err
+	}
+
+	return resp, nil
+}
+
+// save writes the cassette to the recorder path
+// The whole file is rewritten so the recording survives a failing command
+func (r *Recorder) save() error {
+	data, err := json.MarshalIndent(r.cassette, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error encoding cassette: %s", err)
+	}
+
+	err = os.MkdirAll(filepath.Dir(r.path), 0755)
+	if err != nil {
+		return fmt.Errorf("error creating cassette directory: %s", err)
+	}
+	err = os.WriteFile(r.path, data, 0644)
+	if err != nil {
+		return fmt.Errorf("error writing cassette %s: %s", r.path, err)
+	}
+
+	return nil
+}
+
+// Load returns a Replayer for the cassette file at path
+func Load(path string) (*Replayer, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading cassette %s: %s", path, err)
+	}
+
+	var cassette Cassette
+	err = json.Unmarshal(data, &cassette)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing cassette %s: %s", path, err)
+	}
+
+	return &Replayer{cassette: cassette, used: make([]bool, len(cassette.Interactions))}, nil
+}
+
+// RoundTrip serves the first unused interaction recorded for the method and
+// url of the request, ignoring the host and api base path so cassettes
+// recorded against GitHub Enterprise or self-managed GitLab still match
+// The path and query must otherwise be equal
+// Once all matching interactions are used, the last one is served again so
+// polling keeps working
+func (r *Replayer) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body != nil {
+		req.Body.Close()
+	}
+	want := normalizeURI(scrubURL(req.URL.String()))
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	match := -1
+	for i, interaction := range r.cassette.Interactions {
+		if interaction.Request.Method != req.Method || normalizeURI(interaction.Request.URL) != want {
+			continue
+		}
+		match = i
+		if !r.used[i] {
+			break
+		}
+	}
+	if match < 0 {
+		return nil, fmt.Errorf("no recorded interaction for %s %s", req.Method, want)
+	}
+	r.used[match] = true
+
+	recorded := r.cassette.Interactions[match].Response
+	header := make(http.Header)
+	for name, value := range recorded.Headers {
+		header.Set(name, value)
+	}
+
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", recorded.StatusCode, http.StatusText(recorded.StatusCode)),
+		StatusCode:    recorded.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(strings.NewReader(recorded.Body)),
+		ContentLength: int64(len(recorded.Body)),
+		Request:       req,
+	}, nil
+}
+
+// readBody reads and replaces body so it can still be consumed
+func readBody(body *io.ReadCloser) (string, error) {
+	if *body == nil || *body == http.NoBody {
+		return "", nil
+	}
+	data, err := io.ReadAll(*body)
+	(*body).Close()
+	if err != nil {
+		return "", fmt.Errorf("error reading body: %s", err)
+	}
+	*body = io.NopCloser(bytes.NewReader(data))
+	return string(data), nil
+}
+
+// normalizeURI returns the path and query of a url below the api base path
+func normalizeURI(url string) string {
+	uri := requestURI(url)
+	if match := apiBasePath.FindStringIndex(uri); match != nil {
+		uri = "/" + uri[match[1]:]
+	}
+	return uri
+}
+
+// requestURI strips the scheme and host from a url
+func requestURI(url string) string {
+	if i := strings.Index(url, "://"); i >= 0 {
+		url = url[i+3:]
+		if j := strings.Index(url, "/"); j >= 0 {
+			return url[j:]
+		}
+		return "/"
+	}
+	return url
+}
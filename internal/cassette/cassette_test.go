@@ -0,0 +1,263 @@
+package cassette
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRecordReplay(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		io.WriteString(w, `{"id":1,"config":{"url":"https://atlantis.ngrok.io/events","secret":"hook-secret"}}`)
+	}))
+	defer server.Close()
+
+	path := filepath.Join(t.TempDir(), "cassettes", "hooks.json")
+	client := &http.Client{Transport: NewRecorder(path, http.DefaultTransport)}
+
+	req, _ := http.NewRequest(http.MethodPost, server.URL+"/repos/kubefirst/gitops/hooks?private_token=glpat-123", strings.NewReader(`{"config":{"secret":"hook-secret"}}`))
+	req.Header.Set("Authorization", "Bearer ghp_123")
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("recording error = %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if !strings.Contains(string(body), "hook-secret") {
+		t.Errorf("recorded response body = %s, want it unchanged", body)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile() error = %v", err)
+	}
+	for _, secret := range []string{"ghp_123", "glpat-123", "hook-secret"} {
+		if strings.Contains(string(data), secret) {
+			t.Errorf("cassette contains %s:\n%s", secret, data)
+		}
+	}
+
+	replayer, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	client = &http.Client{Transport: replayer}
+
+	tests := []struct {
+		name       string
+		method     string
+		url        string
+		wantStatus int
+		wantErr    bool
+	}{
+		{
+			name:       "If the request was recorded on another host, should replay it",
+			method:     http.MethodPost,
+			url:        "http://replay.invalid/repos/kubefirst/gitops/hooks?private_token=other",
+			wantStatus: http.StatusCreated,
+		},
+		{
+			name:       "If the recorded interaction was used, should serve the last match again",
+			method:     http.MethodPost,
+			url:        "http://replay.invalid/repos/kubefirst/gitops/hooks?private_token=other",
+			wantStatus: http.StatusCreated,
+		},
+		{
+			name:       "If the request has an api base path, should replay it",
+			method:     http.MethodPost,
+			url:        "http://replay.invalid/api/v3/repos/kubefirst/gitops/hooks?private_token=other",
+			wantStatus: http.StatusCreated,
+		},
+		{
+			name:    "If the method was not recorded, should fail",
+			method:  http.MethodGet,
+			url:     "http://replay.invalid/repos/kubefirst/gitops/hooks",
+			wantErr: true,
+		},
+		{
+			name:    "If only a suffix of the path was recorded, should fail",
+			method:  http.MethodPost,
+			url:     "http://replay.invalid/repos/other/repos/kubefirst/gitops/hooks?private_token=other",
+			wantErr: true,
+		},
+		{
+			name:    "If the path is a suffix of the recorded one, should fail",
+			method:  http.MethodPost,
+			url:     "http://replay.invalid/gitops/hooks?private_token=other",
+			wantErr: true,
+		},
+		{
+			name:    "If the query differs, should fail",
+			method:  http.MethodPost,
+			url:     "http://replay.invalid/repos/kubefirst/gitops/hooks?page=2&private_token=other",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest(tt.method, tt.url, nil)
+			resp, err := client.Do(req)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("replay error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			resp.Body.Close()
+			if resp.StatusCode != tt.wantStatus {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestNormalizeURI(t *testing.T) {
+	tests := []struct {
+		name string
+		url  string
+		want string
+	}{
+		{
+			name: "If the url has no base path, should keep the path and query",
+			url:  "https://api.github.com/repos/kubefirst/gitops/hooks?page=1",
+			want: "/repos/kubefirst/gitops/hooks?page=1",
+		},
+		{
+			name: "If the url has the GitHub Enterprise base path, should strip it",
+			url:  "https://github.example.com/api/v3/repos/kubefirst/gitops/hooks",
+			want: "/repos/kubefirst/gitops/hooks",
+		},
+		{
+			name: "If the url has a GitLab base path below a prefix, should strip both",
+			url:  "https://example.com/gitlab/api/v4/projects/kubefirst%2Fgitops/hooks",
+			want: "/projects/kubefirst%2Fgitops/hooks",
+		},
+		{
+			name: "If the url is the api root, should return /",
+			url:  "https://gitlab.com/api/v4",
+			want: "/",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := normalizeURI(tt.url); got != tt.want {
+				t.Errorf("normalizeURI() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScrubBody(t *testing.T) {
+	tests := []struct {
+		name     string
+		body     string
+		wantGone []string
+		wantKept []string
+	}{
+		{
+			name:     "If a json field holds a credential, should redact it",
+			body:     `{"config":{"url":"https://atlantis.ngrok.io/events","secret":"hook-secret"}}`,
+			wantGone: []string{"hook-secret"},
+			wantKept: []string{"https://atlantis.ngrok.io/events"},
+		},
+		{
+			name:     "If a GitHub delivery holds signature headers, should redact them",
+			body:     `{"request":{"headers":{"X-Hub-Signature-256":"sha256=abc123","X-Hub-Signature":"sha1=def456","X-GitHub-Event":"ping"}}}`,
+			wantGone: []string{"sha256=abc123", "sha1=def456"},
+			wantKept: []string{"ping"},
+		},
+		{
+			name:     "If a GitLab hook event holds the token header, should redact it",
+			body:     `[{"request_headers":{"X-Gitlab-Token":"hook-secret","X-Gitlab-Event":"Push Hook"}}]`,
+			wantGone: []string{"hook-secret"},
+			wantKept: []string{"Push Hook"},
+		},
+		{
+			name:     "If the body is not json, should keep it as is",
+			body:     "payload=secret",
+			wantKept: []string{"payload=secret"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := scrubBody(tt.body)
+			for _, value := range tt.wantGone {
+				if strings.Contains(got, value) {
+					t.Errorf("scrubBody() = %s, contains %s", got, value)
+				}
+			}
+			for _, value := range tt.wantKept {
+				if !strings.Contains(got, value) {
+					t.Errorf("scrubBody() = %s, missing %s", got, value)
+				}
+			}
+		})
+	}
+}
+
+func TestOptionsTransport(t *testing.T) {
+	cassettePath := filepath.Join(t.TempDir(), "empty.json")
+	err := os.WriteFile(cassettePath, []byte(`{"interactions":[]}`), 0644)
+	if err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+
+	tests := []struct {
+		name          string
+		opts          Options
+		wantNext      bool
+		wantReplaying bool
+		wantErr       bool
+	}{
+		{
+			name:     "If no cassette is selected, should return next",
+			wantNext: true,
+		},
+		{
+			name: "If recording, should return a recorder",
+			opts: Options{Record: filepath.Join(t.TempDir(), "record.json")},
+		},
+		{
+			name:          "If replaying, should return a replayer",
+			opts:          Options{Replay: cassettePath},
+			wantReplaying: true,
+		},
+		{
+			name:    "If recording and replaying, should fail",
+			opts:    Options{Record: "record.json", Replay: cassettePath},
+			wantErr: true,
+		},
+		{
+			name:    "If the replayed cassette is missing, should fail",
+			opts:    Options{Replay: filepath.Join(t.TempDir(), "missing.json")},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			next := http.DefaultTransport
+			got, err := tt.opts.Transport(next)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Transport() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if (got == next) != tt.wantNext {
+				t.Errorf("Transport() returned next = %v, want %v", got == next, tt.wantNext)
+			}
+			if Replaying(got) != tt.wantReplaying {
+				t.Errorf("Replaying() = %v, want %v", Replaying(got), tt.wantReplaying)
+			}
+		})
+	}
+}
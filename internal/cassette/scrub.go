@@ -0,0 +1,109 @@
+package cassette
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// redacted replaces scrubbed values
+const redacted string = "REDACTED"
+
+// sensitiveHeaders are headers carrying credentials or signatures
+var sensitiveHeaders []string = []string{
+	"Authorization",
+	"Cookie",
+	"Private-Token",
+	"Set-Cookie",
+	"X-Gitlab-Token",
+	"X-Hub-Signature",
+	"X-Hub-Signature-256",
+}
+
+// sensitiveKeys are query parameters and json fields holding credentials
+var sensitiveKeys []string = []string{
+	"access_token",
+	"password",
+	"private_token",
+	"secret",
+	"token",
+}
+
+// isSensitive reports whether name is one of keys, ignoring case
+func isSensitive(name string, keys []string) bool {
+	for _, key := range keys {
+		if strings.EqualFold(name, key) {
+			return true
+		}
+	}
+	return false
+}
+
+// scrubHeaders flattens headers, redacting credentials
+func scrubHeaders(header http.Header) map[string]string {
+	if len(header) == 0 {
+		return nil
+	}
+	scrubbed := make(map[string]string, len(header))
+	for name, values := range header {
+		if isSensitive(name, sensitiveHeaders) {
+			scrubbed[name] = redacted
+			continue
+		}
+		scrubbed[name] = strings.Join(values, ", ")
+	}
+	return scrubbed
+}
+
+// scrubURL redacts credentials passed as query parameters
+func scrubURL(rawURL string) string {
+	parsed, err := url.Parse(rawURL)
+	if err != nil || parsed.RawQuery == "" {
+		return rawURL
+	}
+	query := parsed.Query()
+	for name := range query {
+		if isSensitive(name, sensitiveKeys) {
+			query.Set(name, redacted)
+		}
+	}
+	parsed.RawQuery = query.Encode()
+	return parsed.String()
+}
+
+// scrubBody redacts credentials in json bodies, other bodies are kept as is
+func scrubBody(body string) string {
+	var decoded interface{}
+	if body == "" || json.Unmarshal([]byte(body), &decoded) != nil {
+		return body
+	}
+	data, err := json.Marshal(scrubValue(decoded))
+	if err != nil {
+		return body
+	}
+	return string(data)
+}
+
+// scrubValue redacts sensitive fields of decoded json at any depth
+// Headers recorded in json bodies, such as the request headers of a hook
+// delivery, are redacted like the headers of the interaction itself
+func scrubValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, field := range v {
+			if isSensitive(key, sensitiveKeys) || isSensitive(key, sensitiveHeaders) {
+				if _, ok := field.(string); ok {
+					v[key] = redacted
+				}
+				continue
+			}
+			v[key] = scrubValue(field)
+		}
+	case []interface{}:
+		for i, item := range v {
+			v[i] = scrubValue(item)
+		}
+	}
+	return value
+}
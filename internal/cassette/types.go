@@ -0,0 +1,56 @@
+package cassette
+
+import (
+	"net/http"
+	stdsync "sync"
+)
+
+// Cassette holds recorded HTTP interactions in the order they were made
+type Cassette struct {
+	Interactions []Interaction `json:"interactions"`
+}
+
+// Interaction is a recorded request and the response it received
+type Interaction struct {
+	Request  Request  `json:"request"`
+	Response Response `json:"response"`
+}
+
+// Request is a sanitized HTTP request
+type Request struct {
+	Method  string            `json:"method"`
+	URL     string            `json:"url"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Body    string            `json:"body,omitempty"`
+}
+
+// Response is a sanitized HTTP response
+type Response struct {
+	StatusCode int               `json:"statusCode"`
+	Headers    map[string]string `json:"headers,omitempty"`
+	Body       string            `json:"body,omitempty"`
+}
+
+// Options selects whether HTTP interactions are recorded to or replayed
+// from a cassette file
+type Options struct {
+	Record string
+	Replay string
+}
+
+// Recorder is an http.RoundTripper that records interactions made through
+// next to a cassette file, saving it after every interaction
+type Recorder struct {
+	mu       stdsync.Mutex
+	path     string
+	next     http.RoundTripper
+	cassette Cassette
+}
+
+// Replayer is an http.RoundTripper that serves responses from a cassette
+type Replayer struct {
+	mu       stdsync.Mutex
+	cassette Cassette
+	// used marks interactions that were already served
+	used []bool
+}
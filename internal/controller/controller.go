@@ -0,0 +1,419 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/kubefirst/git-helper/internal/kubernetes"
+	"github.com/kubefirst/git-helper/internal/state"
+	"github.com/kubefirst/git-helper/internal/sync"
+	log "github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/dynamic/dynamicinformer"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/workqueue"
+)
+
+// Controller reconciles WebhookBindings against the git providers
+type Controller struct {
+	kube  *kubernetes.Client
+	opts  Options
+	queue workqueue.RateLimitingInterface
+}
+
+// NewController instantiates a WebhookBinding controller
+func NewController(kube *kubernetes.Client, opts Options) *Controller {
+	if opts.Workers < 1 {
+		opts.Workers = 1
+	}
+
+	return &Controller{
+		kube:  kube,
+		opts:  opts,
+		queue: workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), "webhookbindings"),
+	}
+}
+
+// Run watches WebhookBindings and reconciles them until ctx is cancelled
+func (c *Controller) Run(ctx context.Context) error {
+	defer c.queue.ShutDown()
+
+	factory := dynamicinformer.NewFilteredDynamicSharedInformerFactory(c.kube.Dynamic, c.opts.ResyncPeriod, c.opts.Namespace, nil)
+	informer := factory.ForResource(Resource).Informer()
+	_, err := informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    c.enqueue,
+		UpdateFunc: func(_, obj interface{}) { c.enqueue(obj) },
+		DeleteFunc: c.enqueue,
+	})
+	if err != nil {
+		return fmt.Errorf("error watching WebhookBindings: %s", err)
+	}
+
+	factory.Start(ctx.Done())
+	if !cache.WaitForCacheSync(ctx.Done(), informer.HasSynced) {
+		return fmt.Errorf("timed out waiting for WebhookBinding cache to sync")
+	}
+
+	log.Infof("starting %d WebhookBinding workers", c.opts.Workers)
+	for i := 0; i < c.opts.Workers; i++ {
+		go wait.UntilWithContext(ctx, c.runWorker, time.Second)
+	}
+
+	<-ctx.Done()
+	log.Info("stopping WebhookBinding controller")
+
+	return nil
+}
+
+// enqueue adds the key of a WebhookBinding to the work queue
+func (c *Controller) enqueue(obj interface{}) {
+	key, err := cache.DeletionHandlingMetaNamespaceKeyFunc(obj)
+	if err != nil {
+		log.Errorf("error getting WebhookBinding key: %s", err)
+		return
+	}
+	c.queue.Add(key)
+}
+
+// runWorker processes work queue items until the queue is shut down
+func (c *Controller) runWorker(ctx context.Context) {
+	for c.processNextItem(ctx) {
+	}
+}
+
+// processNextItem reconciles a single queued WebhookBinding, requeueing it
+// with backoff on failure
+func (c *Controller) processNextItem(ctx context.Context) bool {
+	item, shutdown := c.queue.Get()
+	if shutdown {
+		return false
+	}
+	defer c.queue.Done(item)
+
+	key := item.(string)
+	err := c.Reconcile(ctx, key)
+	if err != nil {
+		log.Errorf("error reconciling WebhookBinding %s: %s", key, err)
+		c.queue.AddRateLimited(key)
+		return true
+	}
+	c.queue.Forget(key)
+
+	return true
+}
+
+// Reconcile brings the remote hook of a single WebhookBinding in line with
+// its spec and records the result in its status
+func (c *Controller) Reconcile(ctx context.Context, key string) error {
+	namespace, name, err := cache.SplitMetaNamespaceKey(key)
+	if err != nil {
+		return err
+	}
+
+	obj, err := c.kube.Dynamic.Resource(Resource).Namespace(namespace).Get(ctx, name, metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("error getting WebhookBinding: %s", err)
+	}
+
+	binding, err := fromUnstructured(obj)
+	if err != nil {
+		return err
+	}
+
+	if binding.DeletionTimestamp != nil {
+		return c.finalize(ctx, binding)
+	}
+
+	if !hasFinalizer(binding) {
+		binding.Finalizers = append(binding.Finalizers, finalizer)
+		binding, err = c.update(ctx, binding)
+		if err != nil {
+			return err
+		}
+	}
+
+	url, err := c.resolveURL(binding)
+	if err != nil {
+		return c.fail(ctx, binding, "URLUnavailable", err)
+	}
+
+	token, err := c.resolveToken(binding)
+	if err != nil {
+		return c.fail(ctx, binding, "SecretUnavailable", err)
+	}
+
+	status := binding.Status
+	if status.HookID != 0 &&
+		status.LastSyncedURL == url &&
+		status.SecretHash == state.HashSecret(token) &&
+		status.ObservedGeneration == binding.Generation &&
+		meta.IsStatusConditionTrue(status.Conditions, ConditionReady) {
+		return nil
+	}
+
+	return c.withLock(ctx, func() error {
+		return c.replaceHook(ctx, key, binding, url, token)
+	})
+}
+
+// replaceHook points the remote hook of a binding at url, recording it in the
+// status before removing the previous hook
+func (c *Controller) replaceHook(ctx context.Context, key string, binding *WebhookBinding, url string, token string) error {
+	status := binding.Status
+
+	// The recorded hook is only kept if it lives on the binding repository
+	var previousID int64
+	if status.Provider == binding.Spec.Provider && status.Owner == binding.Spec.Owner && status.Repository == binding.Spec.Repository {
+		previousID = status.HookID
+	}
+
+	// A hook already delivering to url, either the recorded hook when only
+	// its events or secret changed, or one created by an attempt whose
+	// status write failed, is updated in place, since GitHub allows a single
+	// hook per url
+	hookID, err := findHook(binding, url, previousID, c.opts.Transport)
+	if err != nil {
+		return c.fail(ctx, binding, "ListFailed", err)
+	}
+	if hookID != 0 {
+		err = updateHook(binding, hookID, url, token, c.opts.Transport)
+		if err != nil {
+			return c.fail(ctx, binding, "UpdateFailed", err)
+		}
+	} else {
+		// Create the new hook before removing the old one so events are not missed
+		hookID, err = createHook(binding, url, token, c.opts.Transport)
+		if err != nil {
+			return c.fail(ctx, binding, "CreateFailed", err)
+		}
+	}
+
+	// Record the new hook before deleting the old one, so a failed status
+	// write leaves the old hook ID in place for the retry
+	binding.Status.HookID = hookID
+	binding.Status.Provider = binding.Spec.Provider
+	binding.Status.Owner = binding.Spec.Owner
+	binding.Status.Repository = binding.Spec.Repository
+	binding.Status.LastSyncedURL = url
+	binding.Status.SecretHash = state.HashSecret(token)
+	binding.Status.ObservedGeneration = binding.Generation
+	meta.SetStatusCondition(&binding.Status.Conditions, metav1.Condition{
+		Type:               ConditionReady,
+		Status:             metav1.ConditionTrue,
+		Reason:             "Synced",
+		Message:            fmt.Sprintf("hook %d points at %s", hookID, url),
+		ObservedGeneration: binding.Generation,
+	})
+	_, err = c.updateStatus(ctx, binding)
+	if err != nil {
+		return err
+	}
+
+	if status.HookID != 0 && status.HookID != hookID {
+		err = deleteHook(status.Provider, status.Owner, status.Repository, status.HookID, c.opts.Transport)
+		if err != nil {
+			log.Warnf("error deleting previous hook %d for WebhookBinding %s: %s", status.HookID, key, err)
+		}
+	}
+
+	return nil
+}
+
+// finalize removes the remote hook of a deleted WebhookBinding and then
+// releases its finalizer
+func (c *Controller) finalize(ctx context.Context, binding *WebhookBinding) error {
+	if !hasFinalizer(binding) {
+		return nil
+	}
+
+	status := binding.Status
+	if status.HookID != 0 {
+		err := c.withLock(ctx, func() error {
+			return deleteHook(status.Provider, status.Owner, status.Repository, status.HookID, c.opts.Transport)
+		})
+		if err != nil {
+			return fmt.Errorf("error deleting hook %d: %s", status.HookID, err)
+		}
+	}
+
+	finalizers := make([]string, 0, len(binding.Finalizers))
+	for _, f := range binding.Finalizers {
+		if f != finalizer {
+			finalizers = append(finalizers, f)
+		}
+	}
+	binding.Finalizers = finalizers
+	_, err := c.update(ctx, binding)
+
+	return err
+}
+
+// withLock runs fn while holding the sync Lease if locking is enabled
+func (c *Controller) withLock(ctx context.Context, fn func() error) error {
+	if !c.opts.Lock {
+		return fn()
+	}
+
+	return c.kube.WithLease(ctx, kubernetes.LeaseOptions{
+		Name:           c.opts.LockName,
+		Namespace:      c.opts.LockNamespace,
+		AcquireTimeout: c.opts.LockTimeout,
+	}, fn)
+}
+
+// fail records a failed sync on the Ready condition and returns the cause
+// so the binding is requeued
+func (c *Controller) fail(ctx context.Context, binding *WebhookBinding, reason string, cause error) error {
+	meta.SetStatusCondition(&binding.Status.Conditions, metav1.Condition{
+		Type:               ConditionReady,
+		Status:             metav1.ConditionFalse,
+		Reason:             reason,
+		Message:            cause.Error(),
+		ObservedGeneration: binding.Generation,
+	})
+	_, err := c.updateStatus(ctx, binding)
+	if err != nil {
+		log.Errorf("error updating WebhookBinding %s/%s status: %s", binding.Namespace, binding.Name, err)
+	}
+
+	return cause
+}
+
+// resolveURL returns the webhook url for a binding, including its path
+func (c *Controller) resolveURL(binding *WebhookBinding) (string, error) {
+	source := binding.Spec.URLSource
+	namespace := source.Namespace
+	if namespace == "" {
+		namespace = binding.Namespace
+	}
+
+	var base string
+	switch source.Type {
+	case "static":
+		if source.URL == "" {
+			return "", fmt.Errorf("a url is required when using the static url source")
+		}
+		base = source.URL
+	case "tunnel":
+		apiURL := source.APIURL
+		if apiURL == "" {
+			apiURL = fmt.Sprintf("http://ngrok.%s:4040/api/tunnels", namespace)
+		}
+		url, err := sync.ResolvePublicURL(sync.WebhookOptions{
+			URLSource:   sync.URLSourceNgrok,
+			TunnelName:  source.Name,
+			NgrokAPIURL: apiURL,
+		}, c.kube)
+		if err != nil {
+			return "", err
+		}
+		base = url
+	case sync.URLSourceIngress, sync.URLSourceHTTPRoute, sync.URLSourceService:
+		url, err := sync.ResolvePublicURL(sync.WebhookOptions{
+			URLSource:       source.Type,
+			SourceName:      source.Name,
+			SourceNamespace: namespace,
+		}, c.kube)
+		if err != nil {
+			return "", err
+		}
+		base = url
+	default:
+		return "", fmt.Errorf("unsupported url source type %s", source.Type)
+	}
+
+	path := "/events"
+	if source.Path != nil {
+		path = *source.Path
+	}
+
+	return strings.TrimSuffix(base, "/") + path, nil
+}
+
+// resolveToken returns the webhook token referenced by a binding, if any
+func (c *Controller) resolveToken(binding *WebhookBinding) (string, error) {
+	ref := binding.Spec.SecretRef
+	if ref == nil {
+		return "", nil
+	}
+
+	secret, err := c.kube.ReadSecret(binding.Namespace, ref.Name)
+	if err != nil {
+		return "", err
+	}
+	token, ok := secret[ref.Key]
+	if !ok {
+		return "", fmt.Errorf("key %s not found in Secret %s", ref.Key, ref.Name)
+	}
+
+	return token, nil
+}
+
+// update writes the metadata and spec of a binding
+func (c *Controller) update(ctx context.Context, binding *WebhookBinding) (*WebhookBinding, error) {
+	obj, err := toUnstructured(binding)
+	if err != nil {
+		return nil, err
+	}
+	obj, err = c.kube.Dynamic.Resource(Resource).Namespace(binding.Namespace).Update(ctx, obj, metav1.UpdateOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("error updating WebhookBinding: %s", err)
+	}
+
+	return fromUnstructured(obj)
+}
+
+// updateStatus writes the status of a binding
+func (c *Controller) updateStatus(ctx context.Context, binding *WebhookBinding) (*WebhookBinding, error) {
+	obj, err := toUnstructured(binding)
+	if err != nil {
+		return nil, err
+	}
+	obj, err = c.kube.Dynamic.Resource(Resource).Namespace(binding.Namespace).UpdateStatus(ctx, obj, metav1.UpdateOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("error updating WebhookBinding status: %s", err)
+	}
+
+	return fromUnstructured(obj)
+}
+
+// hasFinalizer reports whether the controller finalizer is set on a binding
+func hasFinalizer(binding *WebhookBinding) bool {
+	for _, f := range binding.Finalizers {
+		if f == finalizer {
+			return true
+		}
+	}
+	return false
+}
+
+// toUnstructured converts a binding for use with the dynamic client
+func toUnstructured(binding *WebhookBinding) (*unstructured.Unstructured, error) {
+	binding.APIVersion = Group + "/" + Version
+	binding.Kind = Kind
+	obj, err := runtime.DefaultUnstructuredConverter.ToUnstructured(binding)
+	if err != nil {
+		return nil, fmt.Errorf("error converting WebhookBinding: %s", err)
+	}
+	return &unstructured.Unstructured{Object: obj}, nil
+}
+
+// fromUnstructured converts a dynamic client object to a binding
+func fromUnstructured(obj *unstructured.Unstructured) (*WebhookBinding, error) {
+	binding := &WebhookBinding{}
+	err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, binding)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing WebhookBinding: %s", err)
+	}
+	return binding, nil
+}
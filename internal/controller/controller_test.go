@@ -0,0 +1,345 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kubefirst/git-helper/internal/fakeforge"
+	"github.com/kubefirst/git-helper/internal/kubernetes"
+	"github.com/kubefirst/git-helper/internal/state"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+const (
+	oldURL string = "https://old.example.com/events"
+	newURL string = "https://new.example.com/events"
+)
+
+// newBinding returns a github WebhookBinding for kubefirst/gitops with a
+// static url source pointing at url
+func newBinding(url string) *WebhookBinding {
+	path := ""
+	return &WebhookBinding{
+		ObjectMeta: metav1.ObjectMeta{Name: "atlantis", Namespace: "atlantis", Generation: 1},
+		Spec: WebhookBindingSpec{
+			Provider:   "github",
+			Owner:      "kubefirst",
+			Repository: "gitops",
+			URLSource:  URLSource{Type: "static", URL: url, Path: &path},
+		},
+	}
+}
+
+// syncedStatus records hookID pointing at url as the synced state of binding
+func syncedStatus(binding *WebhookBinding, hookID int64, url string) {
+	binding.Finalizers = []string{finalizer}
+	binding.Status = WebhookBindingStatus{
+		ObservedGeneration: binding.Generation,
+		HookID:             hookID,
+		Provider:           "github",
+		Owner:              "kubefirst",
+		Repository:         "gitops",
+		LastSyncedURL:      url,
+		Conditions: []metav1.Condition{{
+			Type:               ConditionReady,
+			Status:             metav1.ConditionTrue,
+			Reason:             "Synced",
+			LastTransitionTime: metav1.Now(),
+		}},
+	}
+}
+
+// hookURLs returns the urls of the hooks registered on kubefirst/gitops
+func hookURLs(forge *fakeforge.Server) []string {
+	urls := make([]string, 0)
+	for _, hook := range forge.GitHubHooks("kubefirst", "gitops") {
+		urls = append(urls, hook.URL)
+	}
+	return urls
+}
+
+func TestReconcile(t *testing.T) {
+	tests := []struct {
+		name string
+		// url is the url source of the binding
+		url string
+		// synced registers a hook for oldURL and records it in the status
+		synced bool
+		// deleted marks the binding for deletion
+		deleted bool
+		// failStatusWrites is the number of status writes that fail
+		failStatusWrites int
+		// lockHeld holds the sync Lease elsewhere while reconciling with --lock
+		lockHeld bool
+		// bumpGeneration changes the spec after the sync, e.g. its events
+		bumpGeneration bool
+		// secret is the token in the Secret referenced by the binding, which
+		// is synced with "secret" if set
+		secret string
+		// reconciles is the number of times the binding is reconciled
+		reconciles    int
+		wantErrs      []bool
+		wantHooks     []string
+		wantStatusURL string
+		wantFinalizer bool
+		// wantSecret is the secret of the remaining hook, if checked
+		wantSecret string
+	}{
+		{
+			name:          "If the binding is new, should create the hook and record it",
+			url:           newURL,
+			reconciles:    1,
+			wantErrs:      []bool{false},
+			wantHooks:     []string{newURL},
+			wantStatusURL: newURL,
+			wantFinalizer: true,
+		},
+		{
+			name:          "If nothing changed, should leave the hook alone",
+			url:           oldURL,
+			synced:        true,
+			reconciles:    2,
+			wantErrs:      []bool{false, false},
+			wantHooks:     []string{oldURL},
+			wantStatusURL: oldURL,
+			wantFinalizer: true,
+		},
+		{
+			name:          "If the url changed, should create the new hook and delete the old one",
+			url:           newURL,
+			synced:        true,
+			reconciles:    1,
+			wantErrs:      []bool{false},
+			wantHooks:     []string{newURL},
+			wantStatusURL: newURL,
+			wantFinalizer: true,
+		},
+		{
+			name:           "If the spec changed but not the url, should update the hook in place",
+			url:            oldURL,
+			synced:         true,
+			bumpGeneration: true,
+			reconciles:     1,
+			wantErrs:       []bool{false},
+			wantHooks:      []string{oldURL},
+			wantStatusURL:  oldURL,
+			wantFinalizer:  true,
+		},
+		{
+			name:          "If the Secret rotated, should update the hook secret",
+			url:           oldURL,
+			synced:        true,
+			secret:        "rotated",
+			reconciles:    2,
+			wantErrs:      []bool{false, false},
+			wantHooks:     []string{oldURL},
+			wantStatusURL: oldURL,
+			wantFinalizer: true,
+			wantSecret:    "rotated",
+		},
+		{
+			name:             "If the status write fails, should keep the old hook and adopt the new one on retry",
+			url:              newURL,
+			synced:           true,
+			failStatusWrites: 1,
+			reconciles:       2,
+			wantErrs:         []bool{true, false},
+			wantHooks:        []string{newURL},
+			wantStatusURL:    newURL,
+			wantFinalizer:    true,
+		},
+		{
+			name:          "If the sync Lease is held, should leave the old hook in place",
+			url:           newURL,
+			synced:        true,
+			lockHeld:      true,
+			reconciles:    1,
+			wantErrs:      []bool{true},
+			wantHooks:     []string{oldURL},
+			wantStatusURL: oldURL,
+			wantFinalizer: true,
+		},
+		{
+			name:          "If the binding is deleted, should delete the hook and release the finalizer",
+			url:           oldURL,
+			synced:        true,
+			deleted:       true,
+			reconciles:    1,
+			wantErrs:      []bool{false},
+			wantHooks:     []string{},
+			wantStatusURL: oldURL,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			forge := fakeforge.New()
+			forge.AddGitHubRepository("kubefirst", "gitops")
+			server := httptest.NewServer(forge.Handler())
+			defer server.Close()
+			t.Setenv("GIT_TOKEN", "fake")
+			t.Setenv("GITHUB_API_URL", server.URL+fakeforge.GitHubPrefix)
+
+			binding := newBinding(tt.url)
+			var syncedID int64
+			if tt.synced {
+				hookID, err := createHook(binding, oldURL, "secret", nil)
+				if err != nil {
+					t.Fatalf("createHook() error = %v", err)
+				}
+				syncedStatus(binding, hookID, oldURL)
+				syncedID = hookID
+			}
+			if tt.bumpGeneration {
+				binding.Generation++
+				binding.Spec.Events = []string{"push", "pull_request"}
+			}
+			objects := []runtime.Object{}
+			if tt.secret != "" {
+				binding.Spec.SecretRef = &SecretReference{Name: "atlantis-webhook", Key: "token"}
+				binding.Status.SecretHash = state.HashSecret("secret")
+				objects = append(objects, &v1.Secret{
+					ObjectMeta: metav1.ObjectMeta{Name: "atlantis-webhook", Namespace: "atlantis"},
+					Data:       map[string][]byte{"token": []byte(tt.secret)},
+				})
+			}
+			if tt.deleted {
+				now := metav1.NewTime(time.Now())
+				binding.DeletionTimestamp = &now
+			}
+
+			dynamic := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), map[schema.GroupVersionResource]string{Resource: Kind + "List"})
+			obj, err := toUnstructured(binding)
+			if err != nil {
+				t.Fatalf("toUnstructured() error = %v", err)
+			}
+			_, err = dynamic.Resource(Resource).Namespace("atlantis").Create(context.Background(), obj, metav1.CreateOptions{})
+			if err != nil {
+				t.Fatalf("Create() error = %v", err)
+			}
+			failures := tt.failStatusWrites
+			dynamic.PrependReactor("update", "webhookbindings", func(action k8stesting.Action) (bool, runtime.Object, error) {
+				if action.GetSubresource() == "status" && failures > 0 {
+					failures--
+					return true, nil, errors.New("conflict")
+				}
+				return false, nil, nil
+			})
+
+			kube := kubernetes.NewClientFromInterface(fake.NewSimpleClientset(objects...), dynamic)
+			opts := Options{
+				Lock:          true,
+				LockName:      "git-helper-sync",
+				LockNamespace: "atlantis",
+				LockTimeout:   500 * time.Millisecond,
+			}
+			if tt.lockHeld {
+				held := make(chan struct{})
+				done := make(chan struct{})
+				go kube.WithLease(context.Background(), kubernetes.LeaseOptions{Name: opts.LockName, Namespace: opts.LockNamespace}, func() error {
+					close(held)
+					<-done
+					return nil
+				})
+				defer close(done)
+				<-held
+			}
+
+			c := NewController(kube, opts)
+			for i := 0; i < tt.reconciles; i++ {
+				err := c.Reconcile(context.Background(), "atlantis/atlantis")
+				if (err != nil) != tt.wantErrs[i] {
+					t.Fatalf("Reconcile() %d error = %v, wantErr %v", i+1, err, tt.wantErrs[i])
+				}
+			}
+
+			if got := hookURLs(forge); strings.Join(got, ",") != strings.Join(tt.wantHooks, ",") {
+				t.Errorf("hooks = %v, want %v", got, tt.wantHooks)
+			}
+
+			obj, err = dynamic.Resource(Resource).Namespace("atlantis").Get(context.Background(), "atlantis", metav1.GetOptions{})
+			if err != nil {
+				t.Fatalf("Get() error = %v", err)
+			}
+			got, err := fromUnstructured(obj)
+			if err != nil {
+				t.Fatalf("fromUnstructured() error = %v", err)
+			}
+			if got.Status.LastSyncedURL != tt.wantStatusURL {
+				t.Errorf("status url = %s, want %s", got.Status.LastSyncedURL, tt.wantStatusURL)
+			}
+			if hasFinalizer(got) != tt.wantFinalizer {
+				t.Errorf("finalizer set = %v, want %v", hasFinalizer(got), tt.wantFinalizer)
+			}
+			if len(tt.wantHooks) == 1 {
+				hooks := forge.GitHubHooks("kubefirst", "gitops")
+				if got.Status.HookID != hooks[0].ID {
+					t.Errorf("status hook ID = %d, want %d", got.Status.HookID, hooks[0].ID)
+				}
+				// A hook kept at the same url is updated rather than replaced
+				if tt.wantHooks[0] == oldURL && syncedID != 0 && hooks[0].ID != syncedID {
+					t.Errorf("hook ID = %d, want the synced hook %d", hooks[0].ID, syncedID)
+				}
+				if tt.wantSecret != "" && hooks[0].Secret != tt.wantSecret {
+					t.Errorf("hook secret = %s, want %s", hooks[0].Secret, tt.wantSecret)
+				}
+			}
+		})
+	}
+}
+
+func TestResolveURL(t *testing.T) {
+	forge := fakeforge.New()
+	forge.SetTunnels(fakeforge.Tunnel{Name: "atlantis", PublicURL: "https://tunnel.ngrok.io", Proto: "https", Addr: "http://atlantis:4141"})
+	server := httptest.NewServer(forge.Handler())
+	defer server.Close()
+
+	path := "/hooks"
+	tests := []struct {
+		name    string
+		source  URLSource
+		want    string
+		wantErr bool
+	}{
+		{
+			name:   "If the source is a tunnel, should query the given ngrok api",
+			source: URLSource{Type: "tunnel", Name: "atlantis", APIURL: server.URL + fakeforge.NgrokPath},
+			want:   "https://tunnel.ngrok.io/events",
+		},
+		{
+			name:   "If a path is given, should append it instead of /events",
+			source: URLSource{Type: "static", URL: "https://atlantis.example.com/", Path: &path},
+			want:   "https://atlantis.example.com/hooks",
+		},
+		{
+			name:    "If a static source has no url, should fail",
+			source:  URLSource{Type: "static"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			binding := newBinding("")
+			binding.Spec.URLSource = tt.source
+			c := NewController(kubernetes.NewClientFromInterface(fake.NewSimpleClientset(), nil), Options{})
+			got, err := c.resolveURL(binding)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("resolveURL() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("resolveURL() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,175 @@
+package controller
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+
+	githubWrapper "github.com/kubefirst/git-helper/internal/github"
+	gitlabWrapper "github.com/kubefirst/git-helper/internal/gitlab"
+	"github.com/kubefirst/git-helper/internal/hooks"
+)
+
+// createHook creates the remote hook described by a binding and returns its ID
+func createHook(binding *WebhookBinding, url string, token string, transport http.RoundTripper) (int64, error) {
+	gitToken := os.Getenv("GIT_TOKEN")
+	if gitToken == "" {
+		return 0, fmt.Errorf("GIT_TOKEN must be set to manage %s webhooks", binding.Spec.Provider)
+	}
+
+	switch binding.Spec.Provider {
+	case "github":
+		gh, err := githubWrapper.NewGitHubClient(gitToken, transport)
+		if err != nil {
+			return 0, err
+		}
+		return gh.CreateRepositoryWebhook(githubWrapper.RepositoryHookRequest{
+			Org:        binding.Spec.Owner,
+			Repository: binding.Spec.Repository,
+			Url:        url,
+			Token:      token,
+			Options:    hooks.Options{Events: binding.Spec.Events},
+		})
+	case "gitlab":
+		gitlabClient, err := gitlabWrapper.NewGitLabClient(gitToken, binding.Spec.Owner, transport)
+		if err != nil {
+			return 0, err
+		}
+		opts, err := hooks.Options{Events: binding.Spec.Events}.GitLabHookOptions(url, token)
+		if err != nil {
+			return 0, err
+		}
+		hookID, err := gitlabClient.CreateProjectWebhook(&gitlabWrapper.ProjectHookRequest{
+			ProjectName: binding.Spec.Repository,
+			CreateOpts:  opts,
+		})
+		return int64(hookID), err
+	default:
+		return 0, fmt.Errorf("unsupported provider %s", binding.Spec.Provider)
+	}
+}
+
+// deleteHook removes a remote hook by ID
+func deleteHook(provider string, owner string, repository string, hookID int64, transport http.RoundTripper) error {
+	gitToken := os.Getenv("GIT_TOKEN")
+	if gitToken == "" {
+		return fmt.Errorf("GIT_TOKEN must be set to manage %s webhooks", provider)
+	}
+
+	switch provider {
+	case "github":
+		gh, err := githubWrapper.NewGitHubClient(gitToken, transport)
+		if err != nil {
+			return err
+		}
+		return gh.DeleteRepositoryWebhookByID(owner, repository, hookID)
+	case "gitlab":
+		gitlabClient, err := gitlabWrapper.NewGitLabClient(gitToken, owner, transport)
+		if err != nil {
+			return err
+		}
+		return gitlabClient.DeleteProjectWebhookByID(repository, int(hookID))
+	default:
+		return fmt.Errorf("unsupported provider %s", provider)
+	}
+}
+
+// updateHook points an existing remote hook at url with the token and events
+// of a binding
+func updateHook(binding *WebhookBinding, hookID int64, url string, token string, transport http.RoundTripper) error {
+	gitToken := os.Getenv("GIT_TOKEN")
+	if gitToken == "" {
+		return fmt.Errorf("GIT_TOKEN must be set to manage %s webhooks", binding.Spec.Provider)
+	}
+
+	switch binding.Spec.Provider {
+	case "github":
+		gh, err := githubWrapper.NewGitHubClient(gitToken, transport)
+		if err != nil {
+			return err
+		}
+		return gh.UpdateRepositoryWebhookByID(githubWrapper.RepositoryHookRequest{
+			Org:        binding.Spec.Owner,
+			Repository: binding.Spec.Repository,
+			Url:        url,
+			Token:      token,
+			Options:    hooks.Options{Events: binding.Spec.Events},
+		}, hookID)
+	case "gitlab":
+		gitlabClient, err := gitlabWrapper.NewGitLabClient(gitToken, binding.Spec.Owner, transport)
+		if err != nil {
+			return err
+		}
+		opts, err := hooks.Options{Events: binding.Spec.Events}.GitLabEditHookOptions(url, token)
+		if err != nil {
+			return err
+		}
+		return gitlabClient.UpdateProjectWebhookByID(binding.Spec.Repository, int(hookID), opts)
+	default:
+		return fmt.Errorf("unsupported provider %s", binding.Spec.Provider)
+	}
+}
+
+// findHook returns the ID of a remote hook on the binding repository that
+// delivers to url, preferring prefer, or 0 if there is none
+func findHook(binding *WebhookBinding, url string, prefer int64, transport http.RoundTripper) (int64, error) {
+	gitToken := os.Getenv("GIT_TOKEN")
+	if gitToken == "" {
+		return 0, fmt.Errorf("GIT_TOKEN must be set to manage %s webhooks", binding.Spec.Provider)
+	}
+
+	switch binding.Spec.Provider {
+	case "github":
+		gh, err := githubWrapper.NewGitHubClient(gitToken, transport)
+		if err != nil {
+			return 0, err
+		}
+		hooks, err := gh.ListRepoWebhooks(binding.Spec.Owner, binding.Spec.Repository)
+		if err != nil {
+			return 0, err
+		}
+		hookIDs := make([]int64, 0)
+		for _, hook := range hooks {
+			if hookURL, _ := hook.Config["url"].(string); hookURL == url {
+				hookIDs = append(hookIDs, hook.GetID())
+			}
+		}
+		return preferredHook(hookIDs, prefer), nil
+	case "gitlab":
+		gitlabClient, err := gitlabWrapper.NewGitLabClient(gitToken, binding.Spec.Owner, transport)
+		if err != nil {
+			return 0, err
+		}
+		projectID, err := gitlabClient.GetProjectID(binding.Spec.Repository)
+		if err != nil {
+			return 0, err
+		}
+		hooks, err := gitlabClient.ListProjectWebhooks(projectID)
+		if err != nil {
+			return 0, err
+		}
+		hookIDs := make([]int64, 0)
+		for _, hook := range hooks {
+			if hook.URL == url {
+				hookIDs = append(hookIDs, int64(hook.ID))
+			}
+		}
+		return preferredHook(hookIDs, prefer), nil
+	default:
+		return 0, fmt.Errorf("unsupported provider %s", binding.Spec.Provider)
+	}
+}
+
+// preferredHook returns prefer if it is one of hookIDs, otherwise the first
+// of them, or 0 if there are none
+func preferredHook(hookIDs []int64, prefer int64) int64 {
+	for _, hookID := range hookIDs {
+		if hookID == prefer {
+			return hookID
+		}
+	}
+	if len(hookIDs) == 0 {
+		return 0
+	}
+	return hookIDs[0]
+}
@@ -0,0 +1,120 @@
+package controller
+
+import (
+	"net/http"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+const (
+	// Group is the API group of the WebhookBinding custom resource
+	Group string = "git-helper.kubefirst.io"
+	// Version is the API version of the WebhookBinding custom resource
+	Version string = "v1alpha1"
+	// Kind is the kind of the WebhookBinding custom resource
+	Kind string = "WebhookBinding"
+
+	// finalizer blocks deletion of a WebhookBinding until its remote hook is removed
+	finalizer string = "git-helper.kubefirst.io/webhook"
+
+	// ConditionReady reports whether the remote hook matches the binding spec
+	ConditionReady string = "Ready"
+)
+
+// Resource identifies WebhookBindings for the dynamic client
+var Resource schema.GroupVersionResource = schema.GroupVersionResource{
+	Group:    Group,
+	Version:  Version,
+	Resource: "webhookbindings",
+}
+
+// WebhookBinding declares a repository webhook that the controller keeps
+// pointed at a public url
+type WebhookBinding struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   WebhookBindingSpec   `json:"spec"`
+	Status WebhookBindingStatus `json:"status,omitempty"`
+}
+
+// WebhookBindingSpec describes the desired webhook
+type WebhookBindingSpec struct {
+	// Provider is one of github or gitlab
+	Provider string `json:"provider"`
+	// Owner is the organization or parent group of the repository
+	Owner string `json:"owner"`
+	// Repository is the repository or project name
+	Repository string `json:"repository"`
+	// URLSource describes where the public url of the webhook comes from
+	URLSource URLSource `json:"urlSource"`
+	// SecretRef points at the Secret key holding the webhook token
+	SecretRef *SecretReference `json:"secretRef,omitempty"`
+	// Events to subscribe the webhook to, as neutral or provider event names,
+	// defaulting to the events Atlantis needs
+	Events []string `json:"events,omitempty"`
+}
+
+// URLSource describes where the public url of a webhook comes from
+type URLSource struct {
+	// Type is one of tunnel, ingress, httproute, service or static
+	Type string `json:"type"`
+	// Name of the Ingress, HTTPRoute or Service, or of the ngrok tunnel
+	Name string `json:"name,omitempty"`
+	// Namespace of the Ingress, HTTPRoute or Service, or of the ngrok
+	// Service, defaulting to the namespace of the binding
+	Namespace string `json:"namespace,omitempty"`
+	// APIURL is the ngrok agent tunnels api when using the tunnel type,
+	// defaulting to the ngrok Service in Namespace
+	APIURL string `json:"apiURL,omitempty"`
+	// URL is the public url when using the static type
+	URL string `json:"url,omitempty"`
+	// Path is appended to the public url, defaulting to /events
+	Path *string `json:"path,omitempty"`
+}
+
+// SecretReference points at a key in a Secret in the binding namespace
+type SecretReference struct {
+	Name string `json:"name"`
+	Key  string `json:"key"`
+}
+
+// WebhookBindingStatus reports the state of the remote webhook
+type WebhookBindingStatus struct {
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+	// HookID is the ID of the remote hook managed for this binding
+	HookID int64 `json:"hookID,omitempty"`
+	// Provider, Owner and Repository record where the hook was created so it
+	// can be removed even after the spec changes
+	Provider   string `json:"provider,omitempty"`
+	Owner      string `json:"owner,omitempty"`
+	Repository string `json:"repository,omitempty"`
+	// LastSyncedURL is the url the remote hook currently points at
+	LastSyncedURL string `json:"lastSyncedURL,omitempty"`
+	// SecretHash is a hash of the token the remote hook was last synced
+	// with, so a rotated Secret is applied
+	SecretHash string             `json:"secretHash,omitempty"`
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// Options holds values used to run the controller
+type Options struct {
+	// Namespace limits the controller to a single namespace, all if empty
+	Namespace string
+	// Workers is the number of bindings reconciled in parallel
+	Workers int
+	// ResyncPeriod is how often every binding is reconciled again, which
+	// picks up public url changes
+	ResyncPeriod time.Duration
+	// Lock holds the sync Lease while replacing a hook, so the controller and
+	// sync runs using --lock do not replace the same hook at once
+	Lock          bool
+	LockName      string
+	LockNamespace string
+	LockTimeout   time.Duration
+	// Transport carries GitHub and GitLab api requests, http.DefaultTransport
+	// if nil
+	Transport http.RoundTripper
+}
@@ -0,0 +1,112 @@
+package fakeforge
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/kubefirst/git-helper/internal/receiver"
+)
+
+// deliveryRequest describes an event to send to a hook
+type deliveryRequest struct {
+	provider   string
+	event      string
+	guid       string
+	payload    []byte
+	redelivery bool
+}
+
+// deliver sends an event to a hook the way the provider would and records
+// the delivery on the hook
+// It must be called without holding s.mu
+func (s *Server) deliver(hook *Hook, req deliveryRequest) Delivery {
+	s.mu.Lock()
+	target, secret, contentType := hook.URL, hook.Secret, hook.ContentType
+	s.mu.Unlock()
+
+	if req.guid == "" {
+		req.guid = uuid.New().String()
+	}
+
+	headers := map[string]string{
+		"Content-Type": "application/json",
+	}
+	body := req.payload
+	switch req.provider {
+	case "github":
+		headers["User-Agent"] = "GitHub-Hookshot/fakeforge"
+		headers["X-GitHub-Event"] = req.event
+		headers["X-GitHub-Delivery"] = req.guid
+		headers["X-GitHub-Hook-ID"] = formatID(hook.ID)
+		if contentType == "form" {
+			headers["Content-Type"] = "application/x-www-form-urlencoded"
+			body = []byte(url.Values{"payload": {string(req.payload)}}.Encode())
+		}
+		if secret != "" {
+			headers[receiver.HeaderGitHubSignature] = receiver.SignGitHub(secret, body)
+		}
+	case "gitlab":
+		headers["User-Agent"] = "GitLab/fakeforge"
+		headers["X-Gitlab-Event"] = req.event
+		headers["X-Gitlab-Event-UUID"] = req.guid
+		if secret != "" {
+			headers["X-Gitlab-Token"] = secret
+		}
+	}
+
+	delivery := Delivery{
+		GUID:        req.guid,
+		Event:       req.event,
+		DeliveredAt: time.Now().UTC(),
+		Redelivery:  req.redelivery,
+		Request:     DeliveryMessage{Headers: headers, Payload: string(req.payload)},
+	}
+
+	started := time.Now()
+	httpReq, err := http.NewRequest(http.MethodPost, target, bytes.NewReader(body))
+	if err == nil {
+		for key, value := range headers {
+			httpReq.Header.Set(key, value)
+		}
+		var resp *http.Response
+		resp, err = s.client.Do(httpReq)
+		if err == nil {
+			defer resp.Body.Close()
+			respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+			delivery.StatusCode = resp.StatusCode
+			delivery.Status = http.StatusText(resp.StatusCode)
+			delivery.Response = DeliveryMessage{Headers: flattenHeaders(resp.Header), Payload: string(respBody)}
+		}
+	}
+	if err != nil {
+		delivery.Status = fmt.Sprintf("failed to connect: %s", err)
+	}
+	delivery.Duration = time.Since(started).Seconds()
+
+	s.mu.Lock()
+	delivery.ID = s.id()
+	hook.Deliveries = append(hook.Deliveries, delivery)
+	s.mu.Unlock()
+
+	return delivery
+}
+
+// flattenHeaders keeps the first value of each header
+func flattenHeaders(header http.Header) map[string]string {
+	flat := make(map[string]string, len(header))
+	for key := range header {
+		flat[key] = header.Get(key)
+	}
+	return flat
+}
+
+// formatID renders an object ID as used in api paths
+func formatID(id int64) string {
+	return strconv.FormatInt(id, 10)
+}
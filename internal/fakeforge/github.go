@@ -0,0 +1,424 @@
+package fakeforge
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// githubHookRequest is the body of a GitHub hook create or edit request
+type githubHookRequest struct {
+	Config map[string]interface{} `json:"config"`
+	Events []string               `json:"events"`
+	Active *bool                  `json:"active"`
+}
+
+// serveGitHub routes requests to the fake GitHub api
+func (s *Server) serveGitHub(w http.ResponseWriter, r *http.Request) {
+	segments := pathSegments(r, GitHubPrefix)
+
+	if params, ok := match(segments, "orgs", "*", "repos"); ok && r.Method == http.MethodGet {
+		s.githubListRepos(w, params[0], true)
+		return
+	}
+	if params, ok := match(segments, "users", "*", "repos"); ok && r.Method == http.MethodGet {
+		s.githubListRepos(w, params[0], false)
+		return
+	}
+	if params, ok := match(segments, "orgs", "*", "teams", "*", "repos"); ok && r.Method == http.MethodGet {
+		s.githubListTeamRepos(w, params[0], params[1])
+		return
+	}
+	if params, ok := match(segments, "repos", "*", "*", "hooks"); ok {
+		switch r.Method {
+		case http.MethodGet:
+			s.githubListHooks(w, params[0], params[1])
+		case http.MethodPost:
+			s.githubCreateHook(w, r, params[0], params[1])
+		default:
+			writeError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+		}
+		return
+	}
+	if params, ok := match(segments, "repos", "*", "*", "hooks", "*"); ok {
+		switch r.Method {
+		case http.MethodGet:
+			s.githubGetHook(w, params[0], params[1], params[2])
+		case http.MethodPatch:
+			s.githubEditHook(w, r, params[0], params[1], params[2])
+		case http.MethodDelete:
+			s.githubDeleteHook(w, params[0], params[1], params[2])
+		default:
+			writeError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+		}
+		return
+	}
+	if params, ok := match(segments, "repos", "*", "*", "hooks", "*", "pings"); ok && r.Method == http.MethodPost {
+		s.githubPingHook(w, params[0], params[1], params[2])
+		return
+	}
+	if params, ok := match(segments, "repos", "*", "*", "hooks", "*", "deliveries"); ok && r.Method == http.MethodGet {
+		s.githubListDeliveries(w, params[0], params[1], params[2])
+		return
+	}
+	if params, ok := match(segments, "repos", "*", "*", "hooks", "*", "deliveries", "*"); ok && r.Method == http.MethodGet {
+		s.githubGetDelivery(w, params[0], params[1], params[2], params[3])
+		return
+	}
+	if params, ok := match(segments, "repos", "*", "*", "hooks", "*", "deliveries", "*", "attempts"); ok && r.Method == http.MethodPost {
+		s.githubRedeliver(w, params[0], params[1], params[2], params[3])
+		return
+	}
+
+	writeError(w, http.StatusNotFound, "Not Found")
+}
+
+func (s *Server) githubListRepos(w http.ResponseWriter, login string, org bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	owner, ok := s.githubOwners[strings.ToLower(login)]
+	if !ok || owner.org != org {
+		writeError(w, http.StatusNotFound, "Not Found")
+		return
+	}
+	repos := make([]map[string]interface{}, 0, len(owner.repos))
+	for _, repo := range owner.repos {
+		repos = append(repos, githubRepoJSON(owner, repo))
+	}
+	writeJSON(w, http.StatusOK, repos)
+}
+
+func (s *Server) githubListTeamRepos(w http.ResponseWriter, org string, team string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	owner, ok := s.githubOwners[strings.ToLower(org)]
+	if !ok {
+		writeError(w, http.StatusNotFound, "Not Found")
+		return
+	}
+	names, ok := owner.teams[team]
+	if !ok {
+		writeError(w, http.StatusNotFound, "Not Found")
+		return
+	}
+	repos := make([]map[string]interface{}, 0, len(names))
+	for _, name := range names {
+		if repo, ok := owner.repos[strings.ToLower(name)]; ok {
+			repos = append(repos, githubRepoJSON(owner, repo))
+		}
+	}
+	writeJSON(w, http.StatusOK, repos)
+}
+
+func (s *Server) githubListHooks(w http.ResponseWriter, owner string, name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	repo := s.githubRepo(owner, name)
+	if repo == nil {
+		writeError(w, http.StatusNotFound, "Not Found")
+		return
+	}
+	hooks := make([]map[string]interface{}, 0, len(repo.hooks))
+	for _, hook := range repo.hooks {
+		hooks = append(hooks, githubHookJSON(hook))
+	}
+	writeJSON(w, http.StatusOK, hooks)
+}
+
+func (s *Server) githubCreateHook(w http.ResponseWriter, r *http.Request, owner string, name string) {
+	var body githubHookRequest
+	err := json.NewDecoder(r.Body).Decode(&body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("Problems parsing JSON: %s", err))
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	repo := s.githubRepo(owner, name)
+	if repo == nil {
+		writeError(w, http.StatusNotFound, "Not Found")
+		return
+	}
+	now := time.Now().UTC()
+	hook := &Hook{
+		ID:          s.id(),
+		Events:      []string{"push"},
+		ContentType: "form",
+		Active:      true,
+		CreatedAt:   now,
+	}
+	err = applyGitHubHookRequest(hook, body)
+	if err != nil {
+		writeError(w, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+	// GitHub allows a single hook per url on a repository
+	for _, existing := range repo.hooks {
+		if existing.URL == hook.URL {
+			writeJSON(w, http.StatusUnprocessableEntity, map[string]interface{}{
+				"message": "Validation Failed",
+				"errors": []map[string]string{
+					{"resource": "Hook", "code": "custom", "message": "Hook already exists on this repository"},
+				},
+			})
+			return
+		}
+	}
+	hook.UpdatedAt = now
+	repo.hooks = append(repo.hooks, hook)
+	writeJSON(w, http.StatusCreated, githubHookJSON(hook))
+}
+
+func (s *Server) githubGetHook(w http.ResponseWriter, owner string, name string, id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	hook := s.githubHook(owner, name, id)
+	if hook == nil {
+		writeError(w, http.StatusNotFound, "Not Found")
+		return
+	}
+	writeJSON(w, http.StatusOK, githubHookJSON(hook))
+}
+
+func (s *Server) githubEditHook(w http.ResponseWriter, r *http.Request, owner string, name string, id string) {
+	var body githubHookRequest
+	err := json.NewDecoder(r.Body).Decode(&body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("Problems parsing JSON: %s", err))
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	hook := s.githubHook(owner, name, id)
+	if hook == nil {
+		writeError(w, http.StatusNotFound, "Not Found")
+		return
+	}
+	err = applyGitHubHookRequest(hook, body)
+	if err != nil {
+		writeError(w, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+	hook.UpdatedAt = time.Now().UTC()
+	writeJSON(w, http.StatusOK, githubHookJSON(hook))
+}
+
+func (s *Server) githubDeleteHook(w http.ResponseWriter, owner string, name string, id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	repo := s.githubRepo(owner, name)
+	if repo == nil {
+		writeError(w, http.StatusNotFound, "Not Found")
+		return
+	}
+	_, index := findHook(repo.hooks, id)
+	if index < 0 {
+		writeError(w, http.StatusNotFound, "Not Found")
+		return
+	}
+	repo.hooks = append(repo.hooks[:index], repo.hooks[index+1:]...)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) githubPingHook(w http.ResponseWriter, owner string, name string, id string) {
+	s.mu.Lock()
+	hook := s.githubHook(owner, name, id)
+	var payload []byte
+	if hook != nil {
+		payload, _ = json.Marshal(map[string]interface{}{
+			"zen":        "Keep it logically awesome.",
+			"hook_id":    hook.ID,
+			"hook":       githubHookJSON(hook),
+			"repository": map[string]interface{}{"full_name": owner + "/" + name},
+		})
+	}
+	s.mu.Unlock()
+
+	if hook == nil {
+		writeError(w, http.StatusNotFound, "Not Found")
+		return
+	}
+	s.deliver(hook, deliveryRequest{provider: "github", event: "ping", payload: payload})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) githubListDeliveries(w http.ResponseWriter, owner string, name string, id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	hook := s.githubHook(owner, name, id)
+	if hook == nil {
+		writeError(w, http.StatusNotFound, "Not Found")
+		return
+	}
+	// Most recent first, like GitHub
+	deliveries := make([]map[string]interface{}, 0, len(hook.Deliveries))
+	for i := len(hook.Deliveries) - 1; i >= 0; i-- {
+		deliveries = append(deliveries, githubDeliveryJSON(hook.Deliveries[i], false))
+	}
+	writeJSON(w, http.StatusOK, deliveries)
+}
+
+func (s *Server) githubGetDelivery(w http.ResponseWriter, owner string, name string, id string, deliveryID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	hook := s.githubHook(owner, name, id)
+	if hook == nil {
+		writeError(w, http.StatusNotFound, "Not Found")
+		return
+	}
+	for _, delivery := range hook.Deliveries {
+		if formatID(delivery.ID) == deliveryID {
+			writeJSON(w, http.StatusOK, githubDeliveryJSON(delivery, true))
+			return
+		}
+	}
+	writeError(w, http.StatusNotFound, "Not Found")
+}
+
+func (s *Server) githubRedeliver(w http.ResponseWriter, owner string, name string, id string, deliveryID string) {
+	s.mu.Lock()
+	hook := s.githubHook(owner, name, id)
+	var original *Delivery
+	if hook != nil {
+		for i := range hook.Deliveries {
+			if formatID(hook.Deliveries[i].ID) == deliveryID {
+				delivery := hook.Deliveries[i]
+				original = &delivery
+			}
+		}
+	}
+	s.mu.Unlock()
+
+	if original == nil {
+		writeError(w, http.StatusNotFound, "Not Found")
+		return
+	}
+	s.deliver(hook, deliveryRequest{
+		provider:   "github",
+		event:      original.Event,
+		guid:       original.GUID,
+		payload:    []byte(original.Request.Payload),
+		redelivery: true,
+	})
+	writeJSON(w, http.StatusAccepted, map[string]interface{}{})
+}
+
+// githubHook returns a hook of a repository or nil
+// Callers must hold s.mu
+func (s *Server) githubHook(owner string, name string, id string) *Hook {
+	repo := s.githubRepo(owner, name)
+	if repo == nil {
+		return nil
+	}
+	hook, _ := findHook(repo.hooks, id)
+	return hook
+}
+
+// applyGitHubHookRequest updates a hook from a create or edit request
+func applyGitHubHookRequest(hook *Hook, body githubHookRequest) error {
+	if body.Config != nil {
+		target, _ := body.Config["url"].(string)
+		if target == "" {
+			return fmt.Errorf("Validation Failed: url cannot be blank")
+		}
+		hook.URL = target
+		if secret, ok := body.Config["secret"].(string); ok {
+			hook.Secret = secret
+		}
+		if contentType, ok := body.Config["content_type"].(string); ok && contentType != "" {
+			hook.ContentType = contentType
+		}
+		switch insecure := body.Config["insecure_ssl"].(type) {
+		case string:
+			hook.InsecureSSL = insecure == "1"
+		case float64:
+			hook.InsecureSSL = insecure == 1
+		}
+	}
+	if len(body.Events) > 0 {
+		hook.Events = body.Events
+	}
+	if body.Active != nil {
+		hook.Active = *body.Active
+	}
+	if hook.URL == "" {
+		return fmt.Errorf("Validation Failed: config is required")
+	}
+	return nil
+}
+
+func githubRepoJSON(owner *githubOwner, repo *githubRepo) map[string]interface{} {
+	ownerType := "User"
+	if owner.org {
+		ownerType = "Organization"
+	}
+	return map[string]interface{}{
+		"id":        repo.id,
+		"name":      repo.name,
+		"full_name": owner.login + "/" + repo.name,
+		"topics":    repo.topics,
+		"archived":  repo.archived,
+		"owner":     map[string]interface{}{"login": owner.login, "type": ownerType},
+	}
+}
+
+func githubHookJSON(hook *Hook) map[string]interface{} {
+	insecureSSL := "0"
+	if hook.InsecureSSL {
+		insecureSSL = "1"
+	}
+	config := map[string]interface{}{
+		"url":          hook.URL,
+		"content_type": hook.ContentType,
+		"insecure_ssl": insecureSSL,
+	}
+	if hook.Secret != "" {
+		config["secret"] = "********"
+	}
+	return map[string]interface{}{
+		"id":         hook.ID,
+		"type":       "Repository",
+		"name":       "web",
+		"active":     hook.Active,
+		"events":     hook.Events,
+		"config":     config,
+		"created_at": hook.CreatedAt,
+		"updated_at": hook.UpdatedAt,
+	}
+}
+
+func githubDeliveryJSON(delivery Delivery, detail bool) map[string]interface{} {
+	result := map[string]interface{}{
+		"id":           delivery.ID,
+		"guid":         delivery.GUID,
+		"delivered_at": delivery.DeliveredAt,
+		"redelivery":   delivery.Redelivery,
+		"duration":     delivery.Duration,
+		"status":       delivery.Status,
+		"status_code":  delivery.StatusCode,
+		"event":        delivery.Event,
+	}
+	if detail {
+		request := json.RawMessage("{}")
+		if json.Valid([]byte(delivery.Request.Payload)) {
+			request = json.RawMessage(delivery.Request.Payload)
+		}
+		response, _ := json.Marshal(delivery.Response.Payload)
+		result["request"] = map[string]interface{}{"headers": delivery.Request.Headers, "payload": request}
+		result["response"] = map[string]interface{}{"headers": delivery.Response.Headers, "payload": json.RawMessage(response)}
+	}
+	return result
+}
@@ -0,0 +1,210 @@
+package fakeforge
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/kubefirst/git-helper/internal/receiver"
+)
+
+func TestServeGitHub(t *testing.T) {
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		body       interface{}
+		wantStatus int
+		wantHooks  int
+	}{
+		{
+			name:       "If the organization exists, should list its repositories",
+			method:     http.MethodGet,
+			path:       "orgs/kubefirst/repos",
+			wantStatus: http.StatusOK,
+			wantHooks:  1,
+		},
+		{
+			name:       "If a user is listed as an organization, should answer 404",
+			method:     http.MethodGet,
+			path:       "orgs/octocat/repos",
+			wantStatus: http.StatusNotFound,
+			wantHooks:  1,
+		},
+		{
+			name:       "If the team exists, should list its repositories",
+			method:     http.MethodGet,
+			path:       "orgs/kubefirst/teams/platform/repos",
+			wantStatus: http.StatusOK,
+			wantHooks:  1,
+		},
+		{
+			name:       "If a hook is created with a url, should add it",
+			method:     http.MethodPost,
+			path:       "repos/kubefirst/gitops/hooks",
+			body:       map[string]interface{}{"config": map[string]interface{}{"url": "https://new.ngrok.io/events"}},
+			wantStatus: http.StatusCreated,
+			wantHooks:  2,
+		},
+		{
+			name:       "If a hook already delivers to the url, should answer 422",
+			method:     http.MethodPost,
+			path:       "repos/kubefirst/gitops/hooks",
+			body:       map[string]interface{}{"config": map[string]interface{}{"url": "https://old.ngrok.io/events"}},
+			wantStatus: http.StatusUnprocessableEntity,
+			wantHooks:  1,
+		},
+		{
+			name:       "If a hook is created without a url, should answer 422",
+			method:     http.MethodPost,
+			path:       "repos/kubefirst/gitops/hooks",
+			body:       map[string]interface{}{"config": map[string]interface{}{}},
+			wantStatus: http.StatusUnprocessableEntity,
+			wantHooks:  1,
+		},
+		{
+			name:       "If the repository does not exist, should answer 404",
+			method:     http.MethodPost,
+			path:       "repos/kubefirst/missing/hooks",
+			body:       map[string]interface{}{"config": map[string]interface{}{"url": "https://new.ngrok.io/events"}},
+			wantStatus: http.StatusNotFound,
+			wantHooks:  1,
+		},
+		{
+			name:       "If the hook exists, should delete it",
+			method:     http.MethodDelete,
+			path:       "repos/kubefirst/gitops/hooks/{hook}",
+			wantStatus: http.StatusNoContent,
+			wantHooks:  0,
+		},
+		{
+			name:       "If the hook does not exist, should answer 404 on delete",
+			method:     http.MethodDelete,
+			path:       "repos/kubefirst/gitops/hooks/1",
+			wantStatus: http.StatusNotFound,
+			wantHooks:  1,
+		},
+		{
+			name:       "If the method is not supported, should answer 405",
+			method:     http.MethodPut,
+			path:       "repos/kubefirst/gitops/hooks",
+			wantStatus: http.StatusMethodNotAllowed,
+			wantHooks:  1,
+		},
+		{
+			name:       "If the path is unknown, should answer 404",
+			method:     http.MethodGet,
+			path:       "repos/kubefirst/gitops/branches",
+			wantStatus: http.StatusNotFound,
+			wantHooks:  1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			forge := New()
+			forge.AddGitHubRepository("kubefirst", "gitops")
+			forge.AddGitHubUserRepository("octocat", "hello-world")
+			forge.AddGitHubTeam("kubefirst", "platform", "gitops")
+			server := httptest.NewServer(forge.Handler())
+			defer server.Close()
+
+			var hook map[string]interface{}
+			status := request(t, server, http.MethodPost, GitHubPrefix+"repos/kubefirst/gitops/hooks", map[string]interface{}{
+				"config": map[string]interface{}{"url": "https://old.ngrok.io/events"},
+			}, &hook)
+			if status != http.StatusCreated {
+				t.Fatalf("create status = %d, want %d", status, http.StatusCreated)
+			}
+
+			status = request(t, server, tt.method, GitHubPrefix+withHookID(tt.path, int64(hook["id"].(float64))), tt.body, nil)
+			if status != tt.wantStatus {
+				t.Errorf("status = %d, want %d", status, tt.wantStatus)
+			}
+			if got := len(forge.GitHubHooks("kubefirst", "gitops")); got != tt.wantHooks {
+				t.Errorf("hooks = %d, want %d", got, tt.wantHooks)
+			}
+		})
+	}
+}
+
+func TestGitHubHookDeliveries(t *testing.T) {
+	tests := []struct {
+		name           string
+		receiverStatus int
+	}{
+		{
+			name:           "If the hook url accepts the ping, should record a successful delivery",
+			receiverStatus: http.StatusOK,
+		},
+		{
+			name:           "If the hook url rejects the ping, should record the failed status",
+			receiverStatus: http.StatusBadGateway,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			target, headers := newTarget(t, tt.receiverStatus)
+			forge := New()
+			forge.AddGitHubRepository("kubefirst", "gitops")
+			server := httptest.NewServer(forge.Handler())
+			defer server.Close()
+
+			var hook struct {
+				ID     int64                  `json:"id"`
+				Config map[string]interface{} `json:"config"`
+			}
+			request(t, server, http.MethodPost, GitHubPrefix+"repos/kubefirst/gitops/hooks", map[string]interface{}{
+				"config": map[string]interface{}{"url": target.URL, "secret": "secret", "content_type": "json"},
+				"events": []string{"push", "pull_request"},
+			}, &hook)
+			if hook.Config["secret"] != "********" {
+				t.Errorf("hook secret = %v, want it masked", hook.Config["secret"])
+			}
+
+			hooksPath := fmt.Sprintf("%srepos/kubefirst/gitops/hooks/%d", GitHubPrefix, hook.ID)
+			status := request(t, server, http.MethodPost, hooksPath+"/pings", nil, nil)
+			if status != http.StatusNoContent {
+				t.Fatalf("ping status = %d, want %d", status, http.StatusNoContent)
+			}
+			if len(*headers) != 1 {
+				t.Fatalf("deliveries received = %d, want 1", len(*headers))
+			}
+			received := (*headers)[0]
+			if received.Get("X-GitHub-Event") != "ping" || received.Get(receiver.HeaderGitHubSignature) == "" {
+				t.Errorf("delivery headers = %v, want a signed ping", received)
+			}
+
+			var deliveries []struct {
+				ID         int64  `json:"id"`
+				GUID       string `json:"guid"`
+				Event      string `json:"event"`
+				StatusCode int    `json:"status_code"`
+				Redelivery bool   `json:"redelivery"`
+			}
+			request(t, server, http.MethodGet, hooksPath+"/deliveries", nil, &deliveries)
+			if len(deliveries) != 1 || deliveries[0].StatusCode != tt.receiverStatus || deliveries[0].Event != "ping" {
+				t.Fatalf("deliveries = %+v, want one ping with status %d", deliveries, tt.receiverStatus)
+			}
+			if deliveries[0].GUID != received.Get("X-GitHub-Delivery") {
+				t.Errorf("delivery guid = %s, want %s", deliveries[0].GUID, received.Get("X-GitHub-Delivery"))
+			}
+
+			status = request(t, server, http.MethodPost, fmt.Sprintf("%s/deliveries/%d/attempts", hooksPath, deliveries[0].ID), nil, nil)
+			if status != http.StatusAccepted {
+				t.Fatalf("redeliver status = %d, want %d", status, http.StatusAccepted)
+			}
+			request(t, server, http.MethodGet, hooksPath+"/deliveries", nil, &deliveries)
+			if len(deliveries) != 2 || !deliveries[0].Redelivery || deliveries[0].GUID != deliveries[1].GUID {
+				t.Errorf("deliveries = %+v, want the redelivery first with the same guid", deliveries)
+			}
+
+			status = request(t, server, http.MethodGet, hooksPath+"/deliveries/1", nil, nil)
+			if status != http.StatusNotFound {
+				t.Errorf("unknown delivery status = %d, want %d", status, http.StatusNotFound)
+			}
+		})
+	}
+}
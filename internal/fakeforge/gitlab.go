@@ -0,0 +1,483 @@
+package fakeforge
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kubefirst/git-helper/internal/common"
+)
+
+// gitlabHookEvents maps the event fields of a GitLab hook to the value of
+// the X-Gitlab-Event header sent for them
+var gitlabHookEvents map[string]string = map[string]string{
+	"push_events":                "Push Hook",
+	"tag_push_events":            "Tag Push Hook",
+	"merge_requests_events":      "Merge Request Hook",
+	"note_events":                "Note Hook",
+	"confidential_note_events":   "Confidential Note Hook",
+	"issues_events":              "Issue Hook",
+	"confidential_issues_events": "Confidential Issue Hook",
+	"pipeline_events":            "Pipeline Hook",
+	"job_events":                 "Job Hook",
+	"releases_events":            "Release Hook",
+	"deployment_events":          "Deployment Hook",
+	"wiki_page_events":           "Wiki Page Hook",
+}
+
+// serveGitLab routes requests to the fake GitLab api
+func (s *Server) serveGitLab(w http.ResponseWriter, r *http.Request) {
+	segments := pathSegments(r, gitlabAPIPrefix)
+
+	if params, ok := match(segments, "namespaces", "*"); ok && r.Method == http.MethodGet {
+		s.gitlabGetNamespace(w, params[0])
+		return
+	}
+	if params, ok := match(segments, "groups", "*", "projects"); ok && r.Method == http.MethodGet {
+		s.gitlabListGroupProjects(w, r, params[0])
+		return
+	}
+	if params, ok := match(segments, "users", "*", "projects"); ok && r.Method == http.MethodGet {
+		s.gitlabListUserProjects(w, r, params[0])
+		return
+	}
+	if params, ok := match(segments, "projects", "*"); ok && r.Method == http.MethodGet {
+		s.gitlabGetProject(w, params[0])
+		return
+	}
+	if params, ok := match(segments, "projects", "*", "hooks"); ok {
+		switch r.Method {
+		case http.MethodGet:
+			s.gitlabListHooks(w, params[0])
+		case http.MethodPost:
+			s.gitlabCreateHook(w, r, params[0])
+		default:
+			writeError(w, http.StatusMethodNotAllowed, "405 Method Not Allowed")
+		}
+		return
+	}
+	if params, ok := match(segments, "projects", "*", "hooks", "*"); ok {
+		switch r.Method {
+		case http.MethodGet:
+			s.gitlabGetHook(w, params[0], params[1])
+		case http.MethodPut:
+			s.gitlabEditHook(w, r, params[0], params[1])
+		case http.MethodDelete:
+			s.gitlabDeleteHook(w, params[0], params[1])
+		default:
+			writeError(w, http.StatusMethodNotAllowed, "405 Method Not Allowed")
+		}
+		return
+	}
+	if params, ok := match(segments, "projects", "*", "hooks", "*", "test", "*"); ok && r.Method == http.MethodPost {
+		s.gitlabTestHook(w, params[0], params[1], params[2])
+		return
+	}
+	if params, ok := match(segments, "projects", "*", "hooks", "*", "events"); ok && r.Method == http.MethodGet {
+		s.gitlabListHookEvents(w, params[0], params[1])
+		return
+	}
+	if params, ok := match(segments, "projects", "*", "hooks", "*", "events", "*", "resend"); ok && r.Method == http.MethodPost {
+		s.gitlabResendHookEvent(w, params[0], params[1], params[2])
+		return
+	}
+
+	writeError(w, http.StatusNotFound, "404 Not Found")
+}
+
+func (s *Server) gitlabGetNamespace(w http.ResponseWriter, id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ns := s.gitlabNamespaceByID(id)
+	if ns == nil {
+		writeError(w, http.StatusNotFound, "404 Namespace Not Found")
+		return
+	}
+	writeJSON(w, http.StatusOK, gitlabNamespaceJSON(ns))
+}
+
+func (s *Server) gitlabListGroupProjects(w http.ResponseWriter, r *http.Request, id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ns := s.gitlabNamespaceByID(id)
+	if ns == nil || ns.kind != "group" {
+		writeError(w, http.StatusNotFound, "404 Group Not Found")
+		return
+	}
+	includeSubGroups := r.URL.Query().Get("include_subgroups") == "true"
+	writeJSON(w, http.StatusOK, s.gitlabFilterProjects(r, func(project *gitlabProject) bool {
+		if project.namespace == ns {
+			return true
+		}
+		return includeSubGroups && strings.HasPrefix(strings.ToLower(project.namespace.fullPath), strings.ToLower(ns.fullPath)+"/")
+	}))
+}
+
+func (s *Server) gitlabListUserProjects(w http.ResponseWriter, r *http.Request, id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ns := s.gitlabNamespaceByID(id)
+	if ns == nil || ns.kind != "user" {
+		writeError(w, http.StatusNotFound, "404 User Not Found")
+		return
+	}
+	writeJSON(w, http.StatusOK, s.gitlabFilterProjects(r, func(project *gitlabProject) bool {
+		return project.namespace == ns
+	}))
+}
+
+func (s *Server) gitlabGetProject(w http.ResponseWriter, id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	project := s.gitlabProject(id)
+	if project == nil {
+		writeError(w, http.StatusNotFound, "404 Project Not Found")
+		return
+	}
+	writeJSON(w, http.StatusOK, gitlabProjectJSON(project))
+}
+
+func (s *Server) gitlabListHooks(w http.ResponseWriter, id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	project := s.gitlabProject(id)
+	if project == nil {
+		writeError(w, http.StatusNotFound, "404 Project Not Found")
+		return
+	}
+	hooks := make([]map[string]interface{}, 0, len(project.hooks))
+	for _, hook := range project.hooks {
+		hooks = append(hooks, gitlabHookJSON(project, hook))
+	}
+	writeJSON(w, http.StatusOK, hooks)
+}
+
+func (s *Server) gitlabCreateHook(w http.ResponseWriter, r *http.Request, id string) {
+	body := make(map[string]interface{})
+	err := json.NewDecoder(r.Body).Decode(&body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("400 Bad Request: %s", err))
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	project := s.gitlabProject(id)
+	if project == nil {
+		writeError(w, http.StatusNotFound, "404 Project Not Found")
+		return
+	}
+	now := time.Now().UTC()
+	hook := &Hook{
+		ID:          s.id(),
+		Events:      []string{"push_events"},
+		ContentType: "json",
+		Active:      true,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	err = applyGitLabHookRequest(hook, body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	project.hooks = append(project.hooks, hook)
+	writeJSON(w, http.StatusCreated, gitlabHookJSON(project, hook))
+}
+
+func (s *Server) gitlabGetHook(w http.ResponseWriter, id string, hookID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	project := s.gitlabProject(id)
+	if project == nil {
+		writeError(w, http.StatusNotFound, "404 Project Not Found")
+		return
+	}
+	hook, _ := findHook(project.hooks, hookID)
+	if hook == nil {
+		writeError(w, http.StatusNotFound, "404 Not Found")
+		return
+	}
+	writeJSON(w, http.StatusOK, gitlabHookJSON(project, hook))
+}
+
+func (s *Server) gitlabEditHook(w http.ResponseWriter, r *http.Request, id string, hookID string) {
+	body := make(map[string]interface{})
+	err := json.NewDecoder(r.Body).Decode(&body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("400 Bad Request: %s", err))
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	project := s.gitlabProject(id)
+	if project == nil {
+		writeError(w, http.StatusNotFound, "404 Project Not Found")
+		return
+	}
+	hook, _ := findHook(project.hooks, hookID)
+	if hook == nil {
+		writeError(w, http.StatusNotFound, "404 Not Found")
+		return
+	}
+	err = applyGitLabHookRequest(hook, body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	hook.UpdatedAt = time.Now().UTC()
+	writeJSON(w, http.StatusOK, gitlabHookJSON(project, hook))
+}
+
+func (s *Server) gitlabDeleteHook(w http.ResponseWriter, id string, hookID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	project := s.gitlabProject(id)
+	if project == nil {
+		writeError(w, http.StatusNotFound, "404 Project Not Found")
+		return
+	}
+	_, index := findHook(project.hooks, hookID)
+	if index < 0 {
+		writeError(w, http.StatusNotFound, "404 Not Found")
+		return
+	}
+	project.hooks = append(project.hooks[:index], project.hooks[index+1:]...)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// gitlabTestHook sends a test event to a hook, like the GitLab test hook api
+func (s *Server) gitlabTestHook(w http.ResponseWriter, id string, hookID string, trigger string) {
+	event, ok := gitlabHookEvents[trigger]
+	if !ok {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("400 Bad Request: unsupported trigger %s", trigger))
+		return
+	}
+
+	s.mu.Lock()
+	project := s.gitlabProject(id)
+	var hook *Hook
+	var payload []byte
+	if project != nil {
+		hook, _ = findHook(project.hooks, hookID)
+		payload, _ = json.Marshal(map[string]interface{}{
+			"object_kind": strings.TrimSuffix(trigger, "_events"),
+			"project":     gitlabProjectJSON(project),
+		})
+	}
+	s.mu.Unlock()
+
+	if hook == nil {
+		writeError(w, http.StatusNotFound, "404 Not Found")
+		return
+	}
+	// Like GitLab, answer 422 with the outcome if the hook did not accept it
+	delivery := s.deliver(hook, deliveryRequest{provider: "gitlab", event: event, payload: payload})
+	switch {
+	case delivery.StatusCode >= 200 && delivery.StatusCode < 300:
+		writeJSON(w, http.StatusCreated, map[string]interface{}{"message": "201 Created"})
+	case delivery.StatusCode == 0:
+		writeError(w, http.StatusUnprocessableEntity, fmt.Sprintf("Hook execution failed: %s", delivery.Status))
+	default:
+		writeError(w, http.StatusUnprocessableEntity, fmt.Sprintf("Hook executed successfully but returned HTTP %d %s", delivery.StatusCode, delivery.Status))
+	}
+}
+
+// gitlabListHookEvents lists the deliveries of a hook, most recent first
+func (s *Server) gitlabListHookEvents(w http.ResponseWriter, id string, hookID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	project := s.gitlabProject(id)
+	if project == nil {
+		writeError(w, http.StatusNotFound, "404 Project Not Found")
+		return
+	}
+	hook, _ := findHook(project.hooks, hookID)
+	if hook == nil {
+		writeError(w, http.StatusNotFound, "404 Not Found")
+		return
+	}
+	events := make([]map[string]interface{}, 0, len(hook.Deliveries))
+	for i := len(hook.Deliveries) - 1; i >= 0; i-- {
+		events = append(events, gitlabHookEventJSON(hook, hook.Deliveries[i]))
+	}
+	writeJSON(w, http.StatusOK, events)
+}
+
+// gitlabResendHookEvent delivers a recorded hook event again
+func (s *Server) gitlabResendHookEvent(w http.ResponseWriter, id string, hookID string, eventID string) {
+	s.mu.Lock()
+	project := s.gitlabProject(id)
+	var hook *Hook
+	var original *Delivery
+	if project != nil {
+		hook, _ = findHook(project.hooks, hookID)
+	}
+	if hook != nil {
+		for i := range hook.Deliveries {
+			if formatID(hook.Deliveries[i].ID) == eventID {
+				delivery := hook.Deliveries[i]
+				original = &delivery
+			}
+		}
+	}
+	s.mu.Unlock()
+
+	if original == nil {
+		writeError(w, http.StatusNotFound, "404 Not Found")
+		return
+	}
+	delivery := s.deliver(hook, deliveryRequest{
+		provider:   "gitlab",
+		event:      original.Event,
+		guid:       original.GUID,
+		payload:    []byte(original.Request.Payload),
+		redelivery: true,
+	})
+	writeJSON(w, http.StatusCreated, map[string]interface{}{"response_status": gitlabResponseStatus(delivery)})
+}
+
+// gitlabFilterProjects returns the projects accepted by include, filtered by
+// the search and topic query parameters and sorted by ID
+// Callers must hold s.mu
+func (s *Server) gitlabFilterProjects(r *http.Request, include func(project *gitlabProject) bool) []map[string]interface{} {
+	search := strings.ToLower(r.URL.Query().Get("search"))
+	topic := r.URL.Query().Get("topic")
+
+	selected := make([]*gitlabProject, 0)
+	for _, project := range s.projects {
+		if !include(project) {
+			continue
+		}
+		if search != "" && !strings.Contains(strings.ToLower(project.name), search) && !strings.Contains(project.path, search) {
+			continue
+		}
+		if topic != "" && !inSlice(project.topics, topic) {
+			continue
+		}
+		selected = append(selected, project)
+	}
+	sort.Slice(selected, func(i, j int) bool { return selected[i].id < selected[j].id })
+
+	projects := make([]map[string]interface{}, 0, len(selected))
+	for _, project := range selected {
+		projects = append(projects, gitlabProjectJSON(project))
+	}
+	return projects
+}
+
+// applyGitLabHookRequest updates a hook from a create or edit request
+func applyGitLabHookRequest(hook *Hook, body map[string]interface{}) error {
+	if target, ok := body["url"].(string); ok {
+		hook.URL = target
+	}
+	if hook.URL == "" {
+		return fmt.Errorf("400 Bad Request: url is missing")
+	}
+	if token, ok := body["token"].(string); ok {
+		hook.Secret = token
+	}
+	if verify, ok := body["enable_ssl_verification"].(bool); ok {
+		hook.InsecureSSL = !verify
+	}
+
+	enabled := make(map[string]bool)
+	for _, event := range hook.Events {
+		enabled[event] = true
+	}
+	for field := range gitlabHookEvents {
+		if value, ok := body[field].(bool); ok {
+			enabled[field] = value
+		}
+	}
+	events := make([]string, 0, len(enabled))
+	for event, on := range enabled {
+		if on {
+			events = append(events, event)
+		}
+	}
+	sort.Strings(events)
+	hook.Events = events
+	return nil
+}
+
+func gitlabNamespaceJSON(ns *gitlabNamespace) map[string]interface{} {
+	return map[string]interface{}{
+		"id":        ns.id,
+		"name":      ns.path,
+		"path":      ns.path,
+		"kind":      ns.kind,
+		"full_path": ns.fullPath,
+		"parent_id": ns.parentID,
+	}
+}
+
+func gitlabProjectJSON(project *gitlabProject) map[string]interface{} {
+	return map[string]interface{}{
+		"id":                  project.id,
+		"name":                project.name,
+		"path":                project.path,
+		"path_with_namespace": project.fullPath(),
+		"namespace":           gitlabNamespaceJSON(project.namespace),
+		"topics":              project.topics,
+	}
+}
+
+func gitlabHookJSON(project *gitlabProject, hook *Hook) map[string]interface{} {
+	result := map[string]interface{}{
+		"id":                      hook.ID,
+		"url":                     hook.URL,
+		"project_id":              project.id,
+		"enable_ssl_verification": !hook.InsecureSSL,
+		"created_at":              hook.CreatedAt,
+	}
+	for field := range gitlabHookEvents {
+		result[field] = inSlice(hook.Events, field)
+	}
+	return result
+}
+
+func gitlabHookEventJSON(hook *Hook, delivery Delivery) map[string]interface{} {
+	return map[string]interface{}{
+		"id":                 delivery.ID,
+		"url":                hook.URL,
+		"trigger":            delivery.Event,
+		"request_headers":    delivery.Request.Headers,
+		"request_data":       delivery.Request.Payload,
+		"response_headers":   delivery.Response.Headers,
+		"response_body":      delivery.Response.Payload,
+		"response_status":    gitlabResponseStatus(delivery),
+		"execution_duration": delivery.Duration,
+		"created_at":         delivery.DeliveredAt,
+	}
+}
+
+// gitlabResponseStatus is the status code of a delivery, or the connection
+// error if there was no response, as reported by GitLab
+func gitlabResponseStatus(delivery Delivery) string {
+	if delivery.StatusCode == 0 {
+		return "internal error"
+	}
+	return strconv.Itoa(delivery.StatusCode)
+}
+
+// inSlice reports whether values contains value
+func inSlice(values []string, value string) bool {
+	_, found := common.FindInSlice(values, value)
+	return found
+}
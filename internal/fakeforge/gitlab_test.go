@@ -0,0 +1,226 @@
+package fakeforge
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestServeGitLab(t *testing.T) {
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		body       interface{}
+		wantStatus int
+		wantCount  int
+		wantHooks  int
+	}{
+		{
+			name:       "If the group exists, should return its namespace by full path",
+			method:     http.MethodGet,
+			path:       "namespaces/" + url.PathEscape("kubefirst/infra"),
+			wantStatus: http.StatusOK,
+			wantHooks:  1,
+		},
+		{
+			name:       "If the namespace does not exist, should answer 404",
+			method:     http.MethodGet,
+			path:       "namespaces/missing",
+			wantStatus: http.StatusNotFound,
+			wantHooks:  1,
+		},
+		{
+			name:       "If subgroups are excluded, should list the group projects only",
+			method:     http.MethodGet,
+			path:       "groups/kubefirst/projects",
+			wantStatus: http.StatusOK,
+			wantCount:  1,
+			wantHooks:  1,
+		},
+		{
+			name:       "If subgroups are included, should list their projects too",
+			method:     http.MethodGet,
+			path:       "groups/kubefirst/projects?include_subgroups=true",
+			wantStatus: http.StatusOK,
+			wantCount:  2,
+			wantHooks:  1,
+		},
+		{
+			name:       "If a topic is given, should list the projects with the topic",
+			method:     http.MethodGet,
+			path:       "groups/kubefirst/projects?include_subgroups=true&topic=atlantis",
+			wantStatus: http.StatusOK,
+			wantCount:  1,
+			wantHooks:  1,
+		},
+		{
+			name:       "If the user exists, should list the user projects",
+			method:     http.MethodGet,
+			path:       "users/jdoe/projects",
+			wantStatus: http.StatusOK,
+			wantCount:  1,
+			wantHooks:  1,
+		},
+		{
+			name:       "If the project is given by encoded path, should return it",
+			method:     http.MethodGet,
+			path:       "projects/" + url.PathEscape("kubefirst/gitops"),
+			wantStatus: http.StatusOK,
+			wantHooks:  1,
+		},
+		{
+			name:       "If a hook is created with a url, should add it",
+			method:     http.MethodPost,
+			path:       "projects/" + url.PathEscape("kubefirst/gitops") + "/hooks",
+			body:       map[string]interface{}{"url": "https://new.ngrok.io/events", "merge_requests_events": true},
+			wantStatus: http.StatusCreated,
+			wantHooks:  2,
+		},
+		{
+			name:       "If a hook is created without a url, should answer 400",
+			method:     http.MethodPost,
+			path:       "projects/" + url.PathEscape("kubefirst/gitops") + "/hooks",
+			body:       map[string]interface{}{"push_events": true},
+			wantStatus: http.StatusBadRequest,
+			wantHooks:  1,
+		},
+		{
+			name:       "If the hook exists, should delete it",
+			method:     http.MethodDelete,
+			path:       "projects/" + url.PathEscape("kubefirst/gitops") + "/hooks/{hook}",
+			wantStatus: http.StatusNoContent,
+			wantHooks:  0,
+		},
+		{
+			name:       "If the test trigger is unsupported, should answer 400",
+			method:     http.MethodPost,
+			path:       "projects/" + url.PathEscape("kubefirst/gitops") + "/hooks/{hook}/test/unknown_events",
+			wantStatus: http.StatusBadRequest,
+			wantHooks:  1,
+		},
+		{
+			name:       "If the path is unknown, should answer 404",
+			method:     http.MethodGet,
+			path:       "projects/" + url.PathEscape("kubefirst/gitops") + "/repository/branches",
+			wantStatus: http.StatusNotFound,
+			wantHooks:  1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			forge := New()
+			forge.AddGitLabProject("kubefirst", "gitops", "atlantis")
+			forge.AddGitLabProject("kubefirst/infra", "metaphor")
+			forge.AddGitLabUser("jdoe")
+			forge.AddGitLabProject("jdoe", "sandbox")
+			server := httptest.NewServer(forge.Handler())
+			defer server.Close()
+
+			var hook struct {
+				ID int64 `json:"id"`
+			}
+			status := request(t, server, http.MethodPost, gitlabAPIPrefix+"projects/"+url.PathEscape("kubefirst/gitops")+"/hooks", map[string]interface{}{
+				"url": "https://old.ngrok.io/events",
+			}, &hook)
+			if status != http.StatusCreated {
+				t.Fatalf("create status = %d, want %d", status, http.StatusCreated)
+			}
+
+			var listed []map[string]interface{}
+			var out interface{}
+			if tt.wantCount > 0 {
+				out = &listed
+			}
+			status = request(t, server, tt.method, gitlabAPIPrefix+withHookID(tt.path, hook.ID), tt.body, out)
+			if status != tt.wantStatus {
+				t.Errorf("status = %d, want %d", status, tt.wantStatus)
+			}
+			if tt.wantCount > 0 && len(listed) != tt.wantCount {
+				t.Errorf("listed %d, want %d: %v", len(listed), tt.wantCount, listed)
+			}
+			if got := len(forge.GitLabHooks("kubefirst/gitops")); got != tt.wantHooks {
+				t.Errorf("hooks = %d, want %d", got, tt.wantHooks)
+			}
+		})
+	}
+}
+
+func TestGitLabHookEvents(t *testing.T) {
+	tests := []struct {
+		name           string
+		receiverStatus int
+		wantStatus     int
+		wantResponse   string
+	}{
+		{
+			name:           "If the hook url accepts the test event, should answer 201",
+			receiverStatus: http.StatusOK,
+			wantStatus:     http.StatusCreated,
+			wantResponse:   "200",
+		},
+		{
+			name:           "If the hook url rejects the test event, should answer 422",
+			receiverStatus: http.StatusBadGateway,
+			wantStatus:     http.StatusUnprocessableEntity,
+			wantResponse:   "502",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			target, headers := newTarget(t, tt.receiverStatus)
+			forge := New()
+			projectID := forge.AddGitLabProject("kubefirst", "gitops")
+			server := httptest.NewServer(forge.Handler())
+			defer server.Close()
+
+			projectPath := fmt.Sprintf("%sprojects/%d/hooks", gitlabAPIPrefix, projectID)
+			var hook struct {
+				ID int64 `json:"id"`
+			}
+			request(t, server, http.MethodPost, projectPath, map[string]interface{}{"url": target.URL, "token": "secret"}, &hook)
+			hookPath := fmt.Sprintf("%s/%d", projectPath, hook.ID)
+
+			status := request(t, server, http.MethodPost, hookPath+"/test/push_events", nil, nil)
+			if status != tt.wantStatus {
+				t.Fatalf("test status = %d, want %d", status, tt.wantStatus)
+			}
+			if len(*headers) != 1 {
+				t.Fatalf("deliveries received = %d, want 1", len(*headers))
+			}
+			received := (*headers)[0]
+			if received.Get("X-Gitlab-Event") != "Push Hook" || received.Get("X-Gitlab-Token") != "secret" {
+				t.Errorf("delivery headers = %v, want a push hook with the token", received)
+			}
+
+			var events []struct {
+				ID             int64             `json:"id"`
+				Trigger        string            `json:"trigger"`
+				ResponseStatus string            `json:"response_status"`
+				RequestHeaders map[string]string `json:"request_headers"`
+			}
+			request(t, server, http.MethodGet, hookPath+"/events", nil, &events)
+			if len(events) != 1 || events[0].ResponseStatus != tt.wantResponse || events[0].Trigger != "Push Hook" {
+				t.Fatalf("events = %+v, want one push hook with status %s", events, tt.wantResponse)
+			}
+			if events[0].RequestHeaders["X-Gitlab-Event-UUID"] != received.Get("X-Gitlab-Event-UUID") {
+				t.Errorf("event uuid = %s, want %s", events[0].RequestHeaders["X-Gitlab-Event-UUID"], received.Get("X-Gitlab-Event-UUID"))
+			}
+
+			var resent struct {
+				ResponseStatus string `json:"response_status"`
+			}
+			status = request(t, server, http.MethodPost, fmt.Sprintf("%s/events/%d/resend", hookPath, events[0].ID), nil, &resent)
+			if status != http.StatusCreated || resent.ResponseStatus != tt.wantResponse {
+				t.Errorf("resend status = %d %s, want %d %s", status, resent.ResponseStatus, http.StatusCreated, tt.wantResponse)
+			}
+			if len(*headers) != 2 || (*headers)[1].Get("X-Gitlab-Event-UUID") != received.Get("X-Gitlab-Event-UUID") {
+				t.Errorf("resent delivery should keep the event uuid")
+			}
+		})
+	}
+}
@@ -0,0 +1,316 @@
+package fakeforge
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	// GitHubPrefix is the path the fake GitHub api is served under, to be
+	// used as the GitHub client base url
+	GitHubPrefix string = "/github/"
+	// GitLabPrefix is the path the fake GitLab api is served under, to be
+	// used as the GitLab client base url
+	GitLabPrefix string = "/gitlab/"
+	// NgrokPath is the path of the fake ngrok agent tunnels api
+	NgrokPath string = "/api/tunnels"
+
+	gitlabAPIPrefix string = GitLabPrefix + "api/v4/"
+)
+
+// New returns an empty fake forge
+func New() *Server {
+	return &Server{
+		nextID:       1000,
+		githubOwners: make(map[string]*githubOwner),
+		namespaces:   make(map[string]*gitlabNamespace),
+		projects:     make(map[int64]*gitlabProject),
+		client:       &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Handler serves the fake GitHub api under GitHubPrefix, the fake GitLab api
+// under GitLabPrefix and the fake ngrok api at NgrokPath
+// Any token is accepted
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc(GitHubPrefix, s.serveGitHub)
+	mux.HandleFunc(gitlabAPIPrefix, s.serveGitLab)
+	mux.HandleFunc(NgrokPath, s.serveNgrok)
+	return mux
+}
+
+// AddGitHubRepository adds a repository owned by an organization, creating
+// the organization if needed
+func (s *Server) AddGitHubRepository(org string, name string, topics ...string) {
+	s.addGitHubRepository(org, true, name, topics)
+}
+
+// AddGitHubUserRepository adds a repository owned by a user
+func (s *Server) AddGitHubUserRepository(user string, name string, topics ...string) {
+	s.addGitHubRepository(user, false, name, topics)
+}
+
+// AddGitHubTeam adds a team with access to the given repositories
+func (s *Server) AddGitHubTeam(org string, team string, repos ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	owner := s.githubOwner(org, true)
+	owner.teams[team] = append(owner.teams[team], repos...)
+}
+
+// AddGitLabGroup adds a group, and any missing parent groups, by full path
+// and returns its ID
+func (s *Server) AddGitLabGroup(fullPath string) int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.gitlabGroup(fullPath).id
+}
+
+// AddGitLabUser adds a user namespace and returns its ID
+func (s *Server) AddGitLabUser(username string) int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ns, ok := s.namespaces[strings.ToLower(username)]
+	if !ok {
+		ns = &gitlabNamespace{id: s.id(), path: username, fullPath: username, kind: "user"}
+		s.namespaces[strings.ToLower(username)] = ns
+	}
+	return ns.id
+}
+
+// AddGitLabProject adds a project to a group, created if missing, or to an
+// existing user namespace, and returns its ID
+func (s *Server) AddGitLabProject(namespace string, name string, topics ...string) int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ns, ok := s.namespaces[strings.ToLower(namespace)]
+	if !ok {
+		ns = s.gitlabGroup(namespace)
+	}
+	project := &gitlabProject{
+		id:        s.id(),
+		name:      name,
+		path:      strings.ToLower(strings.ReplaceAll(name, " ", "-")),
+		namespace: ns,
+		topics:    append([]string{}, topics...),
+	}
+	s.projects[project.id] = project
+	return project.id
+}
+
+// SetTunnels replaces the tunnels reported by the fake ngrok api
+func (s *Server) SetTunnels(tunnels ...Tunnel) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.tunnels = tunnels
+}
+
+// GitHubHooks returns a copy of the hooks of a GitHub repository
+func (s *Server) GitHubHooks(owner string, repo string) []Hook {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	r := s.githubRepo(owner, repo)
+	if r == nil {
+		return []Hook{}
+	}
+	return copyHooks(r.hooks)
+}
+
+// GitLabHooks returns a copy of the hooks of a GitLab project given by full
+// path
+func (s *Server) GitLabHooks(projectPath string) []Hook {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	project := s.gitlabProject(projectPath)
+	if project == nil {
+		return []Hook{}
+	}
+	return copyHooks(project.hooks)
+}
+
+// serveNgrok reports the configured tunnels like the ngrok agent api
+func (s *Server) serveNgrok(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tunnels := make([]map[string]interface{}, 0, len(s.tunnels))
+	for _, tunnel := range s.tunnels {
+		tunnels = append(tunnels, map[string]interface{}{
+			"name":       tunnel.Name,
+			"public_url": tunnel.PublicURL,
+			"proto":      tunnel.Proto,
+			"config":     map[string]interface{}{"addr": tunnel.Addr},
+		})
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"tunnels": tunnels})
+}
+
+// id returns the next free object ID
+// Callers must hold s.mu
+func (s *Server) id() int64 {
+	s.nextID++
+	return s.nextID
+}
+
+func (s *Server) addGitHubRepository(login string, org bool, name string, topics []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	owner := s.githubOwner(login, org)
+	if _, ok := owner.repos[strings.ToLower(name)]; !ok {
+		owner.repos[strings.ToLower(name)] = &githubRepo{id: s.id(), name: name, topics: append([]string{}, topics...)}
+	}
+}
+
+// githubOwner returns an owner, creating it if missing
+// Callers must hold s.mu
+func (s *Server) githubOwner(login string, org bool) *githubOwner {
+	owner, ok := s.githubOwners[strings.ToLower(login)]
+	if !ok {
+		owner = &githubOwner{
+			login: login,
+			org:   org,
+			repos: make(map[string]*githubRepo),
+			teams: make(map[string][]string),
+		}
+		s.githubOwners[strings.ToLower(login)] = owner
+	}
+	return owner
+}
+
+// githubRepo returns a repository or nil
+// Callers must hold s.mu
+func (s *Server) githubRepo(login string, name string) *githubRepo {
+	owner, ok := s.githubOwners[strings.ToLower(login)]
+	if !ok {
+		return nil
+	}
+	return owner.repos[strings.ToLower(name)]
+}
+
+// gitlabGroup returns a group by full path, creating it and its parents if
+// missing
+// Callers must hold s.mu
+func (s *Server) gitlabGroup(fullPath string) *gitlabNamespace {
+	fullPath = strings.Trim(fullPath, "/")
+	if ns, ok := s.namespaces[strings.ToLower(fullPath)]; ok {
+		return ns
+	}
+
+	var parentID int64
+	path := fullPath
+	if i := strings.LastIndex(fullPath, "/"); i >= 0 {
+		parentID = s.gitlabGroup(fullPath[:i]).id
+		path = fullPath[i+1:]
+	}
+	ns := &gitlabNamespace{id: s.id(), path: path, fullPath: fullPath, kind: "group", parentID: parentID}
+	s.namespaces[strings.ToLower(fullPath)] = ns
+	return ns
+}
+
+// gitlabProject returns a project by numeric ID or full path, or nil
+// Callers must hold s.mu
+func (s *Server) gitlabProject(id string) *gitlabProject {
+	for _, project := range s.projects {
+		if strings.EqualFold(project.fullPath(), id) || formatID(project.id) == id {
+			return project
+		}
+	}
+	return nil
+}
+
+// gitlabNamespaceByID returns a namespace by numeric ID or full path, or nil
+// Callers must hold s.mu
+func (s *Server) gitlabNamespaceByID(id string) *gitlabNamespace {
+	if ns, ok := s.namespaces[strings.ToLower(id)]; ok {
+		return ns
+	}
+	for _, ns := range s.namespaces {
+		if formatID(ns.id) == id {
+			return ns
+		}
+	}
+	return nil
+}
+
+func (p *gitlabProject) fullPath() string {
+	return p.namespace.fullPath + "/" + p.path
+}
+
+// pathSegments splits the escaped request path below prefix into unescaped
+// segments, so that encoded slashes in GitLab project paths stay intact
+func pathSegments(r *http.Request, prefix string) []string {
+	escaped := strings.TrimPrefix(r.URL.EscapedPath(), prefix)
+	segments := strings.Split(strings.Trim(escaped, "/"), "/")
+	for i, segment := range segments {
+		unescaped, err := url.PathUnescape(segment)
+		if err == nil {
+			segments[i] = unescaped
+		}
+	}
+	return segments
+}
+
+// match reports whether segments match pattern, where * matches any single
+// segment, and returns the segments matched by *
+func match(segments []string, pattern ...string) ([]string, bool) {
+	if len(segments) != len(pattern) {
+		return nil, false
+	}
+	params := make([]string, 0)
+	for i, part := range pattern {
+		if part == "*" {
+			params = append(params, segments[i])
+			continue
+		}
+		if part != segments[i] {
+			return nil, false
+		}
+	}
+	return params, true
+}
+
+// writeJSON writes v as a JSON response
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeError writes a GitHub and GitLab compatible error message
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"message": message})
+}
+
+// copyHooks returns copies of hooks
+func copyHooks(hooks []*Hook) []Hook {
+	copies := make([]Hook, 0, len(hooks))
+	for _, hook := range hooks {
+		c := *hook
+		c.Events = append([]string{}, hook.Events...)
+		c.Deliveries = append([]Delivery{}, hook.Deliveries...)
+		copies = append(copies, c)
+	}
+	return copies
+}
+
+// findHook returns the hook with the given ID and its index, or nil
+func findHook(hooks []*Hook, id string) (*Hook, int) {
+	for i, hook := range hooks {
+		if formatID(hook.ID) == id {
+			return hook, i
+		}
+	}
+	return nil, -1
+}
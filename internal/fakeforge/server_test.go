@@ -0,0 +1,106 @@
+package fakeforge
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// request sends a request to the fake forge and decodes the JSON response
+// into out, if given
+func request(t *testing.T, server *httptest.Server, method string, path string, body interface{}, out interface{}) int {
+	t.Helper()
+
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			t.Fatalf("Marshal() error = %v", err)
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequest(method, server.URL+path, reader)
+	if err != nil {
+		t.Fatalf("NewRequest() error = %v", err)
+	}
+	resp, err := server.Client().Do(req)
+	if err != nil {
+		t.Fatalf("%s %s error = %v", method, path, err)
+	}
+	defer resp.Body.Close()
+
+	if out != nil && resp.StatusCode < 300 {
+		err = json.NewDecoder(resp.Body).Decode(out)
+		if err != nil {
+			t.Fatalf("%s %s decode error = %v", method, path, err)
+		}
+	}
+	return resp.StatusCode
+}
+
+// newTarget returns a server that answers hook deliveries with status and
+// records the headers of each delivery
+func newTarget(t *testing.T, status int) (*httptest.Server, *[]http.Header) {
+	headers := make([]http.Header, 0)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		headers = append(headers, r.Header.Clone())
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(server.Close)
+	return server, &headers
+}
+
+// withHookID replaces {hook} in a path with the hook ID
+func withHookID(path string, hookID int64) string {
+	return strings.ReplaceAll(path, "{hook}", strconv.FormatInt(hookID, 10))
+}
+
+func TestServeNgrok(t *testing.T) {
+	forge := New()
+	server := httptest.NewServer(forge.Handler())
+	defer server.Close()
+
+	tests := []struct {
+		name    string
+		tunnels []Tunnel
+		want    []string
+	}{
+		{
+			name: "If no tunnels are set, should report none",
+			want: []string{},
+		},
+		{
+			name:    "If tunnels are set, should report their public urls",
+			tunnels: []Tunnel{{Name: "atlantis", PublicURL: "https://abc123.ngrok.io", Proto: "https", Addr: "http://atlantis:4141"}},
+			want:    []string{"https://abc123.ngrok.io"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			forge.SetTunnels(tt.tunnels...)
+
+			var body struct {
+				Tunnels []struct {
+					PublicURL string `json:"public_url"`
+				} `json:"tunnels"`
+			}
+			status := request(t, server, http.MethodGet, NgrokPath, nil, &body)
+			if status != http.StatusOK {
+				t.Fatalf("status = %d, want %d", status, http.StatusOK)
+			}
+			got := make([]string, 0)
+			for _, tunnel := range body.Tunnels {
+				got = append(got, tunnel.PublicURL)
+			}
+			if len(got) != len(tt.want) || (len(got) > 0 && got[0] != tt.want[0]) {
+				t.Errorf("tunnels = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
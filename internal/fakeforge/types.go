@@ -0,0 +1,101 @@
+package fakeforge
+
+import (
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Server is an in-memory fake of the subset of the GitHub and GitLab REST
+// APIs used by git-helper, plus the ngrok agent tunnels api
+type Server struct {
+	mu     sync.Mutex
+	nextID int64
+
+	githubOwners map[string]*githubOwner
+	namespaces   map[string]*gitlabNamespace
+	projects     map[int64]*gitlabProject
+	tunnels      []Tunnel
+
+	// client sends hook deliveries
+	client *http.Client
+}
+
+// Hook is a webhook registered on a GitHub repository or GitLab project
+type Hook struct {
+	ID     int64
+	URL    string
+	Secret string
+	// Events holds GitHub event names, or the enabled GitLab *_events fields
+	Events      []string
+	ContentType string
+	InsecureSSL bool
+	Active      bool
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	Deliveries  []Delivery
+}
+
+// Delivery records an attempt to deliver an event to a hook
+type Delivery struct {
+	ID          int64
+	GUID        string
+	Event       string
+	DeliveredAt time.Time
+	Duration    float64
+	StatusCode  int
+	Status      string
+	Redelivery  bool
+	Request     DeliveryMessage
+	Response    DeliveryMessage
+}
+
+// DeliveryMessage holds the headers and body of a delivery request or response
+type DeliveryMessage struct {
+	Headers map[string]string
+	Payload string
+}
+
+// Tunnel is a tunnel reported by the fake ngrok agent api
+type Tunnel struct {
+	Name      string
+	PublicURL string
+	Proto     string
+	Addr      string
+}
+
+// githubOwner is a GitHub organization or user
+type githubOwner struct {
+	login string
+	org   bool
+	repos map[string]*githubRepo
+	teams map[string][]string
+}
+
+// githubRepo is a GitHub repository
+type githubRepo struct {
+	id       int64
+	name     string
+	topics   []string
+	archived bool
+	hooks    []*Hook
+}
+
+// gitlabNamespace is a GitLab group or user namespace
+type gitlabNamespace struct {
+	id       int64
+	path     string
+	fullPath string
+	kind     string
+	parentID int64
+}
+
+// gitlabProject is a GitLab project
+type gitlabProject struct {
+	id        int64
+	name      string
+	path      string
+	namespace *gitlabNamespace
+	topics    []string
+	hooks     []*Hook
+}
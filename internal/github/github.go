@@ -3,31 +3,109 @@ package github
 import (
 	"context"
 	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
 
+	"github.com/kubefirst/git-helper/internal/cassette"
 	log "github.com/sirupsen/logrus"
 
 	"github.com/google/go-github/v45/github"
 	"golang.org/x/oauth2"
 )
 
-var (
-	defaultEvents []string = []string{"pull_request_review", "push", "issue_comment", "pull_request"}
-)
-
 // NewGitHubClient instantiates a new GitHub client wrapper
-func NewGitHubClient(token string) GitHubWrapper {
+// Requests go through transport, http.DefaultTransport if nil
+func NewGitHubClient(token string, transport http.RoundTripper) (GitHubWrapper, error) {
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	if token == "" && cassette.Replaying(transport) {
+		token = "replay"
+	}
 	if token == "" {
-		log.Fatal("you must provide a token when using github as a provider")
+		return GitHubWrapper{}, fmt.Errorf("you must provide a token when using github as a provider")
 	}
 
 	var gSession GitHubWrapper
-	gSession.context = context.Background()
+	gSession.context = context.WithValue(context.Background(), oauth2.HTTPClient, &http.Client{
+		Transport: transport,
+	})
 	gSession.staticToken = oauth2.StaticTokenSource(&oauth2.Token{AccessToken: token})
 	gSession.oauthClient = oauth2.NewClient(gSession.context, gSession.staticToken)
 	gSession.gitClient = github.NewClient(gSession.oauthClient)
 
-	return gSession
+	// Allow pointing the client at GitHub Enterprise or a fake api
+	if apiURL := os.Getenv("GITHUB_API_URL"); apiURL != "" {
+		baseURL, err := url.Parse(strings.TrimSuffix(apiURL, "/") + "/")
+		if err != nil {
+			return GitHubWrapper{}, fmt.Errorf("invalid GITHUB_API_URL %s: %s", apiURL, err)
+		}
+		gSession.gitClient.BaseURL = baseURL
+	}
+
+	return gSession, nil
+}
 
+// ListOwnerRepositories returns all repositories of an organization, or of a
+// user if owner is not an organization
+func (gh *GitHubWrapper) ListOwnerRepositories(owner string) ([]*github.Repository, error) {
+	container := make([]*github.Repository, 0)
+	for nextPage := 1; nextPage > 0; {
+		repos, resp, err := gh.gitClient.Repositories.ListByOrg(gh.context, owner, &github.RepositoryListByOrgOptions{
+			ListOptions: github.ListOptions{
+				Page:    nextPage,
+				PerPage: 100,
+			},
+		})
+		if resp != nil && resp.StatusCode == http.StatusNotFound {
+			return gh.listUserRepositories(owner)
+		}
+		if err != nil {
+			return []*github.Repository{}, err
+		}
+		container = append(container, repos...)
+		nextPage = resp.NextPage
+	}
+	return container, nil
+}
+
+// ListTeamRepositories returns all repositories an organization team has
+// access to
+func (gh *GitHubWrapper) ListTeamRepositories(org string, team string) ([]*github.Repository, error) {
+	container := make([]*github.Repository, 0)
+	for nextPage := 1; nextPage > 0; {
+		repos, resp, err := gh.gitClient.Teams.ListTeamReposBySlug(gh.context, org, team, &github.ListOptions{
+			Page:    nextPage,
+			PerPage: 100,
+		})
+		if err != nil {
+			return []*github.Repository{}, err
+		}
+		container = append(container, repos...)
+		nextPage = resp.NextPage
+	}
+	return container, nil
+}
+
+// listUserRepositories returns all repositories owned by a user
+func (gh *GitHubWrapper) listUserRepositories(user string) ([]*github.Repository, error) {
+	container := make([]*github.Repository, 0)
+	for nextPage := 1; nextPage > 0; {
+		repos, resp, err := gh.gitClient.Repositories.List(gh.context, user, &github.RepositoryListOptions{
+			ListOptions: github.ListOptions{
+				Page:    nextPage,
+				PerPage: 100,
+			},
+		})
+		if err != nil {
+			return []*github.Repository{}, err
+		}
+		container = append(container, repos...)
+		nextPage = resp.NextPage
+	}
+	return container, nil
 }
 
 // ListRepoWebhooks returns all webhooks for a repository
@@ -47,81 +125,165 @@ func (gh *GitHubWrapper) ListRepoWebhooks(owner string, repo string) ([]*github.
 	return container, nil
 }
 
-// CreateRepositoryWebhook
-func (gh *GitHubWrapper) CreateRepositoryWebhook(req RepositoryHookRequest) error {
-	_, _, err := gh.gitClient.Repositories.CreateHook(gh.context, req.Org, req.Repository, &github.Hook{
-		Events: defaultEvents,
-		Config: map[string]interface{}{
-			"content_type": "json",
-			"insecure_ssl": 0,
-			"url":          req.Url,
-			"secret":       req.Token,
-		},
+// CreateRepositoryWebhook creates a webhook and returns its ID
+func (gh *GitHubWrapper) CreateRepositoryWebhook(req RepositoryHookRequest) (int64, error) {
+	err := req.Options.Validate()
+	if err != nil {
+		return 0, err
+	}
+	active := req.Options.IsActive()
+	hook, _, err := gh.gitClient.Repositories.CreateHook(gh.context, req.Org, req.Repository, &github.Hook{
+		Events: req.Options.GitHubEvents(),
+		Config: req.Options.GitHubConfig(req.Url, req.Token),
+		Active: &active,
 	})
 	if err != nil {
-		return fmt.Errorf("error when creating a webhook: %v", err)
+		return 0, fmt.Errorf("error when creating a webhook: %v", err)
 	}
 	log.Infof("created hook %s/%s / %s", req.Org, req.Repository, req.Url)
 
+	return hook.GetID(), nil
+}
+
+// DeleteRepositoryWebhookByID deletes a single webhook by its ID
+// A hook that no longer exists is not treated as an error
+func (gh *GitHubWrapper) DeleteRepositoryWebhookByID(org string, repository string, hookID int64) error {
+	resp, err := gh.gitClient.Repositories.DeleteHook(gh.context, org, repository, hookID)
+	if resp != nil && resp.StatusCode == http.StatusNotFound {
+		log.Infof("hook %s/%s / %d already deleted", org, repository, hookID)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	log.Infof("deleted hook %s/%s / %d", org, repository, hookID)
+
 	return nil
 }
 
-// DeleteRepositoryWebhook
+// DeleteRepositoryWebhook deletes every hook delivering to req.Url
 func (gh *GitHubWrapper) DeleteRepositoryWebhook(req RepositoryHookRequest) error {
-	webhooks, err := gh.ListRepoWebhooks(req.Org, req.Repository)
+	hookIDs, err := gh.matchingHookIDs(req)
 	if err != nil {
 		return err
 	}
 
-	var hookID int64 = 0
-	for _, hook := range webhooks {
-		if req.Url == hook.Config["url"] {
-			hookID = hook.GetID()
-		}
-	}
-	if hookID != 0 {
-		_, err := gh.gitClient.Repositories.DeleteHook(gh.context, req.Org, req.Repository, hookID)
+	for _, hookID := range hookIDs {
+		err := gh.DeleteRepositoryWebhookByID(req.Org, req.Repository, hookID)
 		if err != nil {
 			return err
 		}
-		log.Infof("deleted hook %s/%s / %s", req.Org, req.Repository, req.Url)
-	} else {
-		return fmt.Errorf("hook %s/%s / %s not found", req.Org, req.Repository, req.Url)
 	}
+	log.Infof("deleted %d hooks %s/%s / %s", len(hookIDs), req.Org, req.Repository, req.Url)
 
 	return nil
 }
 
-// UpdateRepositoryWebhook
+// UpdateRepositoryWebhook updates every hook delivering to req.Url
 func (gh *GitHubWrapper) UpdateRepositoryWebhook(req RepositoryHookRequest) error {
-	webhooks, err := gh.ListRepoWebhooks(req.Org, req.Repository)
+	hookIDs, err := gh.matchingHookIDs(req)
 	if err != nil {
 		return err
 	}
 
-	var hookID int64 = 0
-	for _, hook := range webhooks {
-		if req.Url == hook.Config["url"] {
-			hookID = hook.GetID()
+	for _, hookID := range hookIDs {
+		err := gh.UpdateRepositoryWebhookByID(req, hookID)
+		if err != nil {
+			return err
 		}
 	}
-	if hookID != 0 {
-		_, _, err := gh.gitClient.Repositories.EditHook(gh.context, req.Org, req.Repository, hookID, &github.Hook{
-			Events: defaultEvents,
-			Config: map[string]interface{}{
-				"content_type": "json",
-				"insecure_ssl": 0,
-				"url":          req.Url,
-				"secret":       req.Token,
-			},
-		})
+	log.Infof("updated %d hooks %s/%s / %s", len(hookIDs), req.Org, req.Repository, req.Url)
+
+	return nil
+}
+
+// UpdateRepositoryWebhookByID updates the url, secret, events and settings of
+// a single webhook
+func (gh *GitHubWrapper) UpdateRepositoryWebhookByID(req RepositoryHookRequest, hookID int64) error {
+	err := req.Options.Validate()
+	if err != nil {
+		return err
+	}
+	active := req.Options.IsActive()
+	_, _, err = gh.gitClient.Repositories.EditHook(gh.context, req.Org, req.Repository, hookID, &github.Hook{
+		Events: req.Options.GitHubEvents(),
+		Config: req.Options.GitHubConfig(req.Url, req.Token),
+		Active: &active,
+	})
+	if err != nil {
+		return fmt.Errorf("error when updating a webhook: %v", err)
+	}
+
+	return nil
+}
+
+// ListHookDeliveries returns up to limit of the most recent deliveries of a
+// hook, all of them if limit is not positive
+func (gh *GitHubWrapper) ListHookDeliveries(owner string, repo string, hookID int64, limit int) ([]*github.HookDelivery, error) {
+	container := make([]*github.HookDelivery, 0)
+	opts := &github.ListCursorOptions{PerPage: 100}
+	for {
+		deliveries, resp, err := gh.gitClient.Repositories.ListHookDeliveries(gh.context, owner, repo, hookID, opts)
 		if err != nil {
-			return fmt.Errorf("error when creating a webhook: %v", err)
+			return []*github.HookDelivery{}, err
 		}
-		log.Infof("updated hook %s/%s / %s", req.Org, req.Repository, req.Url)
-	} else {
-		return fmt.Errorf("hook %s/%s / %s not found", req.Org, req.Repository, req.Url)
+		container = append(container, deliveries...)
+		if limit > 0 && len(container) >= limit {
+			return container[:limit], nil
+		}
+		if resp.Cursor == "" || len(deliveries) == 0 {
+			return container, nil
+		}
+		opts.Cursor = resp.Cursor
 	}
+}
+
+// PingRepositoryWebhook asks GitHub to send a ping event to a hook
+func (gh *GitHubWrapper) PingRepositoryWebhook(owner string, repo string, hookID int64) error {
+	_, err := gh.gitClient.Repositories.PingHook(gh.context, owner, repo, hookID)
+	if err != nil {
+		return err
+	}
+	log.Infof("pinged hook %s/%s / %d", owner, repo, hookID)
 
 	return nil
 }
+
+// RedeliverHookDelivery asks GitHub to send a delivery of a hook again
+func (gh *GitHubWrapper) RedeliverHookDelivery(owner string, repo string, hookID int64, deliveryID int64) error {
+	_, _, err := gh.gitClient.Repositories.RedeliverHookDelivery(gh.context, owner, repo, hookID, deliveryID)
+	// Redeliveries are queued, GitHub answers 202 Accepted
+	if _, ok := err.(*github.AcceptedError); ok {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	log.Infof("redelivered %s/%s / %d delivery %d", owner, repo, hookID, deliveryID)
+
+	return nil
+}
+
+// matchingHookIDs returns the IDs of all hooks delivering to req.Url
+// In strict mode more than one match is an error
+func (gh *GitHubWrapper) matchingHookIDs(req RepositoryHookRequest) ([]int64, error) {
+	webhooks, err := gh.ListRepoWebhooks(req.Org, req.Repository)
+	if err != nil {
+		return []int64{}, err
+	}
+
+	hookIDs := make([]int64, 0)
+	for _, hook := range webhooks {
+		if req.Url == hook.Config["url"] {
+			hookIDs = append(hookIDs, hook.GetID())
+		}
+	}
+	if len(hookIDs) == 0 {
+		return []int64{}, fmt.Errorf("hook %s/%s / %s not found", req.Org, req.Repository, req.Url)
+	}
+	if req.Strict && len(hookIDs) > 1 {
+		return []int64{}, fmt.Errorf("hook %s/%s / %s is ambiguous, %d hooks match %v", req.Org, req.Repository, req.Url, len(hookIDs), hookIDs)
+	}
+
+	return hookIDs, nil
+}
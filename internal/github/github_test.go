@@ -0,0 +1,92 @@
+package github
+
+import (
+	"testing"
+
+	"github.com/kubefirst/git-helper/internal/cassette"
+)
+
+func TestDeleteRepositoryWebhookReplay(t *testing.T) {
+	tests := []struct {
+		name    string
+		url     string
+		wantErr bool
+	}{
+		{
+			name: "If a hook delivers to the url, should delete it",
+			url:  "https://old.ngrok.io/events",
+		},
+		{
+			name:    "If no hook delivers to the url, should return an error",
+			url:     "https://missing.ngrok.io/events",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			replayer, err := cassette.Load("testdata/delete_hook.json")
+			if err != nil {
+				t.Fatalf("Load() error = %v", err)
+			}
+
+			gh, err := NewGitHubClient("", replayer)
+			if err != nil {
+				t.Fatalf("NewGitHubClient() error = %v", err)
+			}
+			err = gh.DeleteRepositoryWebhook(RepositoryHookRequest{Org: "kubefirst", Repository: "gitops", Url: tt.url})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("DeleteRepositoryWebhook() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNewGitHubClient(t *testing.T) {
+	tests := []struct {
+		name        string
+		token       string
+		apiURL      string
+		wantBaseURL string
+		wantErr     bool
+	}{
+		{
+			name:        "If no api url is set, should use the public api",
+			token:       "token",
+			wantBaseURL: "https://api.github.com/",
+		},
+		{
+			name:        "If an api url is set, should use it with a trailing slash",
+			token:       "token",
+			apiURL:      "https://github.example.com/api/v3",
+			wantBaseURL: "https://github.example.com/api/v3/",
+		},
+		{
+			name:    "If the api url is invalid, should return an error",
+			token:   "token",
+			apiURL:  "://github.example.com",
+			wantErr: true,
+		},
+		{
+			name:    "If no token is given, should return an error",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("GITHUB_API_URL", tt.apiURL)
+
+			gh, err := NewGitHubClient(tt.token, nil)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewGitHubClient() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got := gh.gitClient.BaseURL.String(); got != tt.wantBaseURL {
+				t.Errorf("NewGitHubClient() base url = %s, want %s", got, tt.wantBaseURL)
+			}
+		})
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"net/http"
 
 	"github.com/google/go-github/v45/github"
+	"github.com/kubefirst/git-helper/internal/hooks"
 	"golang.org/x/oauth2"
 )
 
@@ -24,4 +25,8 @@ type RepositoryHookRequest struct {
 	Repository string
 	Url        string
 	Token      string
+	Options    hooks.Options
+	// Strict makes delete and update fail when more than one hook matches Url
+	// instead of acting on all of them
+	Strict bool
 }
@@ -0,0 +1,40 @@
+package gitlabcloud
+
+import (
+	"strings"
+	"sync"
+)
+
+// projectCache maps project paths to IDs for the lifetime of a wrapper
+type projectCache struct {
+	mu  sync.Mutex
+	ids map[string]int
+}
+
+func newProjectCache() *projectCache {
+	return &projectCache{ids: make(map[string]int)}
+}
+
+// get returns the cached ID of a project path
+// A nil cache never holds entries
+func (c *projectCache) get(path string) (int, bool) {
+	if c == nil {
+		return 0, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	id, ok := c.ids[strings.ToLower(path)]
+	return id, ok
+}
+
+// put caches the ID of a project path
+func (c *projectCache) put(path string, id int) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.ids[strings.ToLower(path)] = id
+}
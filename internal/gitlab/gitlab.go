@@ -1,127 +1,305 @@
 package gitlabcloud
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"net/http"
+	"os"
+	"regexp"
+	"strconv"
 	"strings"
+	"time"
 
 	log "github.com/sirupsen/logrus"
 	"github.com/xanzy/go-gitlab"
 )
 
-// NewGitLabClient instantiates a wrapper to communicate with GitLab
-// It sets the path and ID of the group under which resources will be managed
-func NewGitLabClient(token string, parentGroupName string) (GitLabWrapper, error) {
-	git, err := gitlab.NewClient(token)
-	if err != nil {
-		return GitLabWrapper{}, fmt.Errorf("error instantiating gitlab client: %s", err)
-	}
+// namespaceKindUser is the kind of user namespaces
+const namespaceKindUser string = "user"
 
-	// Get parent group ID
-	minAccessLevel := gitlab.AccessLevelValue(gitlab.DeveloperPermissions)
-	container := make([]gitlab.Group, 0)
-	for nextPage := 1; nextPage > 0; {
-		groups, resp, err := git.Groups.ListGroups(&gitlab.ListGroupsOptions{
-			ListOptions: gitlab.ListOptions{
-				Page:    nextPage,
-				PerPage: 10,
-			},
-			MinAccessLevel: &minAccessLevel,
-		})
-		if err != nil {
-			return GitLabWrapper{}, fmt.Errorf("could not get gitlab groups: %s", err)
-		}
-		for _, group := range groups {
-			container = append(container, *group)
-		}
-		nextPage = resp.NextPage
+// projectIDPrefix marks a project given by numeric ID, e.g. id:42
+const projectIDPrefix string = "id:"
+
+// ErrProjectNotFound is returned when a project cannot be resolved
+var ErrProjectNotFound error = errors.New("project not found")
+
+// hookTestStatus finds the status code in the message of a failed hook test
+// e.g. "Hook executed successfully but returned HTTP 502"
+var hookTestStatus *regexp.Regexp = regexp.MustCompile(`HTTP ([1-5][0-9]{2})\b`)
+
+// hookTestNoCommits matches the message of a hook test GitLab could not send
+// because the project has no commits
+var hookTestNoCommits *regexp.Regexp = regexp.MustCompile(`(?i)at least one commit`)
+
+// deletedProjectPath matches the path GitLab gives projects pending deletion
+var deletedProjectPath *regexp.Regexp = regexp.MustCompile(`-deleted-[0-9]+$`)
+
+// NewGitLabClient instantiates a wrapper to communicate with GitLab
+// owner is the full path of the group or user under which resources are
+// managed. It is resolved on first use, so operations that only address
+// projects by path or ID never look it up. Requests go through transport,
+// http.DefaultTransport if nil
+func NewGitLabClient(token string, owner string, transport http.RoundTripper) (GitLabWrapper, error) {
+	if transport == nil {
+		transport = http.DefaultTransport
 	}
-	var gid int = 0
-	for _, group := range container {
-		if group.FullPath == parentGroupName {
-			gid = group.ID
-		} else {
-			continue
-		}
+	opts := []gitlab.ClientOptionFunc{
+		gitlab.WithHTTPClient(&http.Client{Transport: transport}),
 	}
-	if gid == 0 {
-		return GitLabWrapper{}, fmt.Errorf("could not find gitlab group %s", parentGroupName)
+	// Allow pointing the client at a self-managed instance or a fake api
+	if apiURL := os.Getenv("GITLAB_API_URL"); apiURL != "" {
+		opts = append(opts, gitlab.WithBaseURL(apiURL))
 	}
-
-	// Get parent group path
-	group, _, err := git.Groups.GetGroup(gid, &gitlab.GetGroupOptions{})
+	git, err := gitlab.NewClient(token, opts...)
 	if err != nil {
-		return GitLabWrapper{}, fmt.Errorf("could not get gitlab parent group path: %s", err)
+		return GitLabWrapper{}, fmt.Errorf("error instantiating gitlab client: %s", err)
 	}
 
 	return GitLabWrapper{
-		Client:          git,
-		ParentGroupID:   gid,
-		ParentGroupPath: group.FullPath,
+		Client:    git,
+		Owner:     strings.Trim(owner, "/"),
+		projects:  newProjectCache(),
+		namespace: &namespaceResolver{},
 	}, nil
 }
 
-// CheckProjectExists within a parent group
-func (gl *GitLabWrapper) CheckProjectExists(projectName string) (bool, error) {
-	allprojects, err := gl.GetProjects()
+// Namespace returns the group or user namespace of the owner, resolving it
+// with a single lookup the first time it is needed
+func (gl *GitLabWrapper) Namespace() (*gitlab.Namespace, error) {
+	if gl.namespace == nil {
+		return gl.resolveNamespace()
+	}
+	gl.namespace.once.Do(func() {
+		gl.namespace.ns, gl.namespace.err = gl.resolveNamespace()
+	})
+	return gl.namespace.ns, gl.namespace.err
+}
+
+// resolveNamespace looks up the owner namespace by path
+func (gl *GitLabWrapper) resolveNamespace() (*gitlab.Namespace, error) {
+	ns, _, err := gl.Client.Namespaces.GetNamespace(gl.Owner)
 	if err != nil {
-		return false, err
+		return nil, fmt.Errorf("could not find gitlab group or user %s: %s", gl.Owner, err)
 	}
+	return ns, nil
+}
 
-	var exists bool = false
-	for _, project := range allprojects {
-		if project.Name == projectName {
-			exists = true
-		}
+// CheckProjectExists for the owner
+func (gl *GitLabWrapper) CheckProjectExists(projectName string) (bool, error) {
+	_, err := gl.GetProjectID(projectName)
+	if errors.Is(err, ErrProjectNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
 	}
 
-	return exists, nil
+	return true, nil
 }
 
-// GetProjectID returns a project's ID scoped to the parent group
+// GetProjectID returns a project's ID
+// The project is given by full path, by a path relative to the owner such as
+// project or subgroup/project, or by ID with an id: prefix such as id:42. With
+// Recursive set, a bare project name is also searched for in the subgroups of
+// the owner. A name made only of digits is looked up as a path first and as
+// an ID if no project has that path. Results are cached for the lifetime of
+// the wrapper
 func (gl *GitLabWrapper) GetProjectID(projectName string) (int, error) {
-	container := make([]gitlab.Project, 0)
-	for nextPage := 1; nextPage > 0; {
-		projects, resp, err := gl.Client.Groups.ListGroupProjects(gl.ParentGroupID, &gitlab.ListGroupProjectsOptions{
-			ListOptions: gitlab.ListOptions{
-				Page:    nextPage,
-				PerPage: 10,
-			},
-		})
+	if strings.HasPrefix(projectName, projectIDPrefix) {
+		id, err := strconv.Atoi(strings.TrimPrefix(projectName, projectIDPrefix))
 		if err != nil {
-			return 0, err
+			return 0, fmt.Errorf("invalid project ID %s: %s", projectName, err)
 		}
-		for _, project := range projects {
-			container = append(container, *project)
+		return id, nil
+	}
+
+	path := gl.projectPath(projectName)
+	if id, ok := gl.projects.get(path); ok {
+		return id, nil
+	}
+
+	project, resp, err := gl.Client.Projects.GetProject(path, &gitlab.GetProjectOptions{})
+	switch {
+	case err == nil && !projectDeleted(project):
+		gl.projects.put(path, project.ID)
+		return project.ID, nil
+	case err != nil && (resp == nil || resp.StatusCode != http.StatusNotFound):
+		return 0, fmt.Errorf("could not get project %s: %s", path, err)
+	}
+
+	if gl.Recursive && !strings.Contains(projectName, "/") {
+		id, err := gl.searchProjectID(projectName)
+		if err == nil {
+			gl.projects.put(path, id)
+			return id, nil
 		}
-		nextPage = resp.NextPage
+		if !errors.Is(err, ErrProjectNotFound) {
+			return 0, err
+		}
+	}
+
+	if id, err := strconv.Atoi(projectName); err == nil {
+		return gl.lookupProjectID(id)
+	}
+
+	return 0, fmt.Errorf("%w: %s", ErrProjectNotFound, path)
+}
+
+// lookupProjectID checks that a project with the ID exists
+func (gl *GitLabWrapper) lookupProjectID(id int) (int, error) {
+	key := projectIDPrefix + strconv.Itoa(id)
+	if _, ok := gl.projects.get(key); ok {
+		return id, nil
+	}
+
+	project, resp, err := gl.Client.Projects.GetProject(id, &gitlab.GetProjectOptions{})
+	switch {
+	case err == nil && !projectDeleted(project):
+		gl.projects.put(key, project.ID)
+		return project.ID, nil
+	case err != nil && (resp == nil || resp.StatusCode != http.StatusNotFound):
+		return 0, fmt.Errorf("could not get project %d: %s", id, err)
+	}
+
+	return 0, fmt.Errorf("%w: %s/%d or ID %d", ErrProjectNotFound, gl.Owner, id, id)
+}
+
+// searchProjectID finds a project by path or name among the projects of the
+// owner, including subgroups
+func (gl *GitLabWrapper) searchProjectID(projectName string) (int, error) {
+	ns, err := gl.Namespace()
+	if err != nil {
+		return 0, err
 	}
 
-	for _, project := range container {
-		if !strings.Contains(project.Name, "deleted") &&
-			strings.ToLower(project.Name) == projectName {
-			return project.ID, nil
+	var projects []gitlab.Project
+	if ns.Kind == namespaceKindUser {
+		projects, err = gl.listUserProjects(ns.Path, "", projectName)
+	} else {
+		projects, err = gl.listGroupProjects(ns.ID, "", projectName, true)
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	matches := make([]string, 0)
+	var projectID int
+	for _, project := range projects {
+		if strings.EqualFold(project.Path, projectName) || strings.EqualFold(project.Name, projectName) {
+			matches = append(matches, project.PathWithNamespace)
+			projectID = project.ID
 		}
 	}
 
-	return 0, fmt.Errorf("could not get project ID for project %s", projectName)
+	switch len(matches) {
+	case 0:
+		return 0, fmt.Errorf("%w: %s in %s or its subgroups", ErrProjectNotFound, projectName, gl.Owner)
+	case 1:
+		return projectID, nil
+	default:
+		return 0, fmt.Errorf("project %s is ambiguous, matches %s", projectName, strings.Join(matches, ", "))
+	}
+}
+
+// projectPath returns the full path of a project given by full path or by a
+// path relative to the owner
+func (gl *GitLabWrapper) projectPath(projectName string) string {
+	projectName = strings.Trim(projectName, "/")
+	if gl.Owner == "" || hasPathPrefix(projectName, gl.Owner) {
+		return projectName
+	}
+	return gl.Owner + "/" + projectName
+}
+
+// RelativeProjectPath returns the path of a project relative to the owner,
+// which GetProjectID accepts
+func (gl *GitLabWrapper) RelativeProjectPath(project gitlab.Project) string {
+	if hasPathPrefix(project.PathWithNamespace, gl.Owner) {
+		return project.PathWithNamespace[len(gl.Owner)+1:]
+	}
+	return project.PathWithNamespace
 }
 
-// GetProjects for a specific parent group by ID
+// GetProjects returns the projects of the owner
 func (gl *GitLabWrapper) GetProjects() ([]gitlab.Project, error) {
+	return gl.ListOwnerProjects("")
+}
+
+// ListOwnerProjects returns the projects of the owner group or user,
+// including those of subgroups when Recursive is set, optionally limited to
+// a topic
+func (gl *GitLabWrapper) ListOwnerProjects(topic string) ([]gitlab.Project, error) {
+	ns, err := gl.Namespace()
+	if err != nil {
+		return []gitlab.Project{}, err
+	}
+	if ns.Kind == namespaceKindUser {
+		return gl.listUserProjects(ns.Path, topic, "")
+	}
+	return gl.listGroupProjects(ns.ID, topic, "", gl.Recursive)
+}
+
+// ListGroupProjects returns the projects of a group, given by ID or full
+// path, and its subgroups, optionally limited to a topic
+func (gl *GitLabWrapper) ListGroupProjects(group interface{}, topic string) ([]gitlab.Project, error) {
+	return gl.listGroupProjects(group, topic, "", true)
+}
+
+// listGroupProjects lists the projects of a group, optionally filtered by
+// topic and search term
+func (gl *GitLabWrapper) listGroupProjects(group interface{}, topic string, search string, includeSubGroups bool) ([]gitlab.Project, error) {
+	opts := &gitlab.ListGroupProjectsOptions{
+		ListOptions: gitlab.ListOptions{
+			PerPage: 100,
+		},
+		IncludeSubGroups: &includeSubGroups,
+	}
+	if topic != "" {
+		opts.Topic = &topic
+	}
+	if search != "" {
+		opts.Search = &search
+	}
+
+	return collectProjects(func(page int) ([]*gitlab.Project, *gitlab.Response, error) {
+		opts.Page = page
+		return gl.Client.Groups.ListGroupProjects(group, opts)
+	})
+}
+
+// listUserProjects lists the projects of a user, optionally filtered by topic
+// and search term
+func (gl *GitLabWrapper) listUserProjects(user string, topic string, search string) ([]gitlab.Project, error) {
+	opts := &gitlab.ListProjectsOptions{
+		ListOptions: gitlab.ListOptions{
+			PerPage: 100,
+		},
+	}
+	if topic != "" {
+		opts.Topic = &topic
+	}
+	if search != "" {
+		opts.Search = &search
+	}
+
+	return collectProjects(func(page int) ([]*gitlab.Project, *gitlab.Response, error) {
+		opts.Page = page
+		return gl.Client.Projects.ListUserProjects(user, opts)
+	})
+}
+
+// collectProjects pages through a project listing, skipping projects pending
+// deletion
+func collectProjects(list func(page int) ([]*gitlab.Project, *gitlab.Response, error)) ([]gitlab.Project, error) {
 	container := make([]gitlab.Project, 0)
 	for nextPage := 1; nextPage > 0; {
-		projects, resp, err := gl.Client.Groups.ListGroupProjects(gl.ParentGroupID, &gitlab.ListGroupProjectsOptions{
-			ListOptions: gitlab.ListOptions{
-				Page:    nextPage,
-				PerPage: 10,
-			},
-		})
+		projects, resp, err := list(nextPage)
 		if err != nil {
 			return []gitlab.Project{}, err
 		}
 		for _, project := range projects {
-			// Skip deleted projects
-			if !strings.Contains(project.Name, "deleted") {
+			if !projectDeleted(project) {
 				container = append(container, *project)
 			}
 		}
@@ -139,7 +317,7 @@ func (gl *GitLabWrapper) ListProjectWebhooks(projectID int) ([]gitlab.ProjectHoo
 	for nextPage := 1; nextPage > 0; {
 		hooks, resp, err := gl.Client.Projects.ListProjectHooks(projectID, &gitlab.ListProjectHooksOptions{
 			Page:    nextPage,
-			PerPage: 10,
+			PerPage: 100,
 		})
 		if err != nil {
 			return []gitlab.ProjectHook{}, err
@@ -152,77 +330,235 @@ func (gl *GitLabWrapper) ListProjectWebhooks(projectID int) ([]gitlab.ProjectHoo
 	return container, nil
 }
 
-// CreateProjectWebhook
-func (gl *GitLabWrapper) CreateProjectWebhook(req *ProjectHookRequest) error {
-	projectID, err := gl.GetProjectID(req.ProjectName)
+// TestProjectWebhook sends a test event for trigger, e.g. push_events, to a
+// project hook and reports whether the hook accepted it
+// The test request is abandoned after timeout, if positive
+func (gl *GitLabWrapper) TestProjectWebhook(projectName string, hookID int, trigger string, timeout time.Duration) (ProjectHookTestResult, error) {
+	projectID, err := gl.GetProjectID(projectName)
+	if err != nil {
+		return ProjectHookTestResult{}, err
+	}
+
+	ctx := context.Background()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	path := fmt.Sprintf("projects/%d/hooks/%d/test/%s", projectID, hookID, trigger)
+	req, err := gl.Client.NewRequest(http.MethodPost, path, nil, []gitlab.RequestOptionFunc{gitlab.WithContext(ctx)})
+	if err != nil {
+		return ProjectHookTestResult{}, err
+	}
+	resp, err := gl.Client.Do(req, nil)
+	var errResp *gitlab.ErrorResponse
+	if errors.As(err, &errResp) && resp != nil {
+		switch {
+		// GitLab answers 422 with the outcome when the hook did not accept
+		// the event
+		case resp.StatusCode == http.StatusUnprocessableEntity && !hookTestNoCommits.MatchString(errResp.Message):
+			result := ProjectHookTestResult{Message: errResp.Message}
+			if match := hookTestStatus.FindStringSubmatch(errResp.Message); match != nil {
+				result.StatusCode, _ = strconv.Atoi(match[1])
+			}
+			return result, nil
+		// Push test events need a commit to describe, so the hook was never
+		// called on an empty project
+		case hookTestNoCommits.MatchString(errResp.Message):
+			return ProjectHookTestResult{Inconclusive: true, Message: errResp.Message}, nil
+		}
+	}
+	if err != nil {
+		return ProjectHookTestResult{}, err
+	}
+	log.Infof("tested hook %s / %d with %s", projectName, hookID, trigger)
+
+	return ProjectHookTestResult{Success: true, StatusCode: http.StatusOK, Message: "Hook executed successfully"}, nil
+}
+
+// ListProjectHookEvents returns up to limit of the most recent events, or
+// deliveries, of a project hook, all of them if limit is not positive
+// The hook events api requires GitLab 17.8 or later
+func (gl *GitLabWrapper) ListProjectHookEvents(projectName string, hookID int, limit int) ([]ProjectHookEvent, error) {
+	projectID, err := gl.GetProjectID(projectName)
+	if err != nil {
+		return []ProjectHookEvent{}, err
+	}
+
+	container := make([]ProjectHookEvent, 0)
+	for nextPage := 1; nextPage > 0; {
+		path := fmt.Sprintf("projects/%d/hooks/%d/events", projectID, hookID)
+		req, err := gl.Client.NewRequest(http.MethodGet, path, &gitlab.ListOptions{Page: nextPage, PerPage: 100}, nil)
+		if err != nil {
+			return []ProjectHookEvent{}, err
+		}
+		var events []ProjectHookEvent
+		resp, err := gl.Client.Do(req, &events)
+		if resp != nil && resp.StatusCode == http.StatusNotFound {
+			return []ProjectHookEvent{}, fmt.Errorf("hook events of %s / %d not found, they require GitLab 17.8 or later", projectName, hookID)
+		}
+		if err != nil {
+			return []ProjectHookEvent{}, err
+		}
+		container = append(container, events...)
+		if limit > 0 && len(container) >= limit {
+			return container[:limit], nil
+		}
+		nextPage = resp.NextPage
+	}
+	return container, nil
+}
+
+// ResendProjectHookEvent asks GitLab to send a project hook event again
+func (gl *GitLabWrapper) ResendProjectHookEvent(projectName string, hookID int, eventID int) error {
+	projectID, err := gl.GetProjectID(projectName)
 	if err != nil {
 		return err
 	}
 
-	_, _, err = gl.Client.Projects.AddProjectHook(projectID, req.CreateOpts)
+	path := fmt.Sprintf("projects/%d/hooks/%d/events/%d/resend", projectID, hookID, eventID)
+	req, err := gl.Client.NewRequest(http.MethodPost, path, nil, nil)
 	if err != nil {
 		return err
 	}
+	_, err = gl.Client.Do(req, nil)
+	if err != nil {
+		return err
+	}
+	log.Infof("resent %s / %d event %d", projectName, hookID, eventID)
+
+	return nil
+}
+
+// CreateProjectWebhook creates a webhook and returns its ID
+func (gl *GitLabWrapper) CreateProjectWebhook(req *ProjectHookRequest) (int, error) {
+	projectID, err := gl.GetProjectID(req.ProjectName)
+	if err != nil {
+		return 0, err
+	}
+
+	hook, _, err := gl.Client.Projects.AddProjectHook(projectID, req.CreateOpts)
+	if err != nil {
+		return 0, err
+	}
 	log.Infof("created hook %s / %s", req.ProjectName, *req.CreateOpts.URL)
 
+	return hook.ID, nil
+}
+
+// DeleteProjectWebhookByID deletes a single webhook by its ID
+// A hook that no longer exists is not treated as an error
+func (gl *GitLabWrapper) DeleteProjectWebhookByID(projectName string, hookID int) error {
+	projectID, err := gl.GetProjectID(projectName)
+	if err != nil {
+		return err
+	}
+
+	resp, err := gl.Client.Projects.DeleteProjectHook(projectID, hookID)
+	if resp != nil && resp.StatusCode == http.StatusNotFound {
+		log.Infof("hook %s / %d already deleted", projectName, hookID)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	log.Infof("deleted hook %s / %d", projectName, hookID)
+
 	return nil
 }
 
-// DeleteProjectWebhook
+// DeleteProjectWebhook deletes every hook delivering to the requested url
 func (gl *GitLabWrapper) DeleteProjectWebhook(req *ProjectHookRequest) error {
-	projectID, err := gl.GetProjectID(req.ProjectName)
+	projectID, hookIDs, err := gl.matchingHookIDs(req, *req.CreateOpts.URL)
 	if err != nil {
 		return err
 	}
 
-	webhooks, err := gl.ListProjectWebhooks(projectID)
+	for _, hookID := range hookIDs {
+		resp, err := gl.Client.Projects.DeleteProjectHook(projectID, hookID)
+		if resp != nil && resp.StatusCode == http.StatusNotFound {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+	}
+	log.Infof("deleted %d hooks %s/%s", len(hookIDs), req.ProjectName, *req.CreateOpts.URL)
+
+	return nil
+}
+
+// UpdateProjectWebhook updates every hook delivering to the requested url
+func (gl *GitLabWrapper) UpdateProjectWebhook(req *ProjectHookRequest) error {
+	projectID, hookIDs, err := gl.matchingHookIDs(req, *req.CreateOpts.URL)
 	if err != nil {
 		return err
 	}
 
-	var hookID int = 0
-	for _, hook := range webhooks {
-		if hook.ProjectID == projectID && hook.URL == *req.CreateOpts.URL {
-			hookID = hook.ID
+	for _, hookID := range hookIDs {
+		_, _, err = gl.Client.Projects.EditProjectHook(projectID, hookID, req.PatchOpts)
+		if err != nil {
+			return err
 		}
 	}
-	if hookID == 0 {
-		return fmt.Errorf("no webhooks were found for project %s given search parameters", req.ProjectName)
+
+	return nil
+}
+
+// UpdateProjectWebhookByID updates a single webhook
+func (gl *GitLabWrapper) UpdateProjectWebhookByID(projectName string, hookID int, opts *gitlab.EditProjectHookOptions) error {
+	projectID, err := gl.GetProjectID(projectName)
+	if err != nil {
+		return err
 	}
-	_, err = gl.Client.Projects.DeleteProjectHook(projectID, hookID)
+
+	_, _, err = gl.Client.Projects.EditProjectHook(projectID, hookID, opts)
 	if err != nil {
 		return err
 	}
-	log.Infof("deleted hook %s/%s", req.ProjectName, *req.CreateOpts.URL)
+	log.Infof("updated hook %s / %d", projectName, hookID)
 
 	return nil
 }
 
-// UpdateProjectWebhook
-func (gl *GitLabWrapper) UpdateProjectWebhook(req *ProjectHookRequest) error {
+// matchingHookIDs returns the project ID and the IDs of all hooks delivering
+// to url
+// In strict mode more than one match is an error
+func (gl *GitLabWrapper) matchingHookIDs(req *ProjectHookRequest, url string) (int, []int, error) {
 	projectID, err := gl.GetProjectID(req.ProjectName)
 	if err != nil {
-		return err
+		return 0, []int{}, err
 	}
 
 	webhooks, err := gl.ListProjectWebhooks(projectID)
 	if err != nil {
-		return err
+		return 0, []int{}, err
 	}
 
-	var hookID int = 0
+	hookIDs := make([]int, 0)
 	for _, hook := range webhooks {
-		if hook.ProjectID == projectID && hook.URL == *req.CreateOpts.URL {
-			hookID = hook.ID
+		if hook.ProjectID == projectID && hook.URL == url {
+			hookIDs = append(hookIDs, hook.ID)
 		}
 	}
-	if hookID == 0 {
-		return fmt.Errorf("no webhooks were found for project %s given search parameters", req.ProjectName)
+	if len(hookIDs) == 0 {
+		return 0, []int{}, fmt.Errorf("no webhooks were found for project %s given search parameters", req.ProjectName)
 	}
-	_, _, err = gl.Client.Projects.EditProjectHook(projectID, hookID, req.PatchOpts)
-	if err != nil {
-		return err
+	if req.Strict && len(hookIDs) > 1 {
+		return 0, []int{}, fmt.Errorf("webhook %s on project %s is ambiguous, %d hooks match %v", url, req.ProjectName, len(hookIDs), hookIDs)
 	}
 
-	return nil
+	return projectID, hookIDs, nil
+}
+
+// projectDeleted reports whether a project is pending deletion
+// GitLab renames such projects to <path>-deleted-<id> on some versions
+func projectDeleted(project *gitlab.Project) bool {
+	return project.MarkedForDeletionAt != nil || deletedProjectPath.MatchString(project.Path)
+}
+
+// hasPathPrefix reports whether path is below prefix, ignoring case as GitLab
+// does
+func hasPathPrefix(path string, prefix string) bool {
+	return len(path) > len(prefix) && path[len(prefix)] == '/' && strings.EqualFold(path[:len(prefix)], prefix)
 }
@@ -0,0 +1,214 @@
+package gitlabcloud
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/xanzy/go-gitlab"
+)
+
+func TestGetProjectID(t *testing.T) {
+	projects := map[string]*gitlab.Project{
+		"kubefirst/gitops":         {ID: 1, Path: "gitops", Name: "gitops", PathWithNamespace: "kubefirst/gitops"},
+		"kubefirst/infra/metaphor": {ID: 2, Path: "metaphor", Name: "Metaphor", PathWithNamespace: "kubefirst/infra/metaphor"},
+		"kubefirst/undeleted":      {ID: 3, Path: "undeleted", Name: "undeleted", PathWithNamespace: "kubefirst/undeleted"},
+		"kubefirst/2024":           {ID: 5, Path: "2024", Name: "2024", PathWithNamespace: "kubefirst/2024"},
+		"42":                       {ID: 42, Path: "legacy", Name: "legacy", PathWithNamespace: "other/legacy"},
+	}
+	lookups := 0
+	namespaces := 0
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v4/projects/", func(w http.ResponseWriter, r *http.Request) {
+		lookups++
+		project, ok := projects[r.URL.Path[len("/api/v4/projects/"):]]
+		if !ok {
+			http.Error(w, `{"message":"404 Project Not Found"}`, http.StatusNotFound)
+			return
+		}
+		json.NewEncoder(w).Encode(project)
+	})
+	mux.HandleFunc("/api/v4/namespaces/", func(w http.ResponseWriter, r *http.Request) {
+		namespaces++
+		json.NewEncoder(w).Encode(gitlab.Namespace{ID: 10, Kind: "group", Path: "kubefirst", FullPath: "kubefirst"})
+	})
+	mux.HandleFunc("/api/v4/groups/10/projects", func(w http.ResponseWriter, r *http.Request) {
+		found := make([]*gitlab.Project, 0)
+		for _, project := range projects {
+			if project.Path == r.URL.Query().Get("search") {
+				found = append(found, project)
+			}
+		}
+		json.NewEncoder(w).Encode(found)
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	client, err := gitlab.NewClient("token", gitlab.WithBaseURL(server.URL))
+	if err != nil {
+		t.Fatalf("error creating client: %s", err)
+	}
+
+	tests := []struct {
+		name      string
+		recursive bool
+		project   string
+		want      int
+		wantErr   error
+	}{
+		{name: "If the path is relative to the owner, should find the project", project: "gitops", want: 1},
+		{name: "If the path is the full path, should find the project", project: "kubefirst/gitops", want: 1},
+		{name: "If the path includes a subgroup, should find the project", project: "infra/metaphor", want: 2},
+		{name: "If the name contains deleted, should still find the project", project: "undeleted", want: 3},
+		{name: "If the id prefix is used, should return the id", project: "id:42", want: 42},
+		{name: "If a numeric name matches a project, should find the project", project: "2024", want: 5},
+		{name: "If a numeric name matches no project, should fall back to the id", project: "42", want: 42},
+		{name: "If a numeric name matches neither a project nor an id, should return an error", project: "43", wantErr: ErrProjectNotFound},
+		{name: "If a bare name is in a subgroup, should return an error", project: "metaphor", wantErr: ErrProjectNotFound},
+		{name: "If searching recursively, should find the project in a subgroup", recursive: true, project: "metaphor", want: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gl := &GitLabWrapper{
+				Client:    client,
+				Owner:     "kubefirst",
+				Recursive: tt.recursive,
+				projects:  newProjectCache(),
+				namespace: &namespaceResolver{},
+			}
+			got, err := gl.GetProjectID(tt.project)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("GetProjectID() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("GetProjectID() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+
+	t.Run("If looked up repeatedly, should use the cache", func(t *testing.T) {
+		gl := &GitLabWrapper{Client: client, Owner: "kubefirst", projects: newProjectCache(), namespace: &namespaceResolver{}}
+		before := lookups
+		for i := 0; i < 3; i++ {
+			_, err := gl.GetProjectID("gitops")
+			if err != nil {
+				t.Fatalf("GetProjectID() error = %v", err)
+			}
+		}
+		if lookups-before != 1 {
+			t.Errorf("GetProjectID() made %d lookups, want 1", lookups-before)
+		}
+	})
+
+	t.Run("If searching recursively, should resolve the owner lazily and once", func(t *testing.T) {
+		gl := &GitLabWrapper{Client: client, Owner: "kubefirst", Recursive: true, projects: newProjectCache(), namespace: &namespaceResolver{}}
+		before := namespaces
+		_, err := gl.GetProjectID("infra/metaphor")
+		if err != nil {
+			t.Fatalf("GetProjectID() error = %v", err)
+		}
+		if namespaces != before {
+			t.Errorf("lookup by path resolved the owner namespace")
+		}
+		for _, name := range []string{"metaphor", "Metaphor"} {
+			_, err := gl.GetProjectID(name)
+			if err != nil {
+				t.Fatalf("GetProjectID() error = %v", err)
+			}
+		}
+		if namespaces-before != 1 {
+			t.Errorf("owner namespace resolved %d times, want 1", namespaces-before)
+		}
+	})
+}
+
+func TestTestProjectWebhook(t *testing.T) {
+	tests := []struct {
+		name             string
+		status           int
+		message          string
+		delay            time.Duration
+		wantSuccess      bool
+		wantStatusCode   int
+		wantInconclusive bool
+		wantErr          bool
+	}{
+		{
+			name:           "If the hook accepts the event, should report success",
+			status:         http.StatusCreated,
+			wantSuccess:    true,
+			wantStatusCode: http.StatusOK,
+		},
+		{
+			name:           "If the hook rejects the event, should report its status code",
+			status:         http.StatusUnprocessableEntity,
+			message:        "Hook executed successfully but returned HTTP 502 Bad Gateway",
+			wantStatusCode: http.StatusBadGateway,
+		},
+		{
+			name:             "If the project has no commits, should be inconclusive",
+			status:           http.StatusBadRequest,
+			message:          "Ensure the project has at least one commit.",
+			wantInconclusive: true,
+		},
+		{
+			name:             "If the project has no commits on a 422, should be inconclusive",
+			status:           http.StatusUnprocessableEntity,
+			message:          "Ensure the project has at least one commit.",
+			wantInconclusive: true,
+		},
+		{
+			name:    "If the request fails otherwise, should return an error",
+			status:  http.StatusBadRequest,
+			message: "trigger does not have a valid value",
+			wantErr: true,
+		},
+		{
+			name:    "If GitLab does not answer within the timeout, should return an error",
+			status:  http.StatusCreated,
+			delay:   time.Second,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/api/v4/projects/1/hooks/2/test/push_events" {
+					http.Error(w, `{"message":"404 Not Found"}`, http.StatusNotFound)
+					return
+				}
+				select {
+				case <-time.After(tt.delay):
+				case <-r.Context().Done():
+					return
+				}
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(tt.status)
+				json.NewEncoder(w).Encode(map[string]string{"message": tt.message})
+			}))
+			defer server.Close()
+			client, err := gitlab.NewClient("token", gitlab.WithBaseURL(server.URL))
+			if err != nil {
+				t.Fatalf("error creating client: %s", err)
+			}
+			gl := &GitLabWrapper{Client: client, Owner: "kubefirst", projects: newProjectCache(), namespace: &namespaceResolver{}}
+
+			got, err := gl.TestProjectWebhook("id:1", 2, "push_events", 200*time.Millisecond)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("TestProjectWebhook() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got.Success != tt.wantSuccess || got.StatusCode != tt.wantStatusCode || got.Inconclusive != tt.wantInconclusive {
+				t.Errorf("TestProjectWebhook() = %+v, want success %v, status %d, inconclusive %v", got, tt.wantSuccess, tt.wantStatusCode, tt.wantInconclusive)
+			}
+		})
+	}
+}
@@ -1,13 +1,32 @@
 package gitlabcloud
 
-import "github.com/xanzy/go-gitlab"
+import (
+	"sync"
+	"time"
+
+	"github.com/xanzy/go-gitlab"
+)
 
 // GitLabWrapper holds gitlab cloud client info and provides and interface
 // to its functions
 type GitLabWrapper struct {
-	Client          *gitlab.Client
-	ParentGroupID   int
-	ParentGroupPath string
+	Client *gitlab.Client
+	// Owner is the full path of the group or user owning managed projects
+	Owner string
+	// Recursive includes subgroups when listing projects and when looking
+	// up a project by bare name
+	Recursive bool
+
+	projects  *projectCache
+	namespace *namespaceResolver
+}
+
+// namespaceResolver resolves the owner namespace once, shared by copies of a
+// wrapper
+type namespaceResolver struct {
+	once sync.Once
+	ns   *gitlab.Namespace
+	err  error
 }
 
 // DeployTokenCreateParameters holds values to be passed to a function to create
@@ -22,7 +41,34 @@ type DeployTokenCreateParameters struct {
 // project hooks
 type ProjectHookRequest struct {
 	ProjectName string
+	// Strict makes delete and update fail when more than one hook matches
+	// the url instead of acting on all of them
+	Strict bool
 
 	CreateOpts *gitlab.AddProjectHookOptions
 	PatchOpts  *gitlab.EditProjectHookOptions
 }
+
+// ProjectHookTestResult is the outcome of sending a test event to a hook
+type ProjectHookTestResult struct {
+	// Success is set if the hook answered with a 2xx status code
+	Success bool
+	// StatusCode is the status code the hook answered with, 0 if unknown
+	StatusCode int
+	Message    string
+	// Inconclusive is set if GitLab could not send the test event, such as
+	// a push event to a project without commits
+	Inconclusive bool
+}
+
+// ProjectHookEvent is a delivery recorded by GitLab for a project hook
+type ProjectHookEvent struct {
+	ID                int        `json:"id"`
+	URL               string     `json:"url"`
+	Trigger           string     `json:"trigger"`
+	ResponseStatus    string     `json:"response_status"`
+	ExecutionDuration float64    `json:"execution_duration"`
+	CreatedAt         *time.Time `json:"created_at"`
+	// RequestHeaders are the headers the event was sent with
+	RequestHeaders map[string]string `json:"request_headers"`
+}
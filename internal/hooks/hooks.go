@@ -0,0 +1,168 @@
+package hooks
+
+import (
+	"fmt"
+
+	"github.com/xanzy/go-gitlab"
+)
+
+// Provider-neutral event names
+const (
+	EventPush              string = "push"
+	EventTagPush           string = "tag_push"
+	EventPullRequest       string = "pull_request"
+	EventPullRequestReview string = "pull_request_review"
+	EventComment           string = "comment"
+	EventIssues            string = "issues"
+	EventPipeline          string = "pipeline"
+	EventJob               string = "job"
+	EventRelease           string = "release"
+
+	ContentTypeJSON string = "json"
+	ContentTypeForm string = "form"
+)
+
+// AllowedEvents lists the provider-neutral event names
+var AllowedEvents []string = []string{EventPush, EventTagPush, EventPullRequest, EventPullRequestReview, EventComment, EventIssues, EventPipeline, EventJob, EventRelease}
+
+// DefaultEvents are the events Atlantis needs, used when none are given
+var DefaultEvents []string = []string{EventPullRequestReview, EventPush, EventComment, EventPullRequest}
+
+// githubEvents maps neutral event names to GitHub event names
+var githubEvents map[string]string = map[string]string{
+	EventPush:              "push",
+	EventTagPush:           "create",
+	EventPullRequest:       "pull_request",
+	EventPullRequestReview: "pull_request_review",
+	EventComment:           "issue_comment",
+	EventIssues:            "issues",
+	EventPipeline:          "workflow_run",
+	EventJob:               "workflow_job",
+	EventRelease:           "release",
+}
+
+// Validate checks the options that do not depend on the provider
+func (o Options) Validate() error {
+	switch o.ContentType {
+	case "", ContentTypeJSON, ContentTypeForm:
+	default:
+		return fmt.Errorf("unsupported content type %s, must be %s or %s", o.ContentType, ContentTypeJSON, ContentTypeForm)
+	}
+	return nil
+}
+
+// IsActive reports whether the hook should be active
+func (o Options) IsActive() bool {
+	return o.Active == nil || *o.Active
+}
+
+// GitHubEvents returns the GitHub event names for the options
+// Names without a neutral mapping are passed through as GitHub event names
+func (o Options) GitHubEvents() []string {
+	events := o.Events
+	if len(events) == 0 {
+		events = DefaultEvents
+	}
+
+	names := make([]string, 0, len(events))
+	seen := make(map[string]bool)
+	for _, event := range events {
+		name, ok := githubEvents[event]
+		if !ok {
+			name = event
+		}
+		if !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// GitHubConfig returns the GitHub hook config for url and secret
+func (o Options) GitHubConfig(url string, secret string) map[string]interface{} {
+	contentType := o.ContentType
+	if contentType == "" {
+		contentType = ContentTypeJSON
+	}
+	insecureSSL := "0"
+	if o.InsecureSSL {
+		insecureSSL = "1"
+	}
+	return map[string]interface{}{
+		"content_type": contentType,
+		"insecure_ssl": insecureSSL,
+		"url":          url,
+		"secret":       secret,
+	}
+}
+
+// GitLabHookOptions returns GitLab hook options for url and token
+// Neutral and GitLab event names are accepted, anything else is an error
+func (o Options) GitLabHookOptions(url string, token string) (*gitlab.AddProjectHookOptions, error) {
+	var enabled bool = true
+	sslVerification := !o.InsecureSSL && (o.GitLab.SSLVerification == nil || *o.GitLab.SSLVerification)
+	opts := &gitlab.AddProjectHookOptions{
+		URL:                   &url,
+		Token:                 &token,
+		EnableSSLVerification: &sslVerification,
+	}
+
+	events := o.Events
+	if len(events) == 0 {
+		events = DefaultEvents
+	}
+	for _, event := range events {
+		switch event {
+		case EventPush:
+			opts.PushEvents = &enabled
+		case EventTagPush:
+			opts.TagPushEvents = &enabled
+		case EventPullRequest, "merge_request", "merge_requests":
+			opts.MergeRequestsEvents = &enabled
+		// GitLab reports reviews and comments as notes
+		case EventPullRequestReview, EventComment, "note":
+			opts.NoteEvents = &enabled
+		case EventIssues:
+			opts.IssuesEvents = &enabled
+		case EventPipeline:
+			opts.PipelineEvents = &enabled
+		case EventJob:
+			opts.JobEvents = &enabled
+		case EventRelease, "releases":
+			opts.ReleasesEvents = &enabled
+		case "deployment":
+			opts.DeploymentEvents = &enabled
+		case "wiki_page":
+			opts.WikiPageEvents = &enabled
+		default:
+			return nil, fmt.Errorf("unsupported gitlab event %s", event)
+		}
+	}
+
+	if o.GitLab.TagPushEvents {
+		opts.TagPushEvents = &enabled
+	}
+	if o.GitLab.PipelineEvents {
+		opts.PipelineEvents = &enabled
+	}
+	if o.GitLab.JobEvents {
+		opts.JobEvents = &enabled
+	}
+	if o.GitLab.ReleasesEvents {
+		opts.ReleasesEvents = &enabled
+	}
+
+	return opts, nil
+}
+
+// GitLabEditHookOptions returns GitLab options to update a hook to url and
+// token, with the same settings as GitLabHookOptions
+func (o Options) GitLabEditHookOptions(url string, token string) (*gitlab.EditProjectHookOptions, error) {
+	opts, err := o.GitLabHookOptions(url, token)
+	if err != nil {
+		return nil, err
+	}
+	edit := gitlab.EditProjectHookOptions(*opts)
+	return &edit, nil
+}
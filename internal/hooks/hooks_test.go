@@ -0,0 +1,108 @@
+package hooks
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestGitHubEvents(t *testing.T) {
+	tests := []struct {
+		name   string
+		events []string
+		want   []string
+	}{
+		{
+			name: "If no events are given, should use the defaults",
+			want: []string{"pull_request_review", "push", "issue_comment", "pull_request"},
+		},
+		{
+			name:   "If neutral names are given, should map them to github events",
+			events: []string{EventComment, EventTagPush, EventRelease},
+			want:   []string{"issue_comment", "create", "release"},
+		},
+		{
+			name:   "If github names are given, should pass them through once",
+			events: []string{"issue_comment", EventComment, "deployment"},
+			want:   []string{"issue_comment", "deployment"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Options{Events: tt.events}.GitHubEvents()
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("GitHubEvents() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGitLabHookOptions(t *testing.T) {
+	disabled := false
+	tests := []struct {
+		name    string
+		opts    Options
+		want    map[string]bool
+		wantSSL bool
+		wantErr bool
+	}{
+		{
+			name:    "If no options are given, should use the defaults",
+			want:    map[string]bool{"merge_requests": true, "note": true, "push": true},
+			wantSSL: true,
+		},
+		{
+			name: "If toggles and insecure ssl are set, should enable them",
+			opts: Options{
+				Events:      []string{EventPush},
+				InsecureSSL: true,
+				GitLab:      GitLabOptions{PipelineEvents: true, ReleasesEvents: true},
+			},
+			want: map[string]bool{"push": true, "pipeline": true, "releases": true},
+		},
+		{
+			name:    "If gitlab names and disabled ssl verification are set, should use them",
+			opts:    Options{Events: []string{"merge_requests", "tag_push"}, GitLab: GitLabOptions{SSLVerification: &disabled}},
+			want:    map[string]bool{"merge_requests": true, "tag_push": true},
+			wantSSL: false,
+		},
+		{
+			name:    "If an event is unknown, should return an error",
+			opts:    Options{Events: []string{"star"}},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.opts.GitLabHookOptions("https://example.com/events", "token")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("GitLabHookOptions() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			enabled := make(map[string]bool)
+			for name, value := range map[string]*bool{
+				"push":           got.PushEvents,
+				"tag_push":       got.TagPushEvents,
+				"merge_requests": got.MergeRequestsEvents,
+				"note":           got.NoteEvents,
+				"issues":         got.IssuesEvents,
+				"pipeline":       got.PipelineEvents,
+				"job":            got.JobEvents,
+				"releases":       got.ReleasesEvents,
+			} {
+				if value != nil && *value {
+					enabled[name] = true
+				}
+			}
+			if !reflect.DeepEqual(enabled, tt.want) {
+				t.Errorf("GitLabHookOptions() events = %v, want %v", enabled, tt.want)
+			}
+			if *got.EnableSSLVerification != tt.wantSSL {
+				t.Errorf("GitLabHookOptions() ssl verification = %v, want %v", *got.EnableSSLVerification, tt.wantSSL)
+			}
+		})
+	}
+}
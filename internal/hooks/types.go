@@ -0,0 +1,27 @@
+package hooks
+
+// Options holds provider-neutral settings for a repository or project hook
+type Options struct {
+	// Events lists neutral event names, see AllowedEvents, or provider
+	// specific event names. The provider defaults are used if empty
+	Events []string
+	// ContentType is the GitHub payload format, json (default) or form
+	ContentType string
+	// InsecureSSL disables TLS certificate verification of deliveries
+	InsecureSSL bool
+	// Active controls whether GitHub delivers to the hook, true if nil
+	Active *bool
+
+	// GitLab adds GitLab specific toggles on top of Events
+	GitLab GitLabOptions
+}
+
+// GitLabOptions holds GitLab specific hook toggles
+type GitLabOptions struct {
+	TagPushEvents  bool
+	PipelineEvents bool
+	JobEvents      bool
+	ReleasesEvents bool
+	// SSLVerification is combined with InsecureSSL, verification is on if nil
+	SSLVerification *bool
+}
@@ -1,12 +1,14 @@
 package kubernetes
 
 import (
+	"fmt"
 	"os"
 	"path/filepath"
 
 	"github.com/kubefirst/git-helper/internal/common"
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/afero"
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
@@ -15,50 +17,74 @@ import (
 
 var fs afero.Fs = afero.NewOsFs()
 
-// CreateKubeConfig
-func CreateKubeConfig(inCluster bool) (*rest.Config, kubernetes.Interface, string) {
-	// inCluster is either true or false
-	// If it's true, we pull Kubernetes API authentication from Pod SA
-	// If it's false, we use local machine settings
-	if inCluster {
-		config, err := rest.InClusterConfig()
-		if err != nil {
-			panic(err.Error())
-		}
+// NewClient builds a Kubernetes client from the given options
+func NewClient(opts ClientOptions) (*Client, error) {
+	config, err := CreateKubeConfig(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	clientset, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return nil, fmt.Errorf("error creating kubernetes clientset: %s", err)
+	}
+
+	dynamicClient, err := dynamic.NewForConfig(config)
+	if err != nil {
+		return nil, fmt.Errorf("error creating kubernetes dynamic client: %s", err)
+	}
 
-		clientset, err := kubernetes.NewForConfig(config)
+	return &Client{
+		Config:    config,
+		Clientset: clientset,
+		Dynamic:   dynamicClient,
+	}, nil
+}
+
+// NewClientFromInterface wraps existing clients, such as the client-go fakes
+func NewClientFromInterface(clientset kubernetes.Interface, dynamicClient dynamic.Interface) *Client {
+	return &Client{
+		Clientset: clientset,
+		Dynamic:   dynamicClient,
+	}
+}
+
+// CreateKubeConfig builds a REST config for the Kubernetes API
+func CreateKubeConfig(opts ClientOptions) (*rest.Config, error) {
+	// If running in-cluster, we pull Kubernetes API authentication from Pod SA
+	// Otherwise, we use local machine settings
+	if opts.InCluster {
+		config, err := rest.InClusterConfig()
 		if err != nil {
-			panic(err.Error())
+			return nil, fmt.Errorf("error loading in-cluster kubeconfig: %s", err)
 		}
-
-		return config, clientset, "in-cluster"
+		return config, nil
 	}
 
 	// Set path to kubeconfig
-	kubeconfig := ReturnKubeConfigPath()
+	kubeconfig := opts.Kubeconfig
+	if kubeconfig == "" {
+		kubeconfig = ReturnKubeConfigPath()
+	}
 
 	// Check to make sure kubeconfig actually exists
-	// If it doesn't, go fetch it
-	if common.FileExists(fs, kubeconfig) {
-		log.Debug("kubeconfig exists, moving on.")
+	if !common.FileExists(fs, kubeconfig) {
+		return nil, fmt.Errorf("unable to locate kubeconfig file - checked path: %s", kubeconfig)
 	}
 
 	// Show what path was set for kubeconfig
 	log.Debugf("setting kubeconfig to: %s", kubeconfig)
 
-	// Build configuration instance from the provided config file
-	config, err := clientcmd.BuildConfigFromFlags("", kubeconfig)
-	if err != nil {
-		log.Fatalf("unable to locate kubeconfig file - checked path: %s", kubeconfig)
-	}
-
-	// Create clientset, which is used to run operations against the API
-	clientset, err := kubernetes.NewForConfig(config)
+	// Build configuration instance from the provided config file and context
+	config, err := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(
+		&clientcmd.ClientConfigLoadingRules{ExplicitPath: kubeconfig},
+		&clientcmd.ConfigOverrides{CurrentContext: opts.Context},
+	).ClientConfig()
 	if err != nil {
-		panic(err)
+		return nil, fmt.Errorf("error loading kubeconfig %s: %s", kubeconfig, err)
 	}
 
-	return config, clientset, kubeconfig
+	return config, nil
 }
 
 // ReturnKubeConfigPath generates the path in the filesystem to kubeconfig
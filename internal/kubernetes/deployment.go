@@ -0,0 +1,129 @@
+package kubernetes
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+// deploymentPollInterval is how often a Deployment is checked while waiting for it
+const deploymentPollInterval time.Duration = 2 * time.Second
+
+// ReadDeploymentPodRestarts returns the total container restart count of each
+// pod belonging to a Deployment, keyed by pod name
+func (k *Client) ReadDeploymentPodRestarts(namespace string, deploymentName string) (map[string]int32, error) {
+	pods, err := k.listDeploymentPods(namespace, deploymentName)
+	if err != nil {
+		return map[string]int32{}, err
+	}
+
+	restarts := make(map[string]int32)
+	for _, pod := range pods {
+		restarts[pod.Name] = podRestartCount(pod)
+	}
+
+	return restarts, nil
+}
+
+// WaitForDeploymentRestart waits until a Deployment has finished rolling out and
+// has a ready pod that is either new or has restarted since the snapshot taken
+// with ReadDeploymentPodRestarts
+func (k *Client) WaitForDeploymentRestart(namespace string, deploymentName string, before map[string]int32, timeout time.Duration) error {
+	err := wait.PollUntilContextTimeout(context.Background(), deploymentPollInterval, timeout, true, func(ctx context.Context) (bool, error) {
+		deployment, err := k.Clientset.AppsV1().Deployments(namespace).Get(ctx, deploymentName, metav1.GetOptions{})
+		if err != nil {
+			return false, fmt.Errorf("error getting Deployment: %s", err)
+		}
+		if !deploymentRolledOut(deployment) {
+			log.Infof("waiting for Deployment %s in Namespace %s to roll out", deploymentName, namespace)
+			return false, nil
+		}
+
+		pods, err := k.listDeploymentPods(namespace, deploymentName)
+		if err != nil {
+			return false, err
+		}
+		for _, pod := range pods {
+			if !podReady(pod) {
+				continue
+			}
+			restarts, existed := before[pod.Name]
+			if !existed || podRestartCount(pod) > restarts {
+				return true, nil
+			}
+		}
+		log.Infof("waiting for Deployment %s in Namespace %s to restart", deploymentName, namespace)
+		return false, nil
+	})
+	if err != nil {
+		return fmt.Errorf("error waiting for Deployment %s in Namespace %s to restart: %s", deploymentName, namespace, err)
+	}
+	log.Infof("Deployment %s in Namespace %s restarted", deploymentName, namespace)
+
+	return nil
+}
+
+// listDeploymentPods returns the pods selected by a Deployment
+func (k *Client) listDeploymentPods(namespace string, deploymentName string) ([]v1.Pod, error) {
+	deployment, err := k.Clientset.AppsV1().Deployments(namespace).Get(context.Background(), deploymentName, metav1.GetOptions{})
+	if err != nil {
+		return []v1.Pod{}, fmt.Errorf("error getting Deployment: %s", err)
+	}
+
+	selector, err := metav1.LabelSelectorAsSelector(deployment.Spec.Selector)
+	if err != nil {
+		return []v1.Pod{}, fmt.Errorf("error parsing Deployment selector: %s", err)
+	}
+
+	pods, err := k.Clientset.CoreV1().Pods(namespace).List(context.Background(), metav1.ListOptions{
+		LabelSelector: selector.String(),
+	})
+	if err != nil {
+		return []v1.Pod{}, fmt.Errorf("error listing pods: %s", err)
+	}
+
+	return pods.Items, nil
+}
+
+// deploymentRolledOut reports whether the latest Deployment spec has been
+// observed and all of its replicas are updated and available
+func deploymentRolledOut(deployment *appsv1.Deployment) bool {
+	var replicas int32 = 1
+	if deployment.Spec.Replicas != nil {
+		replicas = *deployment.Spec.Replicas
+	}
+	status := deployment.Status
+
+	return status.ObservedGeneration >= deployment.Generation &&
+		status.UpdatedReplicas == replicas &&
+		status.Replicas == replicas &&
+		status.AvailableReplicas == replicas
+}
+
+// podReady reports whether a pod is running, not terminating and ready
+func podReady(pod v1.Pod) bool {
+	if pod.DeletionTimestamp != nil || pod.Status.Phase != v1.PodRunning {
+		return false
+	}
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == v1.PodReady {
+			return condition.Status == v1.ConditionTrue
+		}
+	}
+	return false
+}
+
+// podRestartCount sums the restart counts of all containers in a pod
+func podRestartCount(pod v1.Pod) int32 {
+	var restarts int32 = 0
+	for _, status := range pod.Status.ContainerStatuses {
+		restarts += status.RestartCount
+	}
+	return restarts
+}
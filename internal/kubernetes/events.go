@@ -0,0 +1,104 @@
+package kubernetes
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// eventComponent is reported as the source of Events created by this tool
+const eventComponent string = "git-helper"
+
+// ObjectReference returns a reference to a ConfigMap or Deployment that
+// Events can be recorded against
+func (k *Client) ObjectReference(kind string, namespace string, name string) (*v1.ObjectReference, error) {
+	var meta metav1.Object
+	var apiVersion string
+	switch kind {
+	case "ConfigMap":
+		configMap, err := k.Clientset.CoreV1().ConfigMaps(namespace).Get(context.Background(), name, metav1.GetOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("error getting ConfigMap: %s", err)
+		}
+		meta, apiVersion = configMap, "v1"
+	case "Deployment":
+		deployment, err := k.Clientset.AppsV1().Deployments(namespace).Get(context.Background(), name, metav1.GetOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("error getting Deployment: %s", err)
+		}
+		meta, apiVersion = deployment, "apps/v1"
+	default:
+		return nil, fmt.Errorf("unsupported event object kind %s, must be ConfigMap or Deployment", kind)
+	}
+
+	return &v1.ObjectReference{
+		Kind:            kind,
+		APIVersion:      apiVersion,
+		Namespace:       namespace,
+		Name:            name,
+		UID:             meta.GetUID(),
+		ResourceVersion: meta.GetResourceVersion(),
+	}, nil
+}
+
+// RecordEvent creates an Event on the referenced object
+// eventType is one of v1.EventTypeNormal or v1.EventTypeWarning
+func (k *Client) RecordEvent(ref *v1.ObjectReference, eventType string, reason string, message string) error {
+	now := metav1.NewTime(time.Now())
+	event := &v1.Event{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      fmt.Sprintf("%s.%x", ref.Name, now.UnixNano()),
+			Namespace: ref.Namespace,
+		},
+		InvolvedObject: *ref,
+		Reason:         reason,
+		Message:        message,
+		Type:           eventType,
+		FirstTimestamp: now,
+		LastTimestamp:  now,
+		Count:          1,
+		Source: v1.EventSource{
+			Component: eventComponent,
+		},
+	}
+
+	_, err := k.Clientset.CoreV1().Events(ref.Namespace).Create(context.Background(), event, metav1.CreateOptions{})
+	if err != nil {
+		return fmt.Errorf("error creating Event: %s", err)
+	}
+	log.Debugf("recorded %s Event %s on %s %s/%s", eventType, reason, ref.Kind, ref.Namespace, ref.Name)
+
+	return nil
+}
+
+// AnnotateObject merges annotations into the referenced ConfigMap or Deployment
+func (k *Client) AnnotateObject(ref *v1.ObjectReference, annotations map[string]string) error {
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"annotations": annotations,
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	switch ref.Kind {
+	case "ConfigMap":
+		_, err = k.Clientset.CoreV1().ConfigMaps(ref.Namespace).Patch(context.Background(), ref.Name, types.MergePatchType, patch, metav1.PatchOptions{FieldManager: fieldManager})
+	case "Deployment":
+		_, err = k.Clientset.AppsV1().Deployments(ref.Namespace).Patch(context.Background(), ref.Name, types.MergePatchType, patch, metav1.PatchOptions{FieldManager: fieldManager})
+	default:
+		return fmt.Errorf("unsupported annotation object kind %s, must be ConfigMap or Deployment", ref.Kind)
+	}
+	if err != nil {
+		return fmt.Errorf("error annotating %s %s: %s", ref.Kind, ref.Name, err)
+	}
+
+	return nil
+}
@@ -2,18 +2,23 @@ package kubernetes
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 
 	log "github.com/sirupsen/logrus"
 	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/retry"
 )
 
-// CreateSecretV2
-func CreateSecretV2(inCluster bool, secret *v1.Secret) error {
-	_, clientset, _ := CreateKubeConfig(inCluster)
+// fieldManager identifies changes made by this tool in managed fields
+const fieldManager string = "git-helper"
 
-	_, err := clientset.CoreV1().Secrets(secret.Namespace).Create(
+// CreateSecret
+func (k *Client) CreateSecret(secret *v1.Secret) error {
+	_, err := k.Clientset.CoreV1().Secrets(secret.Namespace).Create(
 		context.Background(),
 		secret,
 		metav1.CreateOptions{},
@@ -25,13 +30,11 @@ func CreateSecretV2(inCluster bool, secret *v1.Secret) error {
 	return nil
 }
 
-// ReadConfigMapV2
-func ReadConfigMapV2(inCluster bool, namespace string, configMapName string) (map[string]string, error) {
-	_, clientset, _ := CreateKubeConfig(inCluster)
-
-	configMap, err := clientset.CoreV1().ConfigMaps(namespace).Get(context.Background(), configMapName, metav1.GetOptions{})
+// ReadConfigMap
+func (k *Client) ReadConfigMap(namespace string, configMapName string) (map[string]string, error) {
+	configMap, err := k.Clientset.CoreV1().ConfigMaps(namespace).Get(context.Background(), configMapName, metav1.GetOptions{})
 	if err != nil {
-		return map[string]string{}, fmt.Errorf("error getting ConfigMap: %s", err)
+		return map[string]string{}, fmt.Errorf("error getting ConfigMap: %w", err)
 	}
 
 	parsedSecretData := make(map[string]string)
@@ -42,13 +45,11 @@ func ReadConfigMapV2(inCluster bool, namespace string, configMapName string) (ma
 	return parsedSecretData, nil
 }
 
-// ReadSecretV2
-func ReadSecretV2(inCluster bool, namespace string, secretName string) (map[string]string, error) {
-	_, clientset, _ := CreateKubeConfig(inCluster)
-
-	secret, err := clientset.CoreV1().Secrets(namespace).Get(context.Background(), secretName, metav1.GetOptions{})
+// ReadSecret
+func (k *Client) ReadSecret(namespace string, secretName string) (map[string]string, error) {
+	secret, err := k.Clientset.CoreV1().Secrets(namespace).Get(context.Background(), secretName, metav1.GetOptions{})
 	if err != nil {
-		return map[string]string{}, fmt.Errorf("error getting secret: %s", err)
+		return map[string]string{}, fmt.Errorf("error getting secret: %w", err)
 	}
 
 	parsedSecretData := make(map[string]string)
@@ -59,25 +60,167 @@ func ReadSecretV2(inCluster bool, namespace string, secretName string) (map[stri
 	return parsedSecretData, nil
 }
 
-// UpdateConfigMapV2
-func UpdateConfigMapV2(inCluster bool, namespace, configMapName string, key string, value string) error {
-	_, clientset, _ := CreateKubeConfig(inCluster)
+// UpdateConfigMap sets a single key in a ConfigMap, preserving all other keys
+func (k *Client) UpdateConfigMap(namespace, configMapName string, key string, value string) error {
+	return k.PatchConfigMap(namespace, configMapName, map[string]string{key: value})
+}
+
+// PatchConfigMap sets the given keys in a ConfigMap with a strategic merge patch,
+// preserving all other keys
+// The ConfigMap is created if it does not exist, and the patch is retried if
+// the ConfigMap changes between reading and writing it
+func (k *Client) PatchConfigMap(namespace, configMapName string, data map[string]string) error {
+	err := retry.OnError(retry.DefaultRetry, func(err error) bool {
+		return errors.IsConflict(err) || errors.IsAlreadyExists(err)
+	}, func() error {
+		configMap, err := k.Clientset.CoreV1().ConfigMaps(namespace).Get(context.Background(), configMapName, metav1.GetOptions{})
+		if errors.IsNotFound(err) {
+			_, err = k.Clientset.CoreV1().ConfigMaps(namespace).Create(
+				context.Background(),
+				&v1.ConfigMap{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      configMapName,
+						Namespace: namespace,
+					},
+					Data: data,
+				},
+				metav1.CreateOptions{FieldManager: fieldManager},
+			)
+			return err
+		}
+		if err != nil {
+			return fmt.Errorf("error getting ConfigMap: %s", err)
+		}
+
+		// Include the resourceVersion so the patch fails with a conflict
+		// if the ConfigMap was modified after it was read
+		patch, err := json.Marshal(map[string]interface{}{
+			"metadata": map[string]interface{}{
+				"resourceVersion": configMap.ResourceVersion,
+			},
+			"data": data,
+		})
+		if err != nil {
+			return err
+		}
+
+		_, err = k.Clientset.CoreV1().ConfigMaps(namespace).Patch(
+			context.Background(),
+			configMapName,
+			types.StrategicMergePatchType,
+			patch,
+			metav1.PatchOptions{FieldManager: fieldManager},
+		)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("error updating ConfigMap %s in Namespace %s: %s", configMapName, namespace, err)
+	}
+	log.Infof("updated ConfigMap %s in Namespace %s\n", configMapName, namespace)
+
+	return nil
+}
 
-	configMap, err := clientset.CoreV1().ConfigMaps(namespace).Get(context.Background(), configMapName, metav1.GetOptions{})
+// RemoveConfigMapKeys deletes the given keys from a ConfigMap, preserving all
+// other keys
+func (k *Client) RemoveConfigMapKeys(namespace, configMapName string, keys ...string) error {
+	patch, err := removeKeysPatch("data", keys)
 	if err != nil {
-		return fmt.Errorf("error getting ConfigMap: %s", err)
+		return err
 	}
 
-	configMap.Data = map[string]string{key: value}
-	_, err = clientset.CoreV1().ConfigMaps(namespace).Update(
+	_, err = k.Clientset.CoreV1().ConfigMaps(namespace).Patch(
 		context.Background(),
-		configMap,
-		metav1.UpdateOptions{},
+		configMapName,
+		types.MergePatchType,
+		patch,
+		metav1.PatchOptions{FieldManager: fieldManager},
 	)
+	if errors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("error updating ConfigMap %s in Namespace %s: %s", configMapName, namespace, err)
+	}
+
+	return nil
+}
+
+// PatchSecret sets the given keys in a Secret, preserving all other keys
+// The Secret is created if it does not exist
+func (k *Client) PatchSecret(namespace, secretName string, data map[string]string) error {
+	encoded := make(map[string][]byte)
+	for key, value := range data {
+		encoded[key] = []byte(value)
+	}
+
+	err := retry.OnError(retry.DefaultRetry, errors.IsAlreadyExists, func() error {
+		patch, err := json.Marshal(map[string]interface{}{
+			"data": encoded,
+		})
+		if err != nil {
+			return err
+		}
+
+		_, err = k.Clientset.CoreV1().Secrets(namespace).Patch(
+			context.Background(),
+			secretName,
+			types.MergePatchType,
+			patch,
+			metav1.PatchOptions{FieldManager: fieldManager},
+		)
+		if errors.IsNotFound(err) {
+			_, err = k.Clientset.CoreV1().Secrets(namespace).Create(
+				context.Background(),
+				&v1.Secret{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      secretName,
+						Namespace: namespace,
+					},
+					Data: encoded,
+				},
+				metav1.CreateOptions{FieldManager: fieldManager},
+			)
+		}
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("error updating Secret %s in Namespace %s: %s", secretName, namespace, err)
+	}
+
+	return nil
+}
+
+// RemoveSecretKeys deletes the given keys from a Secret, preserving all
+// other keys
+func (k *Client) RemoveSecretKeys(namespace, secretName string, keys ...string) error {
+	patch, err := removeKeysPatch("data", keys)
 	if err != nil {
 		return err
 	}
-	log.Infof("updated ConfigMap %s in Namespace %s\n", configMap.Name, configMap.Namespace)
+
+	_, err = k.Clientset.CoreV1().Secrets(namespace).Patch(
+		context.Background(),
+		secretName,
+		types.MergePatchType,
+		patch,
+		metav1.PatchOptions{FieldManager: fieldManager},
+	)
+	if errors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("error updating Secret %s in Namespace %s: %s", secretName, namespace, err)
+	}
 
 	return nil
 }
+
+// removeKeysPatch builds a merge patch that deletes keys from a map field
+func removeKeysPatch(field string, keys []string) ([]byte, error) {
+	remove := make(map[string]interface{})
+	for _, key := range keys {
+		remove[key] = nil
+	}
+	return json.Marshal(map[string]interface{}{field: remove})
+}
@@ -0,0 +1,70 @@
+package kubernetes
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestPatchConfigMap(t *testing.T) {
+	type args struct {
+		existing []runtime.Object
+		data     map[string]string
+	}
+	tests := []struct {
+		name string
+		args args
+		want map[string]string
+	}{
+		{
+			name: "If the ConfigMap exists, should preserve other keys",
+			args: args{
+				existing: []runtime.Object{
+					&v1.ConfigMap{
+						ObjectMeta: metav1.ObjectMeta{Name: "ngrok", Namespace: "atlantis"},
+						Data: map[string]string{
+							"active-ngrok-tunnel-url": "https://old.ngrok.io",
+							"other":                   "value",
+						},
+					},
+				},
+				data: map[string]string{"active-ngrok-tunnel-url": "https://new.ngrok.io"},
+			},
+			want: map[string]string{
+				"active-ngrok-tunnel-url": "https://new.ngrok.io",
+				"other":                   "value",
+			},
+		},
+		{
+			name: "If the ConfigMap does not exist, should create it",
+			args: args{
+				existing: []runtime.Object{},
+				data:     map[string]string{"active-ngrok-tunnel-url": "https://new.ngrok.io"},
+			},
+			want: map[string]string{"active-ngrok-tunnel-url": "https://new.ngrok.io"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			kube := NewClientFromInterface(fake.NewSimpleClientset(tt.args.existing...), nil)
+
+			err := kube.PatchConfigMap("atlantis", "ngrok", tt.args.data)
+			if err != nil {
+				t.Fatalf("PatchConfigMap() error = %v", err)
+			}
+
+			configMap, err := kube.Clientset.CoreV1().ConfigMaps("atlantis").Get(context.Background(), "ngrok", metav1.GetOptions{})
+			if err != nil {
+				t.Fatalf("error getting ConfigMap: %v", err)
+			}
+			if !reflect.DeepEqual(configMap.Data, tt.want) {
+				t.Errorf("PatchConfigMap() data = %v, want %v", configMap.Data, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,154 @@
+package kubernetes
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+	log "github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/leaderelection"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+)
+
+const (
+	defaultLeaseDuration time.Duration = 15 * time.Second
+	defaultRenewDeadline time.Duration = 10 * time.Second
+	defaultRetryPeriod   time.Duration = 2 * time.Second
+)
+
+// WithLease runs fn while holding a Lease, so that only one instance at a time
+// performs the operation it guards
+// It waits up to opts.AcquireTimeout for the Lease, and releases it once fn returns
+// If the Lease is lost before fn returns, WithLease still waits for fn and
+// then returns an error
+func (k *Client) WithLease(ctx context.Context, opts LeaseOptions, fn func() error) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var acquired atomic.Bool
+	// done carries the result of fn, which runs in the elector goroutine
+	done := make(chan error, 1)
+	elector, err := k.newLeaderElector(opts, leaderelection.LeaderCallbacks{
+		OnStartedLeading: func(_ context.Context) {
+			acquired.Store(true)
+			log.Infof("acquired Lease %s in Namespace %s", opts.Name, opts.Namespace)
+			done <- fn()
+			cancel()
+		},
+		OnStoppedLeading: func() {},
+	})
+	if err != nil {
+		return err
+	}
+
+	if opts.AcquireTimeout > 0 {
+		timer := time.AfterFunc(opts.AcquireTimeout, func() {
+			if !acquired.Load() {
+				cancel()
+			}
+		})
+		defer timer.Stop()
+	}
+
+	log.Infof("waiting for Lease %s in Namespace %s", opts.Name, opts.Namespace)
+	elector.Run(ctx)
+	if !acquired.Load() {
+		return fmt.Errorf("could not acquire Lease %s in Namespace %s", opts.Name, opts.Namespace)
+	}
+
+	// The elector stops once fn is done, unless the Lease was lost first
+	select {
+	case fnErr := <-done:
+		log.Infof("released Lease %s in Namespace %s", opts.Name, opts.Namespace)
+		return fnErr
+	default:
+	}
+	log.Warnf("lost Lease %s in Namespace %s, waiting for the guarded operation to finish", opts.Name, opts.Namespace)
+	fnErr := <-done
+	if fnErr != nil {
+		return fmt.Errorf("lost Lease %s in Namespace %s before the operation finished: %s", opts.Name, opts.Namespace, fnErr)
+	}
+	return fmt.Errorf("lost Lease %s in Namespace %s before the operation finished", opts.Name, opts.Namespace)
+}
+
+// RunWithLeaderElection runs run only while this instance holds the leader Lease
+// An error is returned if leadership is lost before run returns, so the
+// process can exit and let another replica take over
+func (k *Client) RunWithLeaderElection(ctx context.Context, opts LeaseOptions, run func(ctx context.Context) error) error {
+	parent := ctx
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var finished atomic.Bool
+	var lost atomic.Bool
+	var runErr error
+	elector, err := k.newLeaderElector(opts, leaderelection.LeaderCallbacks{
+		OnStartedLeading: func(leaderCtx context.Context) {
+			log.Infof("started leading with Lease %s in Namespace %s", opts.Name, opts.Namespace)
+			runErr = run(leaderCtx)
+			finished.Store(true)
+			cancel()
+		},
+		OnStoppedLeading: func() {
+			if !finished.Load() && parent.Err() == nil {
+				lost.Store(true)
+			}
+			cancel()
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	elector.Run(ctx)
+	if lost.Load() {
+		return fmt.Errorf("lost leadership of Lease %s in Namespace %s", opts.Name, opts.Namespace)
+	}
+
+	return runErr
+}
+
+// newLeaderElector builds a Lease based leader elector that releases the Lease
+// when its context is cancelled
+func (k *Client) newLeaderElector(opts LeaseOptions, callbacks leaderelection.LeaderCallbacks) (*leaderelection.LeaderElector, error) {
+	identity := opts.Identity
+	if identity == "" {
+		hostname, _ := os.Hostname()
+		identity = fmt.Sprintf("%s_%s", hostname, uuid.New().String())
+	}
+
+	lock := &resourcelock.LeaseLock{
+		LeaseMeta: metav1.ObjectMeta{
+			Name:      opts.Name,
+			Namespace: opts.Namespace,
+		},
+		Client: k.Clientset.CoordinationV1(),
+		LockConfig: resourcelock.ResourceLockConfig{
+			Identity: identity,
+		},
+	}
+
+	config := leaderelection.LeaderElectionConfig{
+		Lock:            lock,
+		LeaseDuration:   defaultLeaseDuration,
+		RenewDeadline:   defaultRenewDeadline,
+		RetryPeriod:     defaultRetryPeriod,
+		ReleaseOnCancel: true,
+		Name:            opts.Name,
+		Callbacks:       callbacks,
+	}
+	if opts.LeaseDuration > 0 {
+		config.LeaseDuration = opts.LeaseDuration
+	}
+
+	elector, err := leaderelection.NewLeaderElector(config)
+	if err != nil {
+		return nil, fmt.Errorf("error creating leader elector: %s", err)
+	}
+
+	return elector, nil
+}
@@ -0,0 +1,88 @@
+package kubernetes
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestWithLease(t *testing.T) {
+	kube := NewClientFromInterface(fake.NewSimpleClientset(), nil)
+	opts := LeaseOptions{
+		Name:           "git-helper-sync",
+		Namespace:      "atlantis",
+		AcquireTimeout: 500 * time.Millisecond,
+	}
+	wantErr := errors.New("sync failed")
+
+	t.Run("If the Lease is free, should run fn and return its error", func(t *testing.T) {
+		err := kube.WithLease(context.Background(), opts, func() error {
+			return wantErr
+		})
+		if err != wantErr {
+			t.Errorf("WithLease() error = %v, want %v", err, wantErr)
+		}
+	})
+
+	t.Run("If the Lease is held, should not run fn", func(t *testing.T) {
+		held := make(chan struct{})
+		done := make(chan struct{})
+		go kube.WithLease(context.Background(), LeaseOptions{Name: opts.Name, Namespace: opts.Namespace}, func() error {
+			close(held)
+			<-done
+			return nil
+		})
+		defer close(done)
+		<-held
+
+		ran := false
+		err := kube.WithLease(context.Background(), opts, func() error {
+			ran = true
+			return nil
+		})
+		if err == nil || ran {
+			t.Errorf("WithLease() error = %v, ran = %v, want an error without running", err, ran)
+		}
+	})
+
+	t.Run("If the Lease is lost before fn returns, should wait for fn and fail", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		started := make(chan struct{})
+		release := make(chan struct{})
+		var finished atomic.Bool
+		result := make(chan error, 1)
+		go func() {
+			// A Lease of its own, the one above may still be held
+			result <- kube.WithLease(ctx, LeaseOptions{Name: "git-helper-lost", Namespace: opts.Namespace}, func() error {
+				close(started)
+				<-release
+				finished.Store(true)
+				return nil
+			})
+		}()
+		select {
+		case <-started:
+		case err := <-result:
+			t.Fatalf("WithLease() error = %v, want fn to run", err)
+		}
+
+		// Cancelling releases the Lease while fn is still running
+		cancel()
+		select {
+		case err := <-result:
+			t.Fatalf("WithLease() returned %v before fn finished", err)
+		case <-time.After(100 * time.Millisecond):
+		}
+
+		close(release)
+		err := <-result
+		if err == nil || !finished.Load() {
+			t.Errorf("WithLease() error = %v, fn finished = %v, want an error after fn finished", err, finished.Load())
+		}
+	})
+}
@@ -0,0 +1,41 @@
+package kubernetes
+
+import (
+	"time"
+
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+// Client holds Kubernetes API clients and provides an interface
+// to the operations git-helper performs against the cluster
+type Client struct {
+	Config    *rest.Config
+	Clientset kubernetes.Interface
+	Dynamic   dynamic.Interface
+}
+
+// ClientOptions holds values used to build a Client
+type ClientOptions struct {
+	// InCluster uses the Pod service account instead of a kubeconfig file
+	InCluster bool
+	// Kubeconfig overrides the kubeconfig path, which otherwise defaults to
+	// $KUBECONFIG or ~/.kube/config
+	Kubeconfig string
+	// Context overrides the current kubeconfig context
+	Context string
+}
+
+// LeaseOptions holds values used to lock or elect a leader with a Lease
+type LeaseOptions struct {
+	Name      string
+	Namespace string
+	// Identity of the holder, defaulting to the hostname and a random suffix
+	Identity string
+	// LeaseDuration is how long other candidates wait before taking over a
+	// Lease that is no longer renewed
+	LeaseDuration time.Duration
+	// AcquireTimeout is how long WithLease waits for the Lease, forever if zero
+	AcquireTimeout time.Duration
+}
@@ -0,0 +1,218 @@
+package kubernetes
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	networkingv1 "k8s.io/api/networking/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+const gatewayAPIGroup string = "gateway.networking.k8s.io"
+
+// gatewayAPIVersions are the Gateway API versions tried in order, v1beta1
+// covering clusters with Gateway API releases before v1.0
+var gatewayAPIVersions []string = []string{"v1", "v1beta1"}
+
+// ReadIngressURL returns the public url of an Ingress
+// The host is taken from the first rule, falling back to the load balancer
+// address, and the scheme is https when the host is covered by a tls entry
+func (k *Client) ReadIngressURL(namespace string, ingressName string) (string, error) {
+	ingress, err := k.Clientset.NetworkingV1().Ingresses(namespace).Get(context.Background(), ingressName, metav1.GetOptions{})
+	if err != nil {
+		return "", fmt.Errorf("error getting Ingress: %s", err)
+	}
+
+	var host string
+	for _, rule := range ingress.Spec.Rules {
+		if rule.Host != "" {
+			host = rule.Host
+			break
+		}
+	}
+	if host == "" {
+		for _, lb := range ingress.Status.LoadBalancer.Ingress {
+			if lb.Hostname != "" {
+				host = lb.Hostname
+			} else {
+				host = lb.IP
+			}
+			if host != "" {
+				break
+			}
+		}
+	}
+	if host == "" {
+		return "", fmt.Errorf("Ingress %s in Namespace %s has no host or load balancer address", ingressName, namespace)
+	}
+
+	scheme := "http"
+	if ingressHasTLS(ingress, host) {
+		scheme = "https"
+	}
+
+	return fmt.Sprintf("%s://%s", scheme, host), nil
+}
+
+// ReadServiceLoadBalancerURL returns the public url of a LoadBalancer Service
+// built from its first load balancer address and first port
+func (k *Client) ReadServiceLoadBalancerURL(namespace string, serviceName string) (string, error) {
+	service, err := k.Clientset.CoreV1().Services(namespace).Get(context.Background(), serviceName, metav1.GetOptions{})
+	if err != nil {
+		return "", fmt.Errorf("error getting Service: %s", err)
+	}
+
+	var host string
+	for _, lb := range service.Status.LoadBalancer.Ingress {
+		if lb.Hostname != "" {
+			host = lb.Hostname
+		} else {
+			host = lb.IP
+		}
+		if host != "" {
+			break
+		}
+	}
+	if host == "" {
+		return "", fmt.Errorf("Service %s in Namespace %s has no load balancer address", serviceName, namespace)
+	}
+	if len(service.Spec.Ports) == 0 {
+		return "", fmt.Errorf("Service %s in Namespace %s exposes no ports", serviceName, namespace)
+	}
+
+	port := service.Spec.Ports[0]
+	scheme := "http"
+	if port.Port == 443 || port.Name == "https" {
+		scheme = "https"
+	}
+	if (scheme == "http" && port.Port == 80) || (scheme == "https" && port.Port == 443) {
+		return fmt.Sprintf("%s://%s", scheme, host), nil
+	}
+
+	return fmt.Sprintf("%s://%s", scheme, net.JoinHostPort(host, strconv.Itoa(int(port.Port)))), nil
+}
+
+// ReadHTTPRouteURL returns the public url of a Gateway API HTTPRoute
+// The host is taken from the first hostname and the scheme is https when a
+// parent Gateway serves the route through an HTTPS listener
+func (k *Client) ReadHTTPRouteURL(namespace string, routeName string) (string, error) {
+	route, err := k.getGatewayAPIObject("httproutes", namespace, routeName)
+	if err != nil {
+		return "", fmt.Errorf("error getting HTTPRoute: %s", err)
+	}
+
+	hostnames, _, _ := unstructured.NestedStringSlice(route.Object, "spec", "hostnames")
+	if len(hostnames) == 0 {
+		return "", fmt.Errorf("HTTPRoute %s in Namespace %s has no hostnames", routeName, namespace)
+	}
+	host := hostnames[0]
+
+	parentRefs, _, _ := unstructured.NestedSlice(route.Object, "spec", "parentRefs")
+	scheme := "http"
+	for _, ref := range parentRefs {
+		parent, ok := ref.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		https, err := k.gatewayServesHTTPS(namespace, parent)
+		if err != nil {
+			return "", err
+		}
+		if https {
+			scheme = "https"
+			break
+		}
+	}
+
+	return fmt.Sprintf("%s://%s", scheme, host), nil
+}
+
+// ingressHasTLS reports whether host is covered by any of the Ingress tls
+// entries, either listed or matched by a wildcard host
+// An entry without hosts covers every host of the Ingress
+func ingressHasTLS(ingress *networkingv1.Ingress, host string) bool {
+	for _, tls := range ingress.Spec.TLS {
+		if len(tls.Hosts) == 0 {
+			return true
+		}
+		for _, tlsHost := range tls.Hosts {
+			if tlsHostMatches(tlsHost, host) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// tlsHostMatches reports whether a tls host matches host, where a wildcard
+// such as *.example.com matches exactly one label
+func tlsHostMatches(tlsHost string, host string) bool {
+	if strings.EqualFold(tlsHost, host) {
+		return true
+	}
+	suffix, ok := strings.CutPrefix(tlsHost, "*")
+	if !ok || !strings.HasPrefix(suffix, ".") {
+		return false
+	}
+	label, ok := strings.CutSuffix(strings.ToLower(host), strings.ToLower(suffix))
+	return ok && label != "" && !strings.Contains(label, ".")
+}
+
+// gatewayServesHTTPS reports whether the Gateway referenced by an HTTPRoute
+// parentRef has an HTTPS listener the route can attach to
+func (k *Client) gatewayServesHTTPS(routeNamespace string, parentRef map[string]interface{}) (bool, error) {
+	if kind, ok := parentRef["kind"].(string); ok && kind != "Gateway" {
+		return false, nil
+	}
+	name, _ := parentRef["name"].(string)
+	namespace, _ := parentRef["namespace"].(string)
+	if namespace == "" {
+		namespace = routeNamespace
+	}
+	sectionName, _ := parentRef["sectionName"].(string)
+
+	gateway, err := k.getGatewayAPIObject("gateways", namespace, name)
+	if err != nil {
+		return false, fmt.Errorf("error getting Gateway: %s", err)
+	}
+
+	listeners, _, _ := unstructured.NestedSlice(gateway.Object, "spec", "listeners")
+	for _, l := range listeners {
+		listener, ok := l.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if sectionName != "" && listener["name"] != sectionName {
+			continue
+		}
+		if listener["protocol"] == "HTTPS" {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// getGatewayAPIObject returns a Gateway API object, falling back to older api
+// versions when the cluster does not serve it under v1
+func (k *Client) getGatewayAPIObject(resource string, namespace string, name string) (*unstructured.Unstructured, error) {
+	var err error
+	for _, version := range gatewayAPIVersions {
+		gvr := schema.GroupVersionResource{Group: gatewayAPIGroup, Version: version, Resource: resource}
+		var object *unstructured.Unstructured
+		object, err = k.Dynamic.Resource(gvr).Namespace(namespace).Get(context.Background(), name, metav1.GetOptions{})
+		if err == nil {
+			return object, nil
+		}
+		if !apierrors.IsNotFound(err) {
+			return nil, err
+		}
+	}
+	return nil, err
+}
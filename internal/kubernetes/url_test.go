@@ -0,0 +1,240 @@
+package kubernetes
+
+import (
+	"context"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestReadIngressURL(t *testing.T) {
+	tests := []struct {
+		name    string
+		ingress networkingv1.Ingress
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "If the rule host is covered by tls, should use https",
+			ingress: networkingv1.Ingress{
+				Spec: networkingv1.IngressSpec{
+					Rules: []networkingv1.IngressRule{{Host: "atlantis.example.com"}},
+					TLS:   []networkingv1.IngressTLS{{Hosts: []string{"atlantis.example.com"}}},
+				},
+			},
+			want: "https://atlantis.example.com",
+		},
+		{
+			name: "If the rule host is covered by a wildcard tls host, should use https",
+			ingress: networkingv1.Ingress{
+				Spec: networkingv1.IngressSpec{
+					Rules: []networkingv1.IngressRule{{Host: "atlantis.example.com"}},
+					TLS:   []networkingv1.IngressTLS{{Hosts: []string{"*.example.com"}}},
+				},
+			},
+			want: "https://atlantis.example.com",
+		},
+		{
+			name: "If the wildcard tls host only covers a parent domain, should use http",
+			ingress: networkingv1.Ingress{
+				Spec: networkingv1.IngressSpec{
+					Rules: []networkingv1.IngressRule{{Host: "atlantis.dev.example.com"}},
+					TLS:   []networkingv1.IngressTLS{{Hosts: []string{"*.example.com"}}},
+				},
+			},
+			want: "http://atlantis.dev.example.com",
+		},
+		{
+			name: "If a tls entry has no hosts, should use https",
+			ingress: networkingv1.Ingress{
+				Spec: networkingv1.IngressSpec{
+					Rules: []networkingv1.IngressRule{{Host: "atlantis.example.com"}},
+					TLS:   []networkingv1.IngressTLS{{SecretName: "atlantis-tls"}},
+				},
+			},
+			want: "https://atlantis.example.com",
+		},
+		{
+			name: "If the rule host is not covered by tls, should use http",
+			ingress: networkingv1.Ingress{
+				Spec: networkingv1.IngressSpec{
+					Rules: []networkingv1.IngressRule{{Host: "atlantis.example.com"}},
+					TLS:   []networkingv1.IngressTLS{{Hosts: []string{"other.example.com"}}},
+				},
+			},
+			want: "http://atlantis.example.com",
+		},
+		{
+			name: "If there is no rule host, should use the load balancer address over http",
+			ingress: networkingv1.Ingress{
+				Status: networkingv1.IngressStatus{LoadBalancer: networkingv1.IngressLoadBalancerStatus{
+					Ingress: []networkingv1.IngressLoadBalancerIngress{{IP: "203.0.113.10"}},
+				}},
+			},
+			want: "http://203.0.113.10",
+		},
+		{
+			name:    "If there is no host at all, should fail",
+			ingress: networkingv1.Ingress{},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.ingress.ObjectMeta = metav1.ObjectMeta{Name: "atlantis", Namespace: "atlantis"}
+			kube := NewClientFromInterface(fake.NewSimpleClientset(&tt.ingress), nil)
+			got, err := kube.ReadIngressURL("atlantis", "atlantis")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ReadIngressURL() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ReadIngressURL() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadServiceLoadBalancerURL(t *testing.T) {
+	tests := []struct {
+		name    string
+		service v1.Service
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "If the port is 443, should use https without a port",
+			service: v1.Service{
+				Spec:   v1.ServiceSpec{Ports: []v1.ServicePort{{Port: 443}}},
+				Status: v1.ServiceStatus{LoadBalancer: v1.LoadBalancerStatus{Ingress: []v1.LoadBalancerIngress{{Hostname: "lb.example.com"}}}},
+			},
+			want: "https://lb.example.com",
+		},
+		{
+			name: "If the port is not a default port, should include it",
+			service: v1.Service{
+				Spec:   v1.ServiceSpec{Ports: []v1.ServicePort{{Name: "http", Port: 4141}}},
+				Status: v1.ServiceStatus{LoadBalancer: v1.LoadBalancerStatus{Ingress: []v1.LoadBalancerIngress{{IP: "203.0.113.10"}}}},
+			},
+			want: "http://203.0.113.10:4141",
+		},
+		{
+			name: "If no address is assigned yet, should fail",
+			service: v1.Service{
+				Spec: v1.ServiceSpec{Ports: []v1.ServicePort{{Port: 80}}},
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.service.ObjectMeta = metav1.ObjectMeta{Name: "atlantis", Namespace: "atlantis"}
+			kube := NewClientFromInterface(fake.NewSimpleClientset(&tt.service), nil)
+			got, err := kube.ReadServiceLoadBalancerURL("atlantis", "atlantis")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ReadServiceLoadBalancerURL() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ReadServiceLoadBalancerURL() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+// gatewayAPIObject returns an unstructured Gateway API object of the given
+// version in the atlantis Namespace
+func gatewayAPIObject(version string, kind string, name string, spec map[string]interface{}) *unstructured.Unstructured {
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": gatewayAPIGroup + "/" + version,
+		"kind":       kind,
+		"metadata":   map[string]interface{}{"name": name, "namespace": "atlantis"},
+		"spec":       spec,
+	}}
+}
+
+// newGatewayAPIClient returns a fake dynamic client holding the Gateway API
+// objects, created under their real resource names since the fake client
+// would guess gatewaies for Gateway
+func newGatewayAPIClient(t *testing.T, objects ...*unstructured.Unstructured) dynamic.Interface {
+	resources := map[string]string{"HTTPRoute": "httproutes", "Gateway": "gateways"}
+	client := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme())
+	for _, object := range objects {
+		gvk := object.GroupVersionKind()
+		gvr := schema.GroupVersionResource{Group: gvk.Group, Version: gvk.Version, Resource: resources[gvk.Kind]}
+		_, err := client.Resource(gvr).Namespace(object.GetNamespace()).Create(context.Background(), object, metav1.CreateOptions{})
+		if err != nil {
+			t.Fatalf("Create() error = %v", err)
+		}
+	}
+	return client
+}
+
+func TestReadHTTPRouteURL(t *testing.T) {
+	route := map[string]interface{}{
+		"hostnames":  []interface{}{"atlantis.example.com"},
+		"parentRefs": []interface{}{map[string]interface{}{"name": "public", "sectionName": "https"}},
+	}
+	listeners := func(protocol string) map[string]interface{} {
+		return map[string]interface{}{"listeners": []interface{}{
+			map[string]interface{}{"name": "https", "protocol": protocol},
+		}}
+	}
+
+	tests := []struct {
+		name    string
+		objects []*unstructured.Unstructured
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "If the parent Gateway listener is HTTPS, should use https",
+			objects: []*unstructured.Unstructured{
+				gatewayAPIObject("v1", "HTTPRoute", "atlantis", route),
+				gatewayAPIObject("v1", "Gateway", "public", listeners("HTTPS")),
+			},
+			want: "https://atlantis.example.com",
+		},
+		{
+			name: "If the parent Gateway listener is HTTP, should use http",
+			objects: []*unstructured.Unstructured{
+				gatewayAPIObject("v1", "HTTPRoute", "atlantis", route),
+				gatewayAPIObject("v1", "Gateway", "public", listeners("HTTP")),
+			},
+			want: "http://atlantis.example.com",
+		},
+		{
+			name: "If the cluster only serves v1beta1, should fall back to it",
+			objects: []*unstructured.Unstructured{
+				gatewayAPIObject("v1beta1", "HTTPRoute", "atlantis", route),
+				gatewayAPIObject("v1beta1", "Gateway", "public", listeners("HTTPS")),
+			},
+			want: "https://atlantis.example.com",
+		},
+		{
+			name:    "If the HTTPRoute does not exist, should fail",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			kube := NewClientFromInterface(fake.NewSimpleClientset(), newGatewayAPIClient(t, tt.objects...))
+			got, err := kube.ReadHTTPRouteURL("atlantis", "atlantis")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ReadHTTPRouteURL() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ReadHTTPRouteURL() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,169 @@
+package receiver
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	stdsync "sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// maxPayloadSize bounds the size of accepted deliveries, GitHub caps
+// payloads at 25MB
+const maxPayloadSize int64 = 25 << 20
+
+// Handler returns an http.Handler that validates and prints GitHub and
+// GitLab webhook deliveries
+func Handler(opts Options) http.Handler {
+	if opts.Out == nil {
+		opts.Out = os.Stdout
+	}
+	var mu stdsync.Mutex
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		event, err := ReadEvent(r, opts)
+		if err != nil {
+			log.Warnf("rejected delivery from %s: %s", r.RemoteAddr, err)
+			http.Error(w, err.Error(), StatusCode(err))
+			return
+		}
+
+		mu.Lock()
+		printEvent(opts.Out, event, opts.PrintPayload)
+		mu.Unlock()
+
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"ok":true}`)
+	})
+}
+
+// requestError is a delivery rejected with an http status code
+type requestError struct {
+	status int
+	err    error
+}
+
+func (e *requestError) Error() string {
+	return e.err.Error()
+}
+
+// StatusCode returns the http status code to reject a delivery with
+func StatusCode(err error) int {
+	if reqErr, ok := err.(*requestError); ok {
+		return reqErr.status
+	}
+	return http.StatusBadRequest
+}
+
+// ReadEvent reads a delivery, verifies it against the secret of its provider
+// and summarizes it
+func ReadEvent(r *http.Request, opts Options) (Event, error) {
+	body, err := io.ReadAll(http.MaxBytesReader(nil, r.Body, maxPayloadSize))
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		return Event{}, &requestError{http.StatusRequestEntityTooLarge, fmt.Errorf("body exceeds %d bytes", tooLarge.Limit)}
+	}
+	if err != nil {
+		return Event{}, &requestError{http.StatusBadRequest, fmt.Errorf("error reading body: %s", err)}
+	}
+
+	var event Event
+	switch {
+	case r.Header.Get(HeaderGitHubEvent) != "":
+		event = Event{
+			Provider:   ProviderGitHub,
+			Event:      r.Header.Get(HeaderGitHubEvent),
+			DeliveryID: r.Header.Get(HeaderGitHubDelivery),
+		}
+		if opts.GitHubSecret != "" {
+			err = VerifyGitHub(opts.GitHubSecret, body, r.Header.Get(HeaderGitHubSignature))
+			if err != nil {
+				return Event{}, &requestError{http.StatusUnauthorized, err}
+			}
+			event.Verified = true
+		}
+		// The form content type wraps the json payload in a payload field
+		if strings.HasPrefix(r.Header.Get("Content-Type"), "application/x-www-form-urlencoded") {
+			form, err := url.ParseQuery(string(body))
+			if err == nil && form.Get("payload") != "" {
+				body = []byte(form.Get("payload"))
+			}
+		}
+	case r.Header.Get(HeaderGitLabEvent) != "":
+		event = Event{
+			Provider:   ProviderGitLab,
+			Event:      r.Header.Get(HeaderGitLabEvent),
+			DeliveryID: r.Header.Get(HeaderGitLabEventUUID),
+		}
+		if opts.GitLabSecret != "" {
+			err = VerifyGitLab(opts.GitLabSecret, r.Header.Get(HeaderGitLabToken))
+			if err != nil {
+				return Event{}, &requestError{http.StatusUnauthorized, err}
+			}
+			event.Verified = true
+		}
+	default:
+		return Event{}, &requestError{http.StatusBadRequest, fmt.Errorf("missing %s or %s header", HeaderGitHubEvent, HeaderGitLabEvent)}
+	}
+
+	event.Payload = body
+	err = summarize(&event)
+	if err != nil {
+		return Event{}, &requestError{http.StatusBadRequest, err}
+	}
+
+	return event, nil
+}
+
+// printEvent writes a one line summary of an event, followed by its payload
+// if requested
+func printEvent(out io.Writer, event Event, printPayload bool) {
+	verified := "unverified"
+	if event.Verified {
+		verified = "verified"
+	}
+
+	line := fmt.Sprintf("%s %s %s", time.Now().Format(time.RFC3339), event.Provider, event.Event)
+	if event.Action != "" {
+		line += fmt.Sprintf(" (%s)", event.Action)
+	}
+	if event.Repository != "" {
+		line += " " + event.Repository
+	}
+	if event.Number != 0 {
+		line += fmt.Sprintf(" #%d", event.Number)
+	}
+	if event.Ref != "" {
+		line += " " + event.Ref
+	}
+	if event.Sender != "" {
+		line += " by " + event.Sender
+	}
+	line += fmt.Sprintf(" [%s", verified)
+	if event.DeliveryID != "" {
+		line += " " + event.DeliveryID
+	}
+	fmt.Fprintln(out, line+"]")
+
+	if printPayload {
+		var indented bytes.Buffer
+		if json.Indent(&indented, event.Payload, "", "  ") == nil {
+			fmt.Fprintln(out, indented.String())
+		} else {
+			fmt.Fprintln(out, string(event.Payload))
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package receiver
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestHandler(t *testing.T) {
+	githubPayload := `{"action":"opened","number":12,"pull_request":{"number":12},"repository":{"full_name":"kubefirst/gitops"},"sender":{"login":"octocat"}}`
+	gitlabPayload := `{"object_kind":"merge_request","project":{"path_with_namespace":"kubefirst/infra/gitops"},"user":{"username":"jdoe"},"object_attributes":{"action":"open","iid":7}}`
+	formPayload := url.Values{"payload": {githubPayload}}.Encode()
+
+	tests := []struct {
+		name        string
+		headers     map[string]string
+		body        string
+		wantStatus  int
+		wantSummary string
+	}{
+		{
+			name: "If a GitHub delivery is signed, should accept it",
+			headers: map[string]string{
+				HeaderGitHubEvent:     "pull_request",
+				HeaderGitHubDelivery:  "72d3162e",
+				HeaderGitHubSignature: SignGitHub("secret", []byte(githubPayload)),
+			},
+			body:        githubPayload,
+			wantStatus:  http.StatusOK,
+			wantSummary: "github pull_request (opened) kubefirst/gitops #12 by octocat [verified 72d3162e]",
+		},
+		{
+			name: "If a form encoded GitHub delivery is signed, should accept it",
+			headers: map[string]string{
+				HeaderGitHubEvent:     "pull_request",
+				HeaderGitHubSignature: SignGitHub("secret", []byte(formPayload)),
+				"Content-Type":        "application/x-www-form-urlencoded",
+			},
+			body:        formPayload,
+			wantStatus:  http.StatusOK,
+			wantSummary: "kubefirst/gitops #12 by octocat [verified]",
+		},
+		{
+			name: "If a GitHub delivery is signed with another secret, should reject it",
+			headers: map[string]string{
+				HeaderGitHubEvent:     "pull_request",
+				HeaderGitHubSignature: SignGitHub("other", []byte(githubPayload)),
+			},
+			body:       githubPayload,
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:       "If a GitHub delivery is unsigned, should reject it",
+			headers:    map[string]string{HeaderGitHubEvent: "push"},
+			body:       githubPayload,
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name: "If a GitLab delivery has the token, should accept it",
+			headers: map[string]string{
+				HeaderGitLabEvent: "Merge Request Hook",
+				HeaderGitLabToken: "token",
+			},
+			body:        gitlabPayload,
+			wantStatus:  http.StatusOK,
+			wantSummary: "gitlab Merge Request Hook (open) kubefirst/infra/gitops #7 by jdoe [verified]",
+		},
+		{
+			name: "If a GitLab delivery has another token, should reject it",
+			headers: map[string]string{
+				HeaderGitLabEvent: "Merge Request Hook",
+				HeaderGitLabToken: "other",
+			},
+			body:       gitlabPayload,
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:       "If the provider is unknown, should reject the delivery",
+			body:       githubPayload,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "If the delivery exceeds the payload limit, should reject it as too large",
+			headers:    map[string]string{HeaderGitHubEvent: "push"},
+			body:       strings.Repeat("a", int(maxPayloadSize)+1),
+			wantStatus: http.StatusRequestEntityTooLarge,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			handler := Handler(Options{GitHubSecret: "secret", GitLabSecret: "token", Out: &out})
+
+			req := httptest.NewRequest(http.MethodPost, "/events", strings.NewReader(tt.body))
+			for key, value := range tt.headers {
+				req.Header.Set(key, value)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body.String())
+			}
+			if !strings.Contains(out.String(), tt.wantSummary) {
+				t.Errorf("output = %q, want %q", out.String(), tt.wantSummary)
+			}
+		})
+	}
+}
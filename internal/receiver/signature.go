@@ -0,0 +1,54 @@
+package receiver
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+const (
+	ProviderGitHub string = "github"
+	ProviderGitLab string = "gitlab"
+
+	HeaderGitHubEvent     string = "X-GitHub-Event"
+	HeaderGitHubDelivery  string = "X-GitHub-Delivery"
+	HeaderGitHubSignature string = "X-Hub-Signature-256"
+	HeaderGitLabEvent     string = "X-Gitlab-Event"
+	HeaderGitLabEventUUID string = "X-Gitlab-Event-UUID"
+	HeaderGitLabToken     string = "X-Gitlab-Token"
+)
+
+// SignGitHub returns the X-Hub-Signature-256 value GitHub sends for body
+func SignGitHub(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifyGitHub checks an X-Hub-Signature-256 value against body
+func VerifyGitHub(secret string, body []byte, signature string) error {
+	if signature == "" {
+		return fmt.Errorf("missing %s header", HeaderGitHubSignature)
+	}
+	if !strings.HasPrefix(signature, "sha256=") {
+		return fmt.Errorf("unsupported signature %s, expected sha256=", signature)
+	}
+	if !hmac.Equal([]byte(signature), []byte(SignGitHub(secret, body))) {
+		return fmt.Errorf("signature does not match the secret")
+	}
+	return nil
+}
+
+// VerifyGitLab checks an X-Gitlab-Token value against the secret
+func VerifyGitLab(secret string, token string) error {
+	if token == "" {
+		return fmt.Errorf("missing %s header", HeaderGitLabToken)
+	}
+	if subtle.ConstantTimeCompare([]byte(secret), []byte(token)) != 1 {
+		return fmt.Errorf("token does not match the secret")
+	}
+	return nil
+}
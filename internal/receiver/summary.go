@@ -0,0 +1,86 @@
+package receiver
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// payload holds the payload fields used to summarize GitHub and GitLab events
+type payload struct {
+	// GitHub
+	Action     string `json:"action"`
+	Ref        string `json:"ref"`
+	Number     int    `json:"number"`
+	Repository struct {
+		FullName string `json:"full_name"`
+	} `json:"repository"`
+	Sender struct {
+		Login string `json:"login"`
+	} `json:"sender"`
+	PullRequest struct {
+		Number int `json:"number"`
+	} `json:"pull_request"`
+	Issue struct {
+		Number int `json:"number"`
+	} `json:"issue"`
+
+	// GitLab
+	ObjectKind string `json:"object_kind"`
+	Project    struct {
+		PathWithNamespace string `json:"path_with_namespace"`
+	} `json:"project"`
+	User struct {
+		Username string `json:"username"`
+	} `json:"user"`
+	UserUsername     string `json:"user_username"`
+	ObjectAttributes struct {
+		Action string `json:"action"`
+		IID    int    `json:"iid"`
+	} `json:"object_attributes"`
+	MergeRequest struct {
+		IID int `json:"iid"`
+	} `json:"merge_request"`
+}
+
+// summarize fills the summary fields of an event from its payload
+func summarize(event *Event) error {
+	var p payload
+	err := json.Unmarshal(event.Payload, &p)
+	if err != nil {
+		return fmt.Errorf("error parsing payload: %s", err)
+	}
+
+	switch event.Provider {
+	case ProviderGitHub:
+		event.Repository = p.Repository.FullName
+		event.Action = p.Action
+		event.Ref = p.Ref
+		event.Sender = p.Sender.Login
+		event.Number = firstNonZero(p.PullRequest.Number, p.Issue.Number, p.Number)
+	case ProviderGitLab:
+		event.Repository = p.Project.PathWithNamespace
+		event.Action = p.ObjectAttributes.Action
+		event.Ref = p.Ref
+		event.Sender = p.User.Username
+		if event.Sender == "" {
+			event.Sender = p.UserUsername
+		}
+		if p.ObjectKind == "merge_request" {
+			event.Number = p.ObjectAttributes.IID
+		} else {
+			event.Number = p.MergeRequest.IID
+		}
+	}
+
+	return nil
+}
+
+// firstNonZero returns the first value that is not 0
+func firstNonZero(values ...int) int {
+	for _, value := range values {
+		if value != 0 {
+			return value
+		}
+	}
+	return 0
+}
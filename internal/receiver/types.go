@@ -0,0 +1,33 @@
+package receiver
+
+import "io"
+
+// Options configures the webhook receiver
+type Options struct {
+	// GitHubSecret validates X-Hub-Signature-256, deliveries are accepted
+	// unverified if it is empty
+	GitHubSecret string
+	// GitLabSecret validates X-Gitlab-Token, deliveries are accepted
+	// unverified if it is empty
+	GitLabSecret string
+	// PrintPayload prints the full indented payload of each delivery
+	PrintPayload bool
+	// Out receives the printed deliveries
+	Out io.Writer
+}
+
+// Event summarizes a received delivery
+type Event struct {
+	Provider   string
+	Event      string
+	DeliveryID string
+	Repository string
+	Action     string
+	Ref        string
+	Sender     string
+	// Number is the pull or merge request number, 0 if not applicable
+	Number int
+	// Verified is set if the signature or token matched the secret
+	Verified bool
+	Payload  []byte
+}
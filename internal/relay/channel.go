@@ -0,0 +1,153 @@
+package relay
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// maxEventSize bounds a single channel message, GitHub caps payloads at 25MB
+// and channels add the headers
+const maxEventSize int = 26 << 20
+
+// channelFields are the keys of a channel message that are not headers
+var channelFields map[string]bool = map[string]bool{
+	"body":      true,
+	"bodyB":     true,
+	"query":     true,
+	"timestamp": true,
+}
+
+// Subscribe reads deliveries from a smee-style server-sent events channel and
+// queues them like deliveries received over http, reconnecting until ctx is
+// cancelled
+func (r *Relay) Subscribe(ctx context.Context, channelURL string) error {
+	// Streams stay open, so only the relay context bounds the request
+	client := &http.Client{Transport: r.client.Transport}
+	interval := r.opts.RetryInterval
+	for {
+		connected, err := r.readChannel(ctx, client, channelURL)
+		if ctx.Err() != nil {
+			return nil
+		}
+		if connected {
+			interval = r.opts.RetryInterval
+		}
+		if err != nil {
+			log.Warnf("error reading channel %s, reconnecting in %s: %s", channelURL, interval, err)
+		} else {
+			log.Warnf("channel %s closed, reconnecting in %s", channelURL, interval)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(interval):
+		}
+		interval *= 2
+		if interval > r.opts.MaxRetryInterval {
+			interval = r.opts.MaxRetryInterval
+		}
+	}
+}
+
+// readChannel queues the messages of one channel connection until it ends,
+// reporting whether the connection was established
+func (r *Relay) readChannel(ctx context.Context, client *http.Client, channelURL string) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, channelURL, nil)
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Accept", "text/event-stream")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("channel answered %d", resp.StatusCode)
+	}
+	log.Infof("reading deliveries from channel %s", channelURL)
+
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), maxEventSize)
+	var name string
+	var data strings.Builder
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case line == "":
+			// A blank line dispatches the event
+			if data.Len() > 0 && (name == "" || name == "message") {
+				err := r.acceptMessage(channelURL, []byte(data.String()))
+				if err != nil {
+					log.Warnf("rejected delivery from channel %s: %s", channelURL, err)
+				}
+			}
+			name = ""
+			data.Reset()
+		case strings.HasPrefix(line, "event:"):
+			name = strings.TrimSpace(strings.TrimPrefix(line, "event:"))
+		case strings.HasPrefix(line, "data:"):
+			if data.Len() > 0 {
+				data.WriteByte('\n')
+			}
+			data.WriteString(strings.TrimPrefix(strings.TrimPrefix(line, "data:"), " "))
+		}
+	}
+
+	return true, scanner.Err()
+}
+
+// acceptMessage verifies and queues a channel message, which holds the
+// delivery headers as lowercase keys next to the body
+// The raw body is used when the channel sends it base64 encoded as bodyB,
+// since a re-encoded json body may no longer match the GitHub signature
+func (r *Relay) acceptMessage(channelURL string, data []byte) error {
+	var message map[string]json.RawMessage
+	err := json.Unmarshal(data, &message)
+	if err != nil {
+		return fmt.Errorf("error parsing message: %s", err)
+	}
+
+	var body []byte
+	if raw, ok := message["bodyB"]; ok {
+		var encoded string
+		err = json.Unmarshal(raw, &encoded)
+		if err == nil {
+			body, err = base64.StdEncoding.DecodeString(encoded)
+		}
+		if err != nil {
+			return fmt.Errorf("error decoding bodyB: %s", err)
+		}
+	} else if raw, ok := message["body"]; ok {
+		body = raw
+	} else {
+		return fmt.Errorf("message has no body")
+	}
+
+	req, err := http.NewRequest(http.MethodPost, channelURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	for key, raw := range message {
+		if channelFields[key] {
+			continue
+		}
+		var value string
+		if json.Unmarshal(raw, &value) == nil {
+			req.Header.Set(key, value)
+		}
+	}
+
+	return r.accept(req)
+}
@@ -0,0 +1,309 @@
+package relay
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kubefirst/git-helper/internal/receiver"
+	log "github.com/sirupsen/logrus"
+)
+
+// errQueueFull rejects deliveries while the buffer is full
+var errQueueFull error = errors.New("relay queue is full")
+
+// droppedHeaders are not copied to forwarded deliveries, either because the
+// relay sets them or because they describe the incoming connection
+var droppedHeaders []string = []string{
+	"Content-Length", "Content-Type", "Connection", "Host", "Accept-Encoding", "Transfer-Encoding",
+	"X-Forwarded-For", "X-Forwarded-Host", "X-Forwarded-Proto", "X-Real-Ip",
+	receiver.HeaderGitHubSignature, "X-Hub-Signature", receiver.HeaderGitLabToken,
+}
+
+// Relay verifies webhook deliveries and forwards them to a target, buffering
+// and retrying them while the target is unavailable
+type Relay struct {
+	opts   Options
+	client *http.Client
+
+	// queue holds the deliveries waiting to be forwarded in the order they
+	// were received, the head stays queued until it is forwarded or dropped
+	mu    sync.Mutex
+	queue []delivery
+	// queued wakes Run when a delivery is queued
+	queued chan struct{}
+}
+
+// New returns a relay to the target of opts, with defaults for unset options
+func New(opts Options) (*Relay, error) {
+	target, err := url.Parse(opts.Target)
+	if err != nil || target.Scheme == "" || target.Host == "" {
+		return nil, fmt.Errorf("invalid target url %s", opts.Target)
+	}
+	// Signing deliveries for the target without verifying them first would
+	// let anyone reaching the relay send authenticated deliveries
+	if !opts.InsecureSkipVerify {
+		if opts.TargetGitHubSecret != "" && opts.GitHubSecret == "" {
+			return nil, errors.New("a target GitHub secret requires an incoming GitHub secret unless verification is skipped")
+		}
+		if opts.TargetGitLabSecret != "" && opts.GitLabSecret == "" {
+			return nil, errors.New("a target GitLab secret requires an incoming GitLab secret unless verification is skipped")
+		}
+	}
+	if opts.TargetGitHubSecret == "" {
+		opts.TargetGitHubSecret = opts.GitHubSecret
+	}
+	if opts.TargetGitLabSecret == "" {
+		opts.TargetGitLabSecret = opts.GitLabSecret
+	}
+	if opts.QueueSize <= 0 {
+		opts.QueueSize = 1000
+	}
+	if opts.RetryInterval <= 0 {
+		opts.RetryInterval = time.Second
+	}
+	if opts.MaxRetryInterval < opts.RetryInterval {
+		opts.MaxRetryInterval = opts.RetryInterval
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = 10 * time.Second
+	}
+
+	return &Relay{
+		opts:   opts,
+		client: &http.Client{Timeout: opts.Timeout},
+		queued: make(chan struct{}, 1),
+	}, nil
+}
+
+// Pending returns the number of deliveries waiting to be forwarded,
+// including the one being forwarded
+func (r *Relay) Pending() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.queue)
+}
+
+// enqueue appends a delivery to the queue unless it is full
+func (r *Relay) enqueue(d delivery) error {
+	r.mu.Lock()
+	if len(r.queue) >= r.opts.QueueSize {
+		r.mu.Unlock()
+		return errQueueFull
+	}
+	r.queue = append(r.queue, d)
+	r.mu.Unlock()
+
+	select {
+	case r.queued <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// head returns the oldest queued delivery, if any
+func (r *Relay) head() (delivery, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.queue) == 0 {
+		return delivery{}, false
+	}
+	return r.queue[0], true
+}
+
+// pop removes the oldest queued delivery
+func (r *Relay) pop() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.queue[0] = delivery{}
+	r.queue = r.queue[1:]
+}
+
+// Handler returns an http.Handler that verifies deliveries and queues them
+// for forwarding, answering 202 once queued
+func (r *Relay) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		err := r.accept(req)
+		if errors.Is(err, errQueueFull) {
+			log.Warnf("rejected delivery from %s: %s", req.RemoteAddr, err)
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		if err != nil {
+			log.Warnf("rejected delivery from %s: %s", req.RemoteAddr, err)
+			http.Error(w, err.Error(), receiver.StatusCode(err))
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusAccepted)
+		fmt.Fprint(w, `{"queued":true}`)
+	})
+}
+
+// accept verifies a delivery and queues it
+func (r *Relay) accept(req *http.Request) error {
+	event, err := receiver.ReadEvent(req, receiver.Options{
+		GitHubSecret: r.opts.GitHubSecret,
+		GitLabSecret: r.opts.GitLabSecret,
+	})
+	if err != nil {
+		return err
+	}
+
+	header := req.Header.Clone()
+	for _, name := range droppedHeaders {
+		header.Del(name)
+	}
+
+	err = r.enqueue(delivery{event: event, header: header, receivedAt: time.Now()})
+	if err != nil {
+		return err
+	}
+	log.Infof("queued %s %s delivery %s for %s", event.Provider, event.Event, event.DeliveryID, event.Repository)
+	return nil
+}
+
+// Run forwards queued deliveries in the order they were received until ctx
+// is cancelled
+// A delivery interrupted by the cancellation stays at the head of the queue
+func (r *Relay) Run(ctx context.Context) error {
+	for {
+		d, ok := r.head()
+		if !ok {
+			select {
+			case <-ctx.Done():
+				r.logPending()
+				return nil
+			case <-r.queued:
+			}
+			continue
+		}
+
+		if !r.deliver(ctx, d) {
+			r.logPending()
+			return nil
+		}
+		r.pop()
+	}
+}
+
+// logPending logs every delivery still queued, which is lost when the relay
+// exits since the queue is only kept in memory
+func (r *Relay) logPending() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, d := range r.queue {
+		log.Warnf("relay stopped before forwarding %s %s delivery %s", d.event.Provider, d.event.Event, d.event.DeliveryID)
+	}
+}
+
+// deliver forwards a delivery, retrying with backoff while the target is
+// unavailable, and reports whether it is done with it, either forwarded or
+// dropped, rather than interrupted by ctx
+// Later deliveries wait so the target sees them in order
+func (r *Relay) deliver(ctx context.Context, d delivery) bool {
+	event := d.event
+	interval := r.opts.RetryInterval
+	for attempt := 1; ; attempt++ {
+		err := r.forward(ctx, d)
+		if err == nil {
+			log.Infof("forwarded %s %s delivery %s to %s", event.Provider, event.Event, event.DeliveryID, r.opts.Target)
+			return true
+		}
+		if ctx.Err() != nil {
+			return false
+		}
+		if !retryable(err) {
+			log.Errorf("dropping %s %s delivery %s: %s", event.Provider, event.Event, event.DeliveryID, err)
+			return true
+		}
+		if r.opts.MaxAge > 0 && time.Since(d.receivedAt) >= r.opts.MaxAge {
+			log.Errorf("dropping %s %s delivery %s after %d attempts: %s", event.Provider, event.Event, event.DeliveryID, attempt, err)
+			return true
+		}
+
+		log.Warnf("error forwarding %s %s delivery %s, retrying in %s: %s", event.Provider, event.Event, event.DeliveryID, interval, err)
+		select {
+		case <-ctx.Done():
+			return false
+		case <-time.After(interval):
+		}
+		interval *= 2
+		if interval > r.opts.MaxRetryInterval {
+			interval = r.opts.MaxRetryInterval
+		}
+	}
+}
+
+// targetError is a forward the target answered with a non-2xx status
+type targetError struct {
+	status int
+	body   string
+}
+
+func (e *targetError) Error() string {
+	if e.body == "" {
+		return fmt.Sprintf("target answered %d", e.status)
+	}
+	return fmt.Sprintf("target answered %d: %s", e.status, e.body)
+}
+
+// retryable reports whether a forward may succeed when tried again, which is
+// the case for connection errors and server side failures
+func retryable(err error) bool {
+	var targetErr *targetError
+	if !errors.As(err, &targetErr) {
+		return true
+	}
+	return targetErr.status >= 500 || targetErr.status == http.StatusTooManyRequests || targetErr.status == http.StatusRequestTimeout
+}
+
+// forward sends a delivery to the target, signed with the target secret of
+// its provider
+func (r *Relay) forward(ctx context.Context, d delivery) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, r.opts.Target, bytes.NewReader(d.event.Payload))
+	if err != nil {
+		return err
+	}
+	for name, values := range d.header {
+		req.Header[name] = values
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	switch d.event.Provider {
+	case receiver.ProviderGitHub:
+		if r.opts.TargetGitHubSecret != "" {
+			req.Header.Set(receiver.HeaderGitHubSignature, receiver.SignGitHub(r.opts.TargetGitHubSecret, d.event.Payload))
+		}
+	case receiver.ProviderGitLab:
+		if r.opts.TargetGitLabSecret != "" {
+			req.Header.Set(receiver.HeaderGitLabToken, r.opts.TargetGitLabSecret)
+		}
+	}
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return &targetError{status: resp.StatusCode, body: strings.TrimSpace(string(body))}
+	}
+
+	return nil
+}
@@ -0,0 +1,330 @@
+package relay
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/kubefirst/git-helper/internal/receiver"
+	"github.com/kubefirst/git-helper/internal/sender"
+)
+
+// newTarget returns a target that verifies deliveries against secret,
+// failing the first failures of them with a 502, and reports the status of
+// every delivery on the returned channel
+func newTarget(t *testing.T, secret string, failures int) (*httptest.Server, chan int) {
+	statuses := make(chan int, 10)
+	verify := receiver.Handler(receiver.Options{GitHubSecret: secret, GitLabSecret: secret, Out: &discard{}})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if failures > 0 {
+			failures--
+			w.WriteHeader(http.StatusBadGateway)
+			statuses <- http.StatusBadGateway
+			return
+		}
+		recorder := httptest.NewRecorder()
+		verify.ServeHTTP(recorder, r)
+		w.WriteHeader(recorder.Code)
+		statuses <- recorder.Code
+	}))
+	t.Cleanup(server.Close)
+	return server, statuses
+}
+
+// discard is an io.Writer dropping the printed deliveries
+type discard struct{}
+
+func (discard) Write(p []byte) (int, error) {
+	return len(p), nil
+}
+
+// waitStatuses returns the next count statuses reported by a target,
+// failing the test if they do not arrive in time
+func waitStatuses(t *testing.T, statuses chan int, count int) []int {
+	t.Helper()
+	got := make([]int, 0, count)
+	deadline := time.After(5 * time.Second)
+	for len(got) < count {
+		select {
+		case status := <-statuses:
+			got = append(got, status)
+		case <-deadline:
+			t.Fatalf("target answered %v, want %d deliveries", got, count)
+		}
+	}
+	return got
+}
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    Options
+		wantErr bool
+	}{
+		{
+			name: "If both secrets are set, should create the relay",
+			opts: Options{Target: "http://atlantis.atlantis.svc/events", GitHubSecret: "inbound", TargetGitHubSecret: "target"},
+		},
+		{
+			name: "If no secret is set, should create the relay",
+			opts: Options{Target: "http://atlantis.atlantis.svc/events"},
+		},
+		{
+			name:    "If a GitHub target secret has no incoming secret, should fail",
+			opts:    Options{Target: "http://atlantis.atlantis.svc/events", TargetGitHubSecret: "target"},
+			wantErr: true,
+		},
+		{
+			name:    "If a GitLab target secret has no incoming secret, should fail",
+			opts:    Options{Target: "http://atlantis.atlantis.svc/events", GitHubSecret: "inbound", TargetGitLabSecret: "target"},
+			wantErr: true,
+		},
+		{
+			name: "If verification is skipped, should allow a target secret without an incoming secret",
+			opts: Options{Target: "http://atlantis.atlantis.svc/events", TargetGitHubSecret: "target", InsecureSkipVerify: true},
+		},
+		{
+			name:    "If the target is not a url, should fail",
+			opts:    Options{Target: "atlantis"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := New(tt.opts)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("New() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRelay(t *testing.T) {
+	tests := []struct {
+		name string
+		// sendSecret signs the delivery sent to the relay
+		sendSecret string
+		// targetSecret is what the relay signs forwarded deliveries with
+		targetSecret string
+		provider     string
+		failures     int
+		wantStatus   int
+		wantStatuses []int
+	}{
+		{
+			name:         "If a GitHub delivery verifies, should forward it signed with the target secret",
+			sendSecret:   "inbound",
+			targetSecret: "target",
+			provider:     "github",
+			wantStatus:   http.StatusAccepted,
+			wantStatuses: []int{http.StatusOK},
+		},
+		{
+			name:         "If a GitLab delivery verifies, should forward it with the target token",
+			sendSecret:   "inbound",
+			targetSecret: "target",
+			provider:     "gitlab",
+			wantStatus:   http.StatusAccepted,
+			wantStatuses: []int{http.StatusOK},
+		},
+		{
+			name:         "If the target is down, should retry until it answers",
+			sendSecret:   "inbound",
+			targetSecret: "target",
+			provider:     "github",
+			failures:     2,
+			wantStatus:   http.StatusAccepted,
+			wantStatuses: []int{http.StatusBadGateway, http.StatusBadGateway, http.StatusOK},
+		},
+		{
+			name:         "If the target rejects a delivery, should drop it",
+			sendSecret:   "inbound",
+			targetSecret: "wrong",
+			provider:     "github",
+			wantStatus:   http.StatusAccepted,
+			wantStatuses: []int{http.StatusUnauthorized},
+		},
+		{
+			name:         "If a delivery fails verification, should reject it",
+			sendSecret:   "other",
+			targetSecret: "target",
+			provider:     "github",
+			wantStatus:   http.StatusUnauthorized,
+			wantStatuses: []int{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			target, statuses := newTarget(t, "target", tt.failures)
+			relay, err := New(Options{
+				Target:             target.URL + "/events",
+				GitHubSecret:       "inbound",
+				GitLabSecret:       "inbound",
+				TargetGitHubSecret: tt.targetSecret,
+				TargetGitLabSecret: tt.targetSecret,
+				RetryInterval:      10 * time.Millisecond,
+			})
+			if err != nil {
+				t.Fatalf("New() error = %v", err)
+			}
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			go relay.Run(ctx)
+
+			server := httptest.NewServer(relay.Handler())
+			defer server.Close()
+
+			result, err := sender.Send(server.URL+"/events", sender.Options{Provider: tt.provider, Event: sender.EventPush, Repository: "kubefirst/gitops", Secret: tt.sendSecret})
+			if err != nil {
+				t.Fatalf("Send() error = %v", err)
+			}
+			if result.StatusCode != tt.wantStatus {
+				t.Errorf("relay answered %d, want %d", result.StatusCode, tt.wantStatus)
+			}
+			if got := waitStatuses(t, statuses, len(tt.wantStatuses)); !reflect.DeepEqual(got, tt.wantStatuses) {
+				t.Errorf("target answered %v, want %v", got, tt.wantStatuses)
+			}
+		})
+	}
+}
+
+func TestRelayQueueFull(t *testing.T) {
+	relay, err := New(Options{Target: "http://atlantis.atlantis.svc/events", QueueSize: 1})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	server := httptest.NewServer(relay.Handler())
+	defer server.Close()
+
+	// Nothing forwards, so the second delivery finds the queue full
+	want := []int{http.StatusAccepted, http.StatusServiceUnavailable}
+	for i, status := range want {
+		result, err := sender.Send(server.URL, sender.Options{Provider: "github", Event: sender.EventPush, Repository: "kubefirst/gitops"})
+		if err != nil {
+			t.Fatalf("Send() error = %v", err)
+		}
+		if result.StatusCode != status {
+			t.Errorf("delivery %d answered %d, want %d", i, result.StatusCode, status)
+		}
+	}
+	if relay.Pending() != 1 {
+		t.Errorf("Pending() = %d, want 1", relay.Pending())
+	}
+}
+
+func TestRelayShutdown(t *testing.T) {
+	target, statuses := newTarget(t, "target", 1)
+	relay, err := New(Options{
+		Target:             target.URL + "/events",
+		GitHubSecret:       "inbound",
+		TargetGitHubSecret: "target",
+		RetryInterval:      time.Hour,
+	})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	server := httptest.NewServer(relay.Handler())
+	defer server.Close()
+
+	ids := make([]string, 0, 2)
+	for i := 0; i < 2; i++ {
+		result, err := sender.Send(server.URL, sender.Options{Provider: "github", Event: sender.EventPush, Repository: "kubefirst/gitops", Secret: "inbound"})
+		if err != nil {
+			t.Fatalf("Send() error = %v", err)
+		}
+		ids = append(ids, result.DeliveryID)
+	}
+
+	// Stop the relay while the first delivery waits for its retry
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- relay.Run(ctx)
+	}()
+	waitStatuses(t, statuses, 1)
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	if relay.Pending() != 2 {
+		t.Fatalf("Pending() = %d, want 2", relay.Pending())
+	}
+	if head, _ := relay.head(); head.event.DeliveryID != ids[0] {
+		t.Errorf("head of the queue is delivery %s, want %s", head.event.DeliveryID, ids[0])
+	}
+
+	// A restarted relay forwards both deliveries, the interrupted one first
+	ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+	go relay.Run(ctx)
+	want := []int{http.StatusOK, http.StatusOK}
+	if got := waitStatuses(t, statuses, len(want)); !reflect.DeepEqual(got, want) {
+		t.Errorf("target answered %v, want %v", got, want)
+	}
+}
+
+func TestSubscribe(t *testing.T) {
+	target, statuses := newTarget(t, "target", 0)
+	payload := []byte(`{"ref":"refs/heads/main","repository":{"full_name":"kubefirst/gitops"}}`)
+	messages := []map[string]interface{}{
+		{
+			receiver.HeaderGitHubEvent:     "push",
+			receiver.HeaderGitHubSignature: receiver.SignGitHub("inbound", payload),
+			"bodyB":                        base64.StdEncoding.EncodeToString(payload),
+			"body":                         json.RawMessage(payload),
+			"timestamp":                    1700000000000,
+		},
+		{
+			receiver.HeaderGitLabEvent: "Push Hook",
+			receiver.HeaderGitLabToken: "inbound",
+			"body":                     json.RawMessage(`{"object_kind":"push","project":{"path_with_namespace":"kubefirst/gitops"}}`),
+		},
+		{
+			receiver.HeaderGitLabEvent: "Push Hook",
+			receiver.HeaderGitLabToken: "other",
+			"body":                     json.RawMessage(`{"object_kind":"push"}`),
+		},
+	}
+
+	channel := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, "event: ready\ndata: {}\n\n")
+		for _, message := range messages {
+			data, _ := json.Marshal(message)
+			fmt.Fprintf(w, "data: %s\n\n", data)
+		}
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	}))
+	defer channel.Close()
+
+	relay, err := New(Options{
+		Target:             target.URL + "/events",
+		GitHubSecret:       "inbound",
+		GitLabSecret:       "inbound",
+		TargetGitHubSecret: "target",
+		TargetGitLabSecret: "target",
+	})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go relay.Run(ctx)
+	go relay.Subscribe(ctx, channel.URL)
+
+	// The third message fails verification and is never forwarded
+	want := []int{http.StatusOK, http.StatusOK}
+	if got := waitStatuses(t, statuses, len(want)); !reflect.DeepEqual(got, want) {
+		t.Errorf("target answered %v, want %v", got, want)
+	}
+}
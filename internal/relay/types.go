@@ -0,0 +1,46 @@
+package relay
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/kubefirst/git-helper/internal/receiver"
+)
+
+// Options configures the webhook relay
+type Options struct {
+	// Target is the url verified deliveries are forwarded to, e.g.
+	// http://atlantis.atlantis.svc/events
+	Target string
+	// GitHubSecret and GitLabSecret verify incoming deliveries, which are
+	// accepted unverified if they are empty
+	GitHubSecret string
+	GitLabSecret string
+	// TargetGitHubSecret and TargetGitLabSecret sign forwarded deliveries,
+	// defaulting to the secrets of incoming deliveries
+	TargetGitHubSecret string
+	TargetGitLabSecret string
+	// InsecureSkipVerify allows a target secret without an incoming secret
+	// for the same provider, signing unverified deliveries for the target
+	InsecureSkipVerify bool
+	// QueueSize bounds the deliveries buffered in memory while the target is
+	// down, further deliveries are rejected with a 503
+	QueueSize int
+	// RetryInterval is the delay before retrying a failed forward, doubled
+	// after each attempt up to MaxRetryInterval
+	RetryInterval    time.Duration
+	MaxRetryInterval time.Duration
+	// MaxAge drops deliveries that could not be forwarded within it, 0 keeps
+	// retrying until the relay stops
+	MaxAge time.Duration
+	// Timeout bounds each forwarding attempt
+	Timeout time.Duration
+}
+
+// delivery is a verified delivery waiting to be forwarded
+type delivery struct {
+	event receiver.Event
+	// header holds the original headers, less the ones the relay sets
+	header     http.Header
+	receivedAt time.Time
+}
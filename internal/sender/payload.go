@@ -0,0 +1,260 @@
+package sender
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"hash/fnv"
+	"path"
+	"strings"
+	"time"
+)
+
+const (
+	EventPush         string = "push"
+	EventPullRequest  string = "pull_request"
+	EventIssueComment string = "issue_comment"
+	EventMergeRequest string = "merge_request"
+	EventNote         string = "note"
+)
+
+// AllowedEvents lists the events that can be sent, for each provider
+var AllowedEvents map[string][]string = map[string][]string{
+	"github": {EventPush, EventPullRequest, EventIssueComment},
+	"gitlab": {EventPush, EventMergeRequest, EventNote},
+}
+
+// eventAliases maps the events of one provider to the equivalent event of
+// the other, so the same name can be used for both
+var eventAliases map[string]map[string]string = map[string]map[string]string{
+	"github": {EventMergeRequest: EventPullRequest, EventNote: EventIssueComment, "comment": EventIssueComment},
+	"gitlab": {EventPullRequest: EventMergeRequest, EventIssueComment: EventNote, "comment": EventNote},
+}
+
+// gitlabEventHeaders are the X-Gitlab-Event values of each event
+var gitlabEventHeaders map[string]string = map[string]string{
+	EventPush:         "Push Hook",
+	EventMergeRequest: "Merge Request Hook",
+	EventNote:         "Note Hook",
+}
+
+// normalizeEvent resolves aliases and checks the event is supported
+func normalizeEvent(provider string, event string) (string, error) {
+	allowed, ok := AllowedEvents[provider]
+	if !ok {
+		return "", fmt.Errorf("unsupported provider %s", provider)
+	}
+	if alias, ok := eventAliases[provider][event]; ok {
+		event = alias
+	}
+	for _, name := range allowed {
+		if name == event {
+			return event, nil
+		}
+	}
+	return "", fmt.Errorf("unsupported %s event %s, must be one of %s", provider, event, allowed)
+}
+
+// buildPayload returns the payload of a synthetic event, shaped like the
+// payloads GitHub and GitLab send
+func buildPayload(opts Options, event string) (map[string]interface{}, error) {
+	before, err := randomSHA()
+	if err != nil {
+		return nil, err
+	}
+	after, err := randomSHA()
+	if err != nil {
+		return nil, err
+	}
+
+	switch opts.Provider {
+	case "github":
+		return githubPayload(opts, event, before, after), nil
+	default:
+		return gitlabPayload(opts, event, before, after), nil
+	}
+}
+
+func githubPayload(opts Options, event string, before string, after string) map[string]interface{} {
+	owner, name := path.Split(opts.Repository)
+	owner = strings.TrimSuffix(owner, "/")
+	htmlURL := "https://github.com/" + opts.Repository
+	repository := map[string]interface{}{
+		"id":             syntheticID(opts.Repository),
+		"name":           name,
+		"full_name":      opts.Repository,
+		"private":        true,
+		"owner":          map[string]interface{}{"login": owner, "type": "Organization"},
+		"html_url":       htmlURL,
+		"clone_url":      htmlURL + ".git",
+		"ssh_url":        fmt.Sprintf("git@github.com:%s.git", opts.Repository),
+		"default_branch": opts.Base,
+	}
+	sender := map[string]interface{}{"login": opts.Sender, "id": syntheticID(opts.Sender), "type": "User"}
+
+	switch event {
+	case EventPush:
+		commit := map[string]interface{}{
+			"id":        after,
+			"message":   "Synthetic commit sent by git-helper",
+			"timestamp": time.Now().UTC().Format(time.RFC3339),
+			"url":       fmt.Sprintf("%s/commit/%s", htmlURL, after),
+			"author":    map[string]interface{}{"name": opts.Sender, "username": opts.Sender},
+			"added":     []string{},
+			"removed":   []string{},
+			"modified":  []string{"README.md"},
+		}
+		return map[string]interface{}{
+			"ref":         "refs/heads/" + opts.Branch,
+			"before":      before,
+			"after":       after,
+			"created":     false,
+			"deleted":     false,
+			"forced":      false,
+			"compare":     fmt.Sprintf("%s/compare/%s...%s", htmlURL, before[:12], after[:12]),
+			"commits":     []interface{}{commit},
+			"head_commit": commit,
+			"pusher":      map[string]interface{}{"name": opts.Sender},
+			"repository":  repository,
+			"sender":      sender,
+		}
+	case EventPullRequest:
+		return map[string]interface{}{
+			"action":       "opened",
+			"number":       opts.Number,
+			"pull_request": githubPullRequest(opts, repository, sender, before, after),
+			"repository":   repository,
+			"sender":       sender,
+		}
+	default:
+		return map[string]interface{}{
+			"action": "created",
+			"issue": map[string]interface{}{
+				"number":       opts.Number,
+				"title":        "Synthetic pull request sent by git-helper",
+				"state":        "open",
+				"user":         sender,
+				"html_url":     fmt.Sprintf("%s/pull/%d", htmlURL, opts.Number),
+				"pull_request": map[string]interface{}{"url": fmt.Sprintf("https://api.github.com/repos/%s/pulls/%d", opts.Repository, opts.Number)},
+			},
+			"comment": map[string]interface{}{
+				"id":       syntheticID(opts.Comment),
+				"body":     opts.Comment,
+				"user":     sender,
+				"html_url": fmt.Sprintf("%s/pull/%d#issuecomment-%d", htmlURL, opts.Number, syntheticID(opts.Comment)),
+			},
+			"repository": repository,
+			"sender":     sender,
+		}
+	}
+}
+
+func githubPullRequest(opts Options, repository map[string]interface{}, sender map[string]interface{}, baseSHA string, headSHA string) map[string]interface{} {
+	return map[string]interface{}{
+		"id":       syntheticID(fmt.Sprintf("%s#%d", opts.Repository, opts.Number)),
+		"number":   opts.Number,
+		"state":    "open",
+		"title":    "Synthetic pull request sent by git-helper",
+		"user":     sender,
+		"html_url": fmt.Sprintf("https://github.com/%s/pull/%d", opts.Repository, opts.Number),
+		"draft":    false,
+		"merged":   false,
+		"head":     map[string]interface{}{"ref": opts.Branch, "sha": headSHA, "repo": repository},
+		"base":     map[string]interface{}{"ref": opts.Base, "sha": baseSHA, "repo": repository},
+	}
+}
+
+func gitlabPayload(opts Options, event string, before string, after string) map[string]interface{} {
+	namespace, name := path.Split(opts.Repository)
+	webURL := "https://gitlab.com/" + opts.Repository
+	project := map[string]interface{}{
+		"id":                  syntheticID(opts.Repository),
+		"name":                name,
+		"namespace":           strings.TrimSuffix(namespace, "/"),
+		"path_with_namespace": opts.Repository,
+		"web_url":             webURL,
+		"git_http_url":        webURL + ".git",
+		"git_ssh_url":         fmt.Sprintf("git@gitlab.com:%s.git", opts.Repository),
+		"default_branch":      opts.Base,
+		"visibility_level":    0,
+	}
+	user := map[string]interface{}{"id": syntheticID(opts.Sender), "name": opts.Sender, "username": opts.Sender}
+	mergeRequest := map[string]interface{}{
+		"id":            syntheticID(fmt.Sprintf("%s!%d", opts.Repository, opts.Number)),
+		"iid":           opts.Number,
+		"title":         "Synthetic merge request sent by git-helper",
+		"state":         "opened",
+		"source_branch": opts.Branch,
+		"target_branch": opts.Base,
+		"last_commit":   map[string]interface{}{"id": after, "message": "Synthetic commit sent by git-helper"},
+		"url":           fmt.Sprintf("%s/-/merge_requests/%d", webURL, opts.Number),
+	}
+
+	switch event {
+	case EventPush:
+		return map[string]interface{}{
+			"object_kind":   "push",
+			"event_name":    "push",
+			"ref":           "refs/heads/" + opts.Branch,
+			"before":        before,
+			"after":         after,
+			"checkout_sha":  after,
+			"user_username": opts.Sender,
+			"user_name":     opts.Sender,
+			"project_id":    project["id"],
+			"project":       project,
+			"commits": []interface{}{map[string]interface{}{
+				"id":        after,
+				"message":   "Synthetic commit sent by git-helper",
+				"timestamp": time.Now().UTC().Format(time.RFC3339),
+				"url":       fmt.Sprintf("%s/-/commit/%s", webURL, after),
+				"modified":  []string{"README.md"},
+			}},
+			"total_commits_count": 1,
+		}
+	case EventMergeRequest:
+		attributes := map[string]interface{}{"action": "open"}
+		for key, value := range mergeRequest {
+			attributes[key] = value
+		}
+		return map[string]interface{}{
+			"object_kind":       "merge_request",
+			"event_type":        "merge_request",
+			"user":              user,
+			"project":           project,
+			"object_attributes": attributes,
+		}
+	default:
+		return map[string]interface{}{
+			"object_kind": "note",
+			"event_type":  "note",
+			"user":        user,
+			"project_id":  project["id"],
+			"project":     project,
+			"object_attributes": map[string]interface{}{
+				"id":            syntheticID(opts.Comment),
+				"note":          opts.Comment,
+				"noteable_type": "MergeRequest",
+				"url":           fmt.Sprintf("%s/-/merge_requests/%d#note_%d", webURL, opts.Number, syntheticID(opts.Comment)),
+			},
+			"merge_request": mergeRequest,
+		}
+	}
+}
+
+// syntheticID derives a stable positive ID from a name
+func syntheticID(name string) int64 {
+	hash := fnv.New32a()
+	hash.Write([]byte(name))
+	return int64(hash.Sum32())
+}
+
+// randomSHA returns a random 40 character commit sha
+func randomSHA() (string, error) {
+	sha := make([]byte, 20)
+	_, err := rand.Read(sha)
+	if err != nil {
+		return "", fmt.Errorf("error generating a commit sha: %s", err)
+	}
+	return hex.EncodeToString(sha), nil
+}
@@ -0,0 +1,94 @@
+package sender
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/kubefirst/git-helper/internal/receiver"
+)
+
+// Send builds a synthetic event and POSTs it to url the way the provider
+// would, signed with the secret
+func Send(url string, opts Options) (Result, error) {
+	req, err := NewRequest(url, opts)
+	if err != nil {
+		return Result{}, err
+	}
+	deliveryID := req.Header.Get(receiver.HeaderGitHubDelivery) + req.Header.Get(receiver.HeaderGitLabEventUUID)
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return Result{}, fmt.Errorf("error sending %s event to %s: %s", opts.Event, url, err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+
+	return Result{
+		DeliveryID: deliveryID,
+		StatusCode: resp.StatusCode,
+		Body:       strings.TrimSpace(string(body)),
+	}, nil
+}
+
+// NewRequest returns the signed delivery request of a synthetic event
+func NewRequest(url string, opts Options) (*http.Request, error) {
+	event, err := normalizeEvent(opts.Provider, opts.Event)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.Contains(opts.Repository, "/") {
+		return nil, fmt.Errorf("repository %s must include its owner, e.g. owner/repo", opts.Repository)
+	}
+	if opts.Branch == "" {
+		opts.Branch = "git-helper-test"
+	}
+	if opts.Base == "" {
+		opts.Base = "main"
+	}
+	if opts.Number == 0 {
+		opts.Number = 1
+	}
+	if opts.Sender == "" {
+		opts.Sender = "git-helper"
+	}
+
+	payload, err := buildPayload(opts, event)
+	if err != nil {
+		return nil, err
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("error encoding payload: %s", err)
+	}
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	switch opts.Provider {
+	case "github":
+		req.Header.Set("User-Agent", "GitHub-Hookshot/git-helper")
+		req.Header.Set(receiver.HeaderGitHubEvent, event)
+		req.Header.Set(receiver.HeaderGitHubDelivery, uuid.New().String())
+		if opts.Secret != "" {
+			req.Header.Set(receiver.HeaderGitHubSignature, receiver.SignGitHub(opts.Secret, body))
+		}
+	case "gitlab":
+		req.Header.Set("User-Agent", "GitLab/git-helper")
+		req.Header.Set(receiver.HeaderGitLabEvent, gitlabEventHeaders[event])
+		req.Header.Set(receiver.HeaderGitLabEventUUID, uuid.New().String())
+		if opts.Secret != "" {
+			req.Header.Set(receiver.HeaderGitLabToken, opts.Secret)
+		}
+	}
+
+	return req, nil
+}
@@ -0,0 +1,77 @@
+package sender
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/kubefirst/git-helper/internal/receiver"
+)
+
+func TestSend(t *testing.T) {
+	tests := []struct {
+		name        string
+		opts        Options
+		wantStatus  int
+		wantSummary string
+		wantErr     bool
+	}{
+		{
+			name:        "If sending a GitHub pull request, should sign it",
+			opts:        Options{Provider: "github", Event: EventPullRequest, Repository: "kubefirst/gitops", Number: 12, Secret: "secret"},
+			wantStatus:  http.StatusOK,
+			wantSummary: "github pull_request (opened) kubefirst/gitops #12 by git-helper [verified",
+		},
+		{
+			name:        "If sending a GitLab comment, should map it to a note",
+			opts:        Options{Provider: "gitlab", Event: EventIssueComment, Repository: "kubefirst/infra/gitops", Number: 7, Secret: "secret"},
+			wantStatus:  http.StatusOK,
+			wantSummary: "gitlab Note Hook kubefirst/infra/gitops #7 by git-helper [verified",
+		},
+		{
+			name:        "If sending a GitLab push, should deliver it",
+			opts:        Options{Provider: "gitlab", Event: EventPush, Repository: "kubefirst/gitops", Branch: "feature", Secret: "secret"},
+			wantStatus:  http.StatusOK,
+			wantSummary: "gitlab Push Hook kubefirst/gitops refs/heads/feature by git-helper [verified",
+		},
+		{
+			name:       "If signed with another secret, should be rejected",
+			opts:       Options{Provider: "github", Event: EventPush, Repository: "kubefirst/gitops", Secret: "other"},
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:    "If the event is unsupported, should return an error",
+			opts:    Options{Provider: "github", Event: "release", Repository: "kubefirst/gitops"},
+			wantErr: true,
+		},
+		{
+			name:    "If the repository has no owner, should return an error",
+			opts:    Options{Provider: "github", Event: EventPush, Repository: "gitops"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			server := httptest.NewServer(receiver.Handler(receiver.Options{GitHubSecret: "secret", GitLabSecret: "secret", Out: &out}))
+			defer server.Close()
+
+			result, err := Send(server.URL+"/events", tt.opts)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Send() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if result.StatusCode != tt.wantStatus {
+				t.Errorf("Send() status = %d, want %d: %s", result.StatusCode, tt.wantStatus, result.Body)
+			}
+			if !strings.Contains(out.String(), tt.wantSummary) {
+				t.Errorf("receiver printed %q, want %q", out.String(), tt.wantSummary)
+			}
+		})
+	}
+}
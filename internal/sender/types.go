@@ -0,0 +1,30 @@
+package sender
+
+// Options describes a synthetic webhook event
+type Options struct {
+	// Provider is github or gitlab
+	Provider string
+	// Event is push, pull_request, issue_comment, merge_request or note
+	Event string
+	// Repository is the full name, e.g. owner/repo or group/subgroup/project
+	Repository string
+	// Branch is the pushed or source branch
+	Branch string
+	// Base is the target branch of pull and merge requests
+	Base string
+	// Number is the pull or merge request number
+	Number int
+	// Comment is the body of issue_comment and note events
+	Comment string
+	// Sender is the user login the event is attributed to
+	Sender string
+	// Secret signs GitHub payloads or is sent as the GitLab token
+	Secret string
+}
+
+// Result is the answer of the webhook consumer to a synthetic event
+type Result struct {
+	DeliveryID string
+	StatusCode int
+	Body       string
+}
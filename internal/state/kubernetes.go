@@ -0,0 +1,108 @@
+package state
+
+import (
+	"github.com/kubefirst/git-helper/internal/kubernetes"
+	"k8s.io/apimachinery/pkg/api/errors"
+)
+
+// ConfigMapStore keeps state as JSON entries in a ConfigMap
+type ConfigMapStore struct {
+	kube      *kubernetes.Client
+	namespace string
+	name      string
+}
+
+// NewConfigMapStore returns a Store backed by the named ConfigMap
+func NewConfigMapStore(kube *kubernetes.Client, namespace string, name string) *ConfigMapStore {
+	return &ConfigMapStore{kube: kube, namespace: namespace, name: name}
+}
+
+// Get returns the state stored under key, or ErrNotFound
+func (s *ConfigMapStore) Get(key string) (State, error) {
+	states, err := s.List()
+	if err != nil {
+		return State{}, err
+	}
+	state, ok := states[key]
+	if !ok {
+		return State{}, ErrNotFound
+	}
+	return state, nil
+}
+
+// Put stores state under key, replacing any previous value
+func (s *ConfigMapStore) Put(key string, state State) error {
+	entry, err := encodeEntry(key, state)
+	if err != nil {
+		return err
+	}
+	return s.kube.PatchConfigMap(s.namespace, s.name, entry)
+}
+
+// Delete removes the state stored under key, if any
+func (s *ConfigMapStore) Delete(key string) error {
+	return s.kube.RemoveConfigMapKeys(s.namespace, s.name, keyPrefix+key)
+}
+
+// List returns all stored states keyed by binding
+func (s *ConfigMapStore) List() (map[string]State, error) {
+	data, err := s.kube.ReadConfigMap(s.namespace, s.name)
+	if errors.IsNotFound(err) {
+		return map[string]State{}, nil
+	}
+	if err != nil {
+		return map[string]State{}, err
+	}
+	return decodeEntries(data)
+}
+
+// SecretStore keeps state as JSON entries in a Secret
+type SecretStore struct {
+	kube      *kubernetes.Client
+	namespace string
+	name      string
+}
+
+// NewSecretStore returns a Store backed by the named Secret
+func NewSecretStore(kube *kubernetes.Client, namespace string, name string) *SecretStore {
+	return &SecretStore{kube: kube, namespace: namespace, name: name}
+}
+
+// Get returns the state stored under key, or ErrNotFound
+func (s *SecretStore) Get(key string) (State, error) {
+	states, err := s.List()
+	if err != nil {
+		return State{}, err
+	}
+	state, ok := states[key]
+	if !ok {
+		return State{}, ErrNotFound
+	}
+	return state, nil
+}
+
+// Put stores state under key, replacing any previous value
+func (s *SecretStore) Put(key string, state State) error {
+	entry, err := encodeEntry(key, state)
+	if err != nil {
+		return err
+	}
+	return s.kube.PatchSecret(s.namespace, s.name, entry)
+}
+
+// Delete removes the state stored under key, if any
+func (s *SecretStore) Delete(key string) error {
+	return s.kube.RemoveSecretKeys(s.namespace, s.name, keyPrefix+key)
+}
+
+// List returns all stored states keyed by binding
+func (s *SecretStore) List() (map[string]State, error) {
+	data, err := s.kube.ReadSecret(s.namespace, s.name)
+	if errors.IsNotFound(err) {
+		return map[string]State{}, nil
+	}
+	if err != nil {
+		return map[string]State{}, err
+	}
+	return decodeEntries(data)
+}
@@ -0,0 +1,164 @@
+package state
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/spf13/afero"
+)
+
+// Use afero for file system to allow for easier testing
+var fs afero.Fs = afero.NewOsFs()
+
+// FileStore keeps state in a local JSON file
+type FileStore struct {
+	mu   sync.Mutex
+	fs   afero.Fs
+	path string
+}
+
+// NewFileStore returns a Store backed by a JSON file at path
+func NewFileStore(fs afero.Fs, path string) *FileStore {
+	return &FileStore{fs: fs, path: path}
+}
+
+// Get returns the state stored under key, or ErrNotFound
+func (s *FileStore) Get(key string) (State, error) {
+	states, err := s.List()
+	if err != nil {
+		return State{}, err
+	}
+	state, ok := states[key]
+	if !ok {
+		return State{}, ErrNotFound
+	}
+	return state, nil
+}
+
+// Put stores state under key, replacing any previous value
+func (s *FileStore) Put(key string, state State) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	states, err := s.read()
+	if err != nil {
+		return err
+	}
+	states[key] = state
+	return s.write(states)
+}
+
+// Delete removes the state stored under key, if any
+func (s *FileStore) Delete(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	states, err := s.read()
+	if err != nil {
+		return err
+	}
+	delete(states, key)
+	return s.write(states)
+}
+
+// List returns all stored states keyed by binding
+func (s *FileStore) List() (map[string]State, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.read()
+}
+
+// read parses the state file, which may not exist yet
+func (s *FileStore) read() (map[string]State, error) {
+	states := make(map[string]State)
+	data, err := afero.ReadFile(s.fs, s.path)
+	if os.IsNotExist(err) {
+		return states, nil
+	}
+	if err != nil {
+		return states, fmt.Errorf("error reading state file %s: %s", s.path, err)
+	}
+	if len(data) == 0 {
+		return states, nil
+	}
+	err = json.Unmarshal(data, &states)
+	if err != nil {
+		return states, fmt.Errorf("error parsing state file %s: %s", s.path, err)
+	}
+	return states, nil
+}
+
+// write replaces the state file
+func (s *FileStore) write(states map[string]State) error {
+	data, err := json.MarshalIndent(states, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error serializing state: %s", err)
+	}
+
+	err = s.fs.MkdirAll(filepath.Dir(s.path), 0755)
+	if err != nil {
+		return fmt.Errorf("error creating state directory: %s", err)
+	}
+	err = afero.WriteFile(s.fs, s.path, data, 0600)
+	if err != nil {
+		return fmt.Errorf("error writing state file %s: %s", s.path, err)
+	}
+	return nil
+}
+
+// MemoryStore keeps state in memory for the lifetime of the process
+type MemoryStore struct {
+	mu     sync.Mutex
+	states map[string]State
+}
+
+// NewMemoryStore returns an empty in-memory Store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{states: make(map[string]State)}
+}
+
+// Get returns the state stored under key, or ErrNotFound
+func (s *MemoryStore) Get(key string) (State, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	state, ok := s.states[key]
+	if !ok {
+		return State{}, ErrNotFound
+	}
+	return state, nil
+}
+
+// Put stores state under key, replacing any previous value
+func (s *MemoryStore) Put(key string, state State) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.states[key] = state
+	return nil
+}
+
+// Delete removes the state stored under key, if any
+func (s *MemoryStore) Delete(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.states, key)
+	return nil
+}
+
+// List returns all stored states keyed by binding
+func (s *MemoryStore) List() (map[string]State, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	states := make(map[string]State, len(s.states))
+	for key, state := range s.states {
+		states[key] = state
+	}
+	return states, nil
+}
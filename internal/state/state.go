@@ -0,0 +1,132 @@
+package state
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/kubefirst/git-helper/internal/kubernetes"
+)
+
+const (
+	BackendConfigMap string = "configmap"
+	BackendSecret    string = "secret"
+	BackendFile      string = "file"
+	BackendMemory    string = "memory"
+
+	// DefaultHistoryLimit is the number of previous hooks kept per binding
+	DefaultHistoryLimit int = 10
+
+	// keyPrefix marks ConfigMap and Secret keys that hold state, so the
+	// backends can share an object with other data
+	keyPrefix string = "webhook."
+)
+
+// AllowedBackends lists the supported Store backends
+var AllowedBackends []string = []string{BackendConfigMap, BackendSecret, BackendFile, BackendMemory}
+
+// NewStore returns the Store backend selected by opts
+// kube is only used by the configmap and secret backends
+func NewStore(opts Options, kube *kubernetes.Client) (Store, error) {
+	switch opts.Backend {
+	case BackendConfigMap, "":
+		return NewConfigMapStore(kube, opts.Namespace, opts.Name), nil
+	case BackendSecret:
+		return NewSecretStore(kube, opts.Namespace, opts.Name), nil
+	case BackendFile:
+		if opts.Path == "" {
+			return nil, fmt.Errorf("a path is required when using the file state backend")
+		}
+		return NewFileStore(fs, opts.Path), nil
+	case BackendMemory:
+		return NewMemoryStore(), nil
+	default:
+		return nil, fmt.Errorf("unsupported state backend %s, must be one of %s", opts.Backend, AllowedBackends)
+	}
+}
+
+// Key returns the key state is stored under for a repository or project
+// The parts are escaped before being joined with dots, so distinct tuples
+// never share a key
+func Key(provider string, owner string, repository string) string {
+	return strings.Join([]string{escapeKeyPart(provider), escapeKeyPart(owner), escapeKeyPart(repository)}, ".")
+}
+
+// escapeKeyPart replaces every byte that is not allowed in ConfigMap and
+// Secret keys, as well as the dot separator and the underscore escape
+// character itself, with an underscore followed by its hex value
+func escapeKeyPart(part string) string {
+	var b strings.Builder
+	for i := 0; i < len(part); i++ {
+		c := part[i]
+		if c == '-' || ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z') || ('0' <= c && c <= '9') {
+			b.WriteByte(c)
+			continue
+		}
+		fmt.Fprintf(&b, "_%02x", c)
+	}
+	return b.String()
+}
+
+// HashSecret returns a hex encoded sha256 hash of a hook secret
+func HashSecret(secret string) string {
+	if secret == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// Rotate returns next with the current hook prepended to the history carried
+// over from s, keeping at most limit entries (DefaultHistoryLimit if not positive)
+func (s State) Rotate(next State, limit int) State {
+	if limit <= 0 {
+		limit = DefaultHistoryLimit
+	}
+
+	history := make([]HistoryEntry, 0, len(s.History)+1)
+	if s.HookID != 0 || s.URL != "" {
+		history = append(history, HistoryEntry{
+			HookID:       s.HookID,
+			URL:          s.URL,
+			SecretHash:   s.SecretHash,
+			RegisteredAt: s.UpdatedAt,
+			ReplacedAt:   next.UpdatedAt,
+		})
+	}
+	history = append(history, s.History...)
+	if len(history) > limit {
+		history = history[:limit]
+	}
+	next.History = history
+
+	return next
+}
+
+// decodeEntries parses the prefixed state entries of a ConfigMap or Secret
+func decodeEntries(data map[string]string) (map[string]State, error) {
+	states := make(map[string]State)
+	for dataKey, value := range data {
+		if !strings.HasPrefix(dataKey, keyPrefix) {
+			continue
+		}
+		var s State
+		err := json.Unmarshal([]byte(value), &s)
+		if err != nil {
+			return map[string]State{}, fmt.Errorf("error parsing state %s: %s", dataKey, err)
+		}
+		states[strings.TrimPrefix(dataKey, keyPrefix)] = s
+	}
+	return states, nil
+}
+
+// encodeEntry serializes state for storage in a ConfigMap or Secret
+func encodeEntry(key string, s State) (map[string]string, error) {
+	value, err := json.Marshal(s)
+	if err != nil {
+		return map[string]string{}, fmt.Errorf("error serializing state %s: %s", key, err)
+	}
+	return map[string]string{keyPrefix + key: string(value)}, nil
+}
@@ -0,0 +1,160 @@
+package state
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/kubefirst/git-helper/internal/kubernetes"
+	"github.com/spf13/afero"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestStore(t *testing.T) {
+	kube := kubernetes.NewClientFromInterface(fake.NewSimpleClientset(), nil)
+	key := Key("github", "kubefirst", "gitops")
+	want := State{
+		Provider:   "github",
+		Owner:      "kubefirst",
+		Repository: "gitops",
+		HookID:     42,
+		URL:        "https://atlantis.ngrok.io/events",
+		SecretHash: HashSecret("secret"),
+		UpdatedAt:  time.Date(2023, 3, 1, 12, 0, 0, 0, time.UTC),
+	}
+
+	tests := []struct {
+		name  string
+		store Store
+	}{
+		{name: "If using the memory backend, should round trip state", store: NewMemoryStore()},
+		{name: "If using the file backend, should round trip state", store: NewFileStore(afero.NewMemMapFs(), "/tmp/git-helper/state.json")},
+		{name: "If using the configmap backend, should round trip state", store: NewConfigMapStore(kube, "atlantis", "ngrok")},
+		{name: "If using the secret backend, should round trip state", store: NewSecretStore(kube, "atlantis", "git-helper-state")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := tt.store.Get(key)
+			if err != ErrNotFound {
+				t.Fatalf("Get() before Put() error = %v, want %v", err, ErrNotFound)
+			}
+
+			err = tt.store.Put(key, want)
+			if err != nil {
+				t.Fatalf("Put() error = %v", err)
+			}
+			got, err := tt.store.Get(key)
+			if err != nil {
+				t.Fatalf("Get() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("Get() = %v, want %v", got, want)
+			}
+
+			err = tt.store.Delete(key)
+			if err != nil {
+				t.Fatalf("Delete() error = %v", err)
+			}
+			states, err := tt.store.List()
+			if err != nil {
+				t.Fatalf("List() error = %v", err)
+			}
+			if len(states) != 0 {
+				t.Errorf("List() after Delete() = %v, want empty", states)
+			}
+		})
+	}
+}
+
+func TestRotate(t *testing.T) {
+	first := time.Date(2023, 3, 1, 12, 0, 0, 0, time.UTC)
+	second := first.Add(time.Hour)
+	current := State{
+		HookID:    1,
+		URL:       "https://one.ngrok.io/events",
+		UpdatedAt: first,
+		History: []HistoryEntry{
+			{HookID: 0, URL: "https://zero.ngrok.io/events"},
+		},
+	}
+
+	tests := []struct {
+		name  string
+		limit int
+		want  []HistoryEntry
+	}{
+		{
+			name:  "If under the limit, should prepend the current hook",
+			limit: DefaultHistoryLimit,
+			want: []HistoryEntry{
+				{HookID: 1, URL: "https://one.ngrok.io/events", RegisteredAt: first, ReplacedAt: second},
+				{HookID: 0, URL: "https://zero.ngrok.io/events"},
+			},
+		},
+		{
+			name:  "If over the limit, should drop the oldest entries",
+			limit: 1,
+			want: []HistoryEntry{
+				{HookID: 1, URL: "https://one.ngrok.io/events", RegisteredAt: first, ReplacedAt: second},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := current.Rotate(State{HookID: 2, URL: "https://two.ngrok.io/events", UpdatedAt: second}, tt.limit)
+			if !reflect.DeepEqual(got.History, tt.want) {
+				t.Errorf("Rotate() history = %v, want %v", got.History, tt.want)
+			}
+		})
+	}
+}
+
+func TestKey(t *testing.T) {
+	tests := []struct {
+		name  string
+		parts [3]string
+		want  string
+	}{
+		{
+			name:  "If the parts only use allowed characters, should join them with dots",
+			parts: [3]string{"github", "kubefirst", "gitops"},
+			want:  "github.kubefirst.gitops",
+		},
+		{
+			name:  "If the owner is a nested gitlab group, should escape the slash",
+			parts: [3]string{"gitlab", "kubefirst/platform", "gitops"},
+			want:  "gitlab.kubefirst_2fplatform.gitops",
+		},
+		{
+			name:  "If a part contains dots or underscores, should escape them",
+			parts: [3]string{"github", "kube.first", "git_ops"},
+			want:  "github.kube_2efirst.git_5fops",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Key(tt.parts[0], tt.parts[1], tt.parts[2])
+			if got != tt.want {
+				t.Errorf("Key() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	t.Run("If the tuples differ only in separators, should return distinct keys", func(t *testing.T) {
+		tuples := [][3]string{
+			{"gitlab", "a/b", "c"},
+			{"gitlab", "a_b", "c"},
+			{"gitlab", "a.b", "c"},
+			{"gitlab", "a", "b.c"},
+			{"gitlab", "a_2fb", "c"},
+		}
+		seen := make(map[string][3]string)
+		for _, tuple := range tuples {
+			key := Key(tuple[0], tuple[1], tuple[2])
+			if other, ok := seen[key]; ok {
+				t.Errorf("Key(%v) = Key(%v) = %s", tuple, other, key)
+			}
+			seen[key] = tuple
+		}
+	})
+}
@@ -0,0 +1,57 @@
+package state
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrNotFound is returned when no state is stored under a key
+var ErrNotFound error = errors.New("state not found")
+
+// Store persists the webhook state of each binding
+type Store interface {
+	// Get returns the state stored under key, or ErrNotFound
+	Get(key string) (State, error)
+	// Put stores state under key, replacing any previous value
+	Put(key string, state State) error
+	// Delete removes the state stored under key, if any
+	Delete(key string) error
+	// List returns all stored states keyed by binding
+	List() (map[string]State, error)
+}
+
+// State describes the webhook registered for a single repository or project
+type State struct {
+	Provider   string `json:"provider"`
+	Owner      string `json:"owner"`
+	Repository string `json:"repository"`
+	HookID     int64  `json:"hookID"`
+	// URL is the full url the hook delivers to
+	URL string `json:"url"`
+	// SecretHash is a hash of the hook secret, used to detect rotation
+	// without storing the secret itself
+	SecretHash string    `json:"secretHash,omitempty"`
+	UpdatedAt  time.Time `json:"updatedAt"`
+	// History lists previously registered hooks, most recent first
+	History []HistoryEntry `json:"history,omitempty"`
+}
+
+// HistoryEntry describes a hook that was registered before the current one
+type HistoryEntry struct {
+	HookID       int64     `json:"hookID"`
+	URL          string    `json:"url"`
+	SecretHash   string    `json:"secretHash,omitempty"`
+	RegisteredAt time.Time `json:"registeredAt"`
+	ReplacedAt   time.Time `json:"replacedAt"`
+}
+
+// Options holds values used to select and configure a Store backend
+type Options struct {
+	// Backend is one of configmap, secret, file or memory
+	Backend string
+	// Namespace and Name locate the ConfigMap or Secret backends
+	Namespace string
+	Name      string
+	// Path locates the file backend
+	Path string
+}
@@ -0,0 +1,212 @@
+package sync
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	stdsync "sync"
+
+	"github.com/kubefirst/git-helper/internal/common"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	BulkCreate string = "create"
+	BulkUpdate string = "update"
+	BulkDelete string = "delete"
+	BulkSync   string = "sync"
+
+	bulkActionCreated   string = "created"
+	bulkActionUpdated   string = "updated"
+	bulkActionDeleted   string = "deleted"
+	bulkActionUnchanged string = "unchanged"
+	bulkActionFailed    string = "failed"
+)
+
+// AllowedBulkOperations lists the operations supported by RunBulk
+var AllowedBulkOperations []string = []string{BulkCreate, BulkUpdate, BulkDelete, BulkSync}
+
+// RunBulk applies an operation to the hooks delivering to the request url on
+// every selected repository, using a bounded pool of workers
+// A failure on one repository is recorded in its result and does not stop
+// the others
+func RunBulk(req WebhookOptions, opts BulkOptions) ([]BulkResult, error) {
+	_, found := common.FindInSlice(AllowedBulkOperations, opts.Operation)
+	if !found {
+		return []BulkResult{}, fmt.Errorf("unsupported operation %s, must be one of %s", opts.Operation, AllowedBulkOperations)
+	}
+	if req.Url == "" {
+		return []BulkResult{}, fmt.Errorf("a url is required for bulk operations")
+	}
+
+	client, err := newWebhookClient(req)
+	if err != nil {
+		return []BulkResult{}, err
+	}
+	repositories, err := selectRepositories(req, client, opts.RepositorySelector)
+	if err != nil {
+		return []BulkResult{}, err
+	}
+	log.Infof("running %s on %d repositories of %s", opts.Operation, len(repositories), req.Owner)
+
+	return runBulk(req, client, repositories, opts), nil
+}
+
+// runBulk fans the repositories out to opts.Concurrency workers and returns
+// the results in repository order
+func runBulk(req WebhookOptions, client webhookClient, repositories []string, opts BulkOptions) []BulkResult {
+	workers := opts.Concurrency
+	if workers < 1 {
+		workers = 1
+	}
+	if workers > len(repositories) {
+		workers = len(repositories)
+	}
+
+	results := make([]BulkResult, len(repositories))
+	jobs := make(chan int)
+	var wg stdsync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for index := range jobs {
+				repository := repositories[index]
+				result := bulkRepository(req, client.Repository(repository), opts.Operation)
+				result.Repository = repository
+				if result.Err != nil {
+					log.Errorf("%s %s/%s failed: %s", opts.Operation, req.Owner, repository, result.Err)
+				} else {
+					log.Infof("%s %s/%s: %s %v", opts.Operation, req.Owner, repository, result.Action, result.HookIDs)
+				}
+				results[index] = result
+			}
+		}()
+	}
+	for index := range repositories {
+		jobs <- index
+	}
+	close(jobs)
+	wg.Wait()
+
+	return results
+}
+
+// bulkRepository applies an operation to a single repository
+func bulkRepository(req WebhookOptions, provider webhookProvider, operation string) BulkResult {
+	hooks, err := provider.ListWebhooks()
+	if err != nil {
+		return BulkResult{Action: bulkActionFailed, Err: err}
+	}
+	matching := matchingWebhookIDs(hooks, req.Url)
+	if req.Strict && len(matching) > 1 {
+		return BulkResult{Action: bulkActionFailed, Err: fmt.Errorf("%d hooks deliver to %s: %v", len(matching), req.Url, matching)}
+	}
+
+	switch operation {
+	case BulkCreate:
+		if len(matching) > 0 {
+			return BulkResult{Action: bulkActionUnchanged, HookIDs: matching}
+		}
+		hookID, err := provider.CreateWebhook(req.Url, req.Token)
+		if err != nil {
+			return BulkResult{Action: bulkActionFailed, Err: err}
+		}
+		return BulkResult{Action: bulkActionCreated, HookIDs: []int64{hookID}}
+	case BulkUpdate:
+		if len(matching) == 0 {
+			return BulkResult{Action: bulkActionUnchanged}
+		}
+		err := updateWebhooks(provider, matching, req.Url, req.Token)
+		if err != nil {
+			return BulkResult{Action: bulkActionFailed, Err: err}
+		}
+		return BulkResult{Action: bulkActionUpdated, HookIDs: matching}
+	case BulkDelete:
+		if len(matching) == 0 {
+			return BulkResult{Action: bulkActionUnchanged}
+		}
+		err := deleteWebhooks(provider, matching)
+		if err != nil {
+			return BulkResult{Action: bulkActionFailed, Err: err}
+		}
+		return BulkResult{Action: bulkActionDeleted, HookIDs: matching}
+	case BulkSync:
+		result := BulkResult{Action: bulkActionUpdated, HookIDs: matching}
+		if len(matching) == 0 {
+			hookID, err := provider.CreateWebhook(req.Url, req.Token)
+			if err != nil {
+				return BulkResult{Action: bulkActionFailed, Err: err}
+			}
+			result = BulkResult{Action: bulkActionCreated, HookIDs: []int64{hookID}}
+		} else {
+			err := updateWebhooks(provider, matching, req.Url, req.Token)
+			if err != nil {
+				return BulkResult{Action: bulkActionFailed, Err: err}
+			}
+		}
+		// Remove hooks still delivering to the old url
+		if req.OldUrl != "" && req.OldUrl != req.Url {
+			err := deleteWebhooks(provider, matchingWebhookIDs(hooks, req.OldUrl))
+			if err != nil {
+				return BulkResult{Action: bulkActionFailed, HookIDs: result.HookIDs, Err: err}
+			}
+		}
+		return result
+	default:
+		return BulkResult{Action: bulkActionFailed, Err: fmt.Errorf("unsupported operation %s", operation)}
+	}
+}
+
+// BulkSummary counts the results of a bulk run by action
+func BulkSummary(results []BulkResult) string {
+	counts := make(map[string]int)
+	for _, result := range results {
+		counts[result.Action]++
+	}
+
+	actions := make([]string, 0, len(counts))
+	for action := range counts {
+		actions = append(actions, action)
+	}
+	sort.Strings(actions)
+
+	parts := make([]string, 0, len(actions))
+	for _, action := range actions {
+		parts = append(parts, fmt.Sprintf("%d %s", counts[action], action))
+	}
+	return fmt.Sprintf("%d repositories: %s", len(results), strings.Join(parts, ", "))
+}
+
+// matchingWebhookIDs returns the IDs of the hooks delivering to url
+func matchingWebhookIDs(hooks []webhook, url string) []int64 {
+	hookIDs := make([]int64, 0)
+	for _, hook := range hooks {
+		if hook.URL == url {
+			hookIDs = append(hookIDs, hook.ID)
+		}
+	}
+	return hookIDs
+}
+
+// updateWebhooks points every given hook at url
+func updateWebhooks(provider webhookProvider, hookIDs []int64, url string, token string) error {
+	for _, hookID := range hookIDs {
+		err := provider.UpdateWebhook(hookID, url, token)
+		if err != nil {
+			return fmt.Errorf("error updating webhook %d: %s", hookID, err)
+		}
+	}
+	return nil
+}
+
+// deleteWebhooks deletes every given hook
+func deleteWebhooks(provider webhookProvider, hookIDs []int64) error {
+	for _, hookID := range hookIDs {
+		err := provider.DeleteWebhookByID(hookID)
+		if err != nil {
+			return fmt.Errorf("error deleting webhook %d: %s", hookID, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,113 @@
+package sync
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRunBulk(t *testing.T) {
+	const (
+		newURL = "https://new.example.com/events"
+		oldURL = "https://old.example.com/events"
+	)
+
+	tests := []struct {
+		name      string
+		operation string
+		// duplicate adds a second hook delivering to the new url to a
+		duplicate bool
+		strict    bool
+		want      []string
+		wantHooks map[string][]string
+		// wantIDs are the hook IDs reported for each repository, if set
+		wantIDs [][]int64
+	}{
+		{
+			name:      "If a repository already has the hook, create should skip it",
+			operation: BulkCreate,
+			want:      []string{bulkActionUnchanged, bulkActionCreated, bulkActionFailed, bulkActionCreated},
+			wantHooks: map[string][]string{"a": {newURL}, "b": {oldURL, newURL}, "d": {newURL}},
+		},
+		{
+			name:      "If a repository has the hook, update should update it and skip the others",
+			operation: BulkUpdate,
+			want:      []string{bulkActionUpdated, bulkActionUnchanged, bulkActionFailed, bulkActionUnchanged},
+			wantHooks: map[string][]string{"a": {newURL}, "b": {oldURL}, "d": {}},
+			wantIDs:   [][]int64{{1}, nil, nil, nil},
+		},
+		{
+			name:      "If several hooks deliver to the url, update should update all of them",
+			operation: BulkUpdate,
+			duplicate: true,
+			want:      []string{bulkActionUpdated, bulkActionUnchanged, bulkActionFailed, bulkActionUnchanged},
+			wantHooks: map[string][]string{"a": {newURL, newURL}, "b": {oldURL}, "d": {}},
+			wantIDs:   [][]int64{{1, 3}, nil, nil, nil},
+		},
+		{
+			name:      "If several hooks deliver to the url with strict, update should fail the repository",
+			operation: BulkUpdate,
+			duplicate: true,
+			strict:    true,
+			want:      []string{bulkActionFailed, bulkActionUnchanged, bulkActionFailed, bulkActionUnchanged},
+			wantHooks: map[string][]string{"a": {newURL, newURL}, "b": {oldURL}, "d": {}},
+		},
+		{
+			name:      "If a repository has the hook, delete should delete it and skip the others",
+			operation: BulkDelete,
+			want:      []string{bulkActionDeleted, bulkActionUnchanged, bulkActionFailed, bulkActionUnchanged},
+			wantHooks: map[string][]string{"a": {}, "b": {oldURL}, "d": {}},
+		},
+		{
+			name:      "If a repository has a hook for the old url, sync should replace it",
+			operation: BulkSync,
+			want:      []string{bulkActionUpdated, bulkActionCreated, bulkActionFailed, bulkActionCreated},
+			wantHooks: map[string][]string{"a": {newURL}, "b": {newURL}, "d": {newURL}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &fakeClient{
+				nextID: 100,
+				hooks: map[string][]webhook{
+					"a": {{ID: 1, URL: newURL}},
+					"b": {{ID: 2, URL: oldURL}},
+					"c": {},
+					"d": {},
+				},
+				fail: map[string]bool{"c": true},
+			}
+			if tt.duplicate {
+				client.hooks["a"] = append(client.hooks["a"], webhook{ID: 3, URL: newURL})
+			}
+			req := WebhookOptions{Owner: "org", Url: newURL, OldUrl: oldURL, Strict: tt.strict}
+
+			results := runBulk(req, client, []string{"a", "b", "c", "d"}, BulkOptions{Operation: tt.operation, Concurrency: 3})
+
+			got := make([]string, 0, len(results))
+			for _, result := range results {
+				got = append(got, result.Action)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("runBulk() actions = %v, want %v", got, tt.want)
+			}
+			for i, want := range tt.wantIDs {
+				if !reflect.DeepEqual(results[i].HookIDs, want) && !(len(results[i].HookIDs) == 0 && len(want) == 0) {
+					t.Errorf("runBulk() hook IDs of %d = %v, want %v", i, results[i].HookIDs, want)
+				}
+			}
+			if results[2].Err == nil {
+				t.Errorf("runBulk() expected an error for the failing repository")
+			}
+			for repository, want := range tt.wantHooks {
+				urls := make([]string, 0)
+				for _, hook := range client.hooks[repository] {
+					urls = append(urls, hook.URL)
+				}
+				if !reflect.DeepEqual(urls, want) {
+					t.Errorf("hooks of %s = %v, want %v", repository, urls, want)
+				}
+			}
+		})
+	}
+}
@@ -1,14 +1,17 @@
 package sync
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"time"
 
 	"os"
 
-	"github.com/google/uuid"
 	githubWrapper "github.com/kubefirst/git-helper/internal/github"
 	gitlabWrapper "github.com/kubefirst/git-helper/internal/gitlab"
 	"github.com/kubefirst/git-helper/internal/kubernetes"
+	"github.com/kubefirst/git-helper/internal/state"
 	log "github.com/sirupsen/logrus"
 	"github.com/xanzy/go-gitlab"
 )
@@ -22,34 +25,36 @@ const (
 	ngrokExistingTriggerKey   = "trigger-ngrok-reload"
 )
 
-// Key to match for Atlantis Vault secret
-// Webhook token key
-var atlantisSecretTokenKey string
-
 // DeleteWebhook
 func DeleteWebhook(req WebhookOptions) error {
 	switch req.Provider {
 	case "github":
-		gh := githubWrapper.NewGitHubClient(os.Getenv("GITHUB_TOKEN"))
+		gh, err := githubWrapper.NewGitHubClient(os.Getenv("GITHUB_TOKEN"), req.Transport)
+		if err != nil {
+			return err
+		}
 		request := githubWrapper.RepositoryHookRequest{
 			Org:        req.Owner,
 			Repository: req.Repository,
 			Url:        req.Url,
 			Token:      req.Token,
+			Strict:     req.Strict,
 		}
-		err := gh.DeleteRepositoryWebhook(request)
+		err = gh.DeleteRepositoryWebhook(request)
 		if err != nil {
 			return err
 		}
 	case "gitlab":
-		gitlabClient, err := gitlabWrapper.NewGitLabClient(os.Getenv("GIT_TOKEN"), req.Owner)
+		gitlabClient, err := gitlabWrapper.NewGitLabClient(os.Getenv("GIT_TOKEN"), req.Owner, req.Transport)
 		if err != nil {
 			return err
 		}
+		gitlabClient.Recursive = req.GitLabRecursive
 
 		var enabled bool = true
 		request := &gitlabWrapper.ProjectHookRequest{
 			ProjectName: req.Repository,
+			Strict:      req.Strict,
 			CreateOpts: &gitlab.AddProjectHookOptions{
 				MergeRequestsEvents: &enabled,
 				NoteEvents:          &enabled,
@@ -68,144 +73,303 @@ func DeleteWebhook(req WebhookOptions) error {
 
 // SynchronizeAtlantisWebhook
 func SynchronizeAtlantisWebhook(req WebhookOptions) error {
+	kube, err := newKubernetesClient(req)
+	if err != nil {
+		return err
+	}
+
+	return withSyncLock(context.Background(), req, kube, func() error {
+		return synchronizeAtlantisWebhook(req, kube)
+	})
+}
+
+// syncDependencies holds the collaborators of an Atlantis webhook sync so
+// they can be replaced in tests
+type syncDependencies struct {
+	kube     *kubernetes.Client
+	store    state.Store
+	recorder *syncRecorder
+	provider webhookProvider
+	// restart restarts ngrok within deadline and returns the tunnel url
+	// active before it
+	restart func(deadline time.Time) (string, error)
+	// publicURL resolves the public url, given the tunnel url active before a
+	// restart and the deadline of the restart
+	publicURL func(previous string, deadline time.Time) (string, error)
+	// preflight checks that the public url routes to the consumer
+	preflight func(publicURL string) error
+}
+
+// newSyncDependencies returns the dependencies selected by the request options
+func newSyncDependencies(req WebhookOptions, kube *kubernetes.Client, recorder *syncRecorder) (syncDependencies, error) {
+	store, err := newStateStore(req, kube)
+	if err != nil {
+		return syncDependencies{}, err
+	}
+
+	provider, err := newWebhookProvider(req)
+	if err != nil {
+		return syncDependencies{}, err
+	}
+
+	return syncDependencies{
+		kube:     kube,
+		store:    store,
+		recorder: recorder,
+		provider: provider,
+		restart: func(deadline time.Time) (string, error) {
+			return restartNgrok(req, kube, deadline)
+		},
+		publicURL: func(previous string, deadline time.Time) (string, error) {
+			return publicURL(req, kube, previous, deadline)
+		},
+		preflight: func(publicURL string) error {
+			return checkReachable(publicURL, req.PreflightPath, req.PreflightStatus, req.PreflightTimeout)
+		},
+	}, nil
+}
+
+// synchronizeAtlantisWebhook replaces the Atlantis webhook using an existing
+// Kubernetes client and records the outcome
+func synchronizeAtlantisWebhook(req WebhookOptions, kube *kubernetes.Client) error {
+	recorder := newSyncRecorder(req, kube)
+	deps, err := newSyncDependencies(req, kube, recorder)
+	if err != nil {
+		recorder.result("", err)
+		return err
+	}
+
+	url, err := replaceAtlantisWebhook(req, deps)
+	recorder.result(url, err)
+
+	return err
+}
+
+// replaceAtlantisWebhook deletes the previously registered webhook and creates
+// one for the current public url, which it returns
+func replaceAtlantisWebhook(req WebhookOptions, deps syncDependencies) (string, error) {
+	kube, store, recorder, provider := deps.kube, deps.store, deps.recorder, deps.provider
+
+	if req.Restart && req.URLSource != "" && req.URLSource != URLSourceNgrok {
+		return "", fmt.Errorf("--restart only applies to the %s url source, not %s", URLSourceNgrok, req.URLSource)
+	}
+
+	// The rollout and the new tunnel share a single restart deadline
+	var previousTunnelURL string
+	var restartDeadline time.Time
 	if req.Restart {
-		log.Info("editing ngrok trigger ConfigMap to trigger restart")
-		uuid := uuid.New()
-		// Set the trigger configmap key value to a random uuid to trigger a reload
-		err := kubernetes.UpdateConfigMapV2(req.KubeInClusterConfig, atlantisNamespace, ngrokTriggerConfigMapName, ngrokExistingTriggerKey, uuid.String())
+		var err error
+		restartDeadline = time.Now().Add(req.RestartTimeout)
+		previousTunnelURL, err = deps.restart(restartDeadline)
 		if err != nil {
-			return err
+			return "", err
 		}
 	}
-	switch req.Provider {
-	case "github":
-		gh := githubWrapper.NewGitHubClient(os.Getenv("GIT_TOKEN"))
-		atlantisSecretTokenKey = "ATLANTIS_GH_WEBHOOK_SECRET"
 
-		// Use ConfigMap to get existing tunnel url if one exists
-		configmap, err := kubernetes.ReadConfigMapV2(req.KubeInClusterConfig, atlantisNamespace, ngrokConfigMapName)
+	// Use the state store to find the existing webhook if one was recorded
+	key := state.Key(req.Provider, req.Owner, req.Repository)
+	existing, err := store.Get(key)
+	if err != nil && err != state.ErrNotFound {
+		return "", err
+	}
+
+	// Fall back to the url in the ngrok ConfigMap if nothing was recorded
+	previousURL := existing.URL
+	if err == state.ErrNotFound {
+		tunnelURL, err := readTunnelURL(kube)
 		if err != nil {
-			return err
+			return "", err
 		}
-
-		// Delete webhook if it exists
-		var url string = fmt.Sprintf("%s/events", configmap[ngrokExistingTunnelKey])
-		if configmap[ngrokExistingTunnelKey] != "placeholder" {
-			request := githubWrapper.RepositoryHookRequest{
-				Org:        req.Owner,
-				Repository: req.Repository,
-				Url:        url,
-			}
-			err = gh.DeleteRepositoryWebhook(request)
-			if err != nil {
-				log.Errorf("error deleting existing webhook: %s", err)
-			}
-		} else {
+		switch tunnelURL {
+		case "":
+			log.Info("no previous url recorded, creating initial webhook token")
+		case "placeholder":
 			log.Info("configmap entry is placeholder value, creating initial webhook token")
+		default:
+			previousURL = hookURL(req, tunnelURL)
 		}
+	}
 
-		if !req.Cleanup {
-			// Get new tunnel address
-			newWebhookEndpoint, err := GetNgrokTunnelURL(ngrokAPIAddr)
+	// Resolve and check the new public address before touching the existing
+	// webhook, so a dead tunnel leaves it in place
+	var newWebhookEndpoint string
+	if !req.Cleanup {
+		newWebhookEndpoint, err = deps.publicURL(previousTunnelURL, restartDeadline)
+		if err != nil {
+			return "", err
+		}
+		if req.Preflight {
+			err = deps.preflight(newWebhookEndpoint)
 			if err != nil {
-				return err
+				recorder.warning(EventReasonPreflightFailed, "pre-flight check of %s failed, keeping the existing webhook: %s", newWebhookEndpoint, err)
+				return "", fmt.Errorf("pre-flight check of %s failed, keeping the existing webhook: %s", newWebhookEndpoint, err)
 			}
+		}
+	}
 
-			// Get webhook token from Atlantis secret
-			secret, err := kubernetes.ReadSecretV2(req.KubeInClusterConfig, atlantisNamespace, atlantisSecretName)
-			if err != nil {
-				return err
-			}
+	if req.Cleanup {
+		if previousURL != "" {
+			deletePreviousWebhook(req, provider, recorder, existing.HookID, previousURL, 0)
+		}
+		// Keep the history so the webhook can be restored with a rollback
+		return "", store.Put(key, existing.Rotate(state.State{
+			Provider:   req.Provider,
+			Owner:      req.Owner,
+			Repository: req.Repository,
+			UpdatedAt:  time.Now().UTC(),
+		}, req.HistoryLimit))
+	}
 
-			// Create new repository secret
-			request := githubWrapper.RepositoryHookRequest{
-				Org:        req.Owner,
-				Repository: req.Repository,
-				Url:        fmt.Sprintf("%s/events", newWebhookEndpoint),
-				Token:      secret[atlantisSecretTokenKey],
-			}
-			err = gh.CreateRepositoryWebhook(request)
-			if err != nil {
-				return err
-			}
+	// Get webhook token from Atlantis secret
+	secret, err := kube.ReadSecret(atlantisNamespace, atlantisSecretName)
+	if err != nil {
+		return "", err
+	}
+	token := secret[atlantisSecretTokenKeys[req.Provider]]
 
-			err = kubernetes.UpdateConfigMapV2(req.KubeInClusterConfig, atlantisNamespace, ngrokConfigMapName, ngrokExistingTunnelKey, newWebhookEndpoint)
-			if err != nil {
-				log.Error(err)
-				return err
-			}
-		}
-	case "gitlab":
-		gitlabClient, err := gitlabWrapper.NewGitLabClient(os.Getenv("GIT_TOKEN"), req.Owner)
-		if err != nil {
-			return err
-		}
-		atlantisSecretTokenKey = "ATLANTIS_GITLAB_WEBHOOK_SECRET"
+	// Create the new repository webhook before removing the old one, so a
+	// failed create leaves the repository with a working hook
+	// GitHub allows a single hook per url, so a hook already delivering to
+	// the url is updated with the current secret instead
+	webhookURL := hookURL(req, newWebhookEndpoint)
+	hookID, created, err := upsertWebhook(provider, existing.HookID, webhookURL, token)
+	if err != nil {
+		return "", err
+	}
+	if created {
+		recorder.event(EventReasonHookCreated, "created webhook %d for %s on %s/%s", hookID, webhookURL, req.Owner, req.Repository)
+	} else {
+		recorder.event(EventReasonHookUpdated, "updated webhook %d for %s on %s/%s", hookID, webhookURL, req.Owner, req.Repository)
+	}
 
-		// Use ConfigMap to get existing tunnel url if one exists
-		configmap, err := kubernetes.ReadConfigMapV2(req.KubeInClusterConfig, atlantisNamespace, ngrokConfigMapName)
-		if err != nil {
-			return err
-		}
+	err = store.Put(key, existing.Rotate(state.State{
+		Provider:   req.Provider,
+		Owner:      req.Owner,
+		Repository: req.Repository,
+		HookID:     hookID,
+		URL:        webhookURL,
+		SecretHash: state.HashSecret(token),
+		UpdatedAt:  time.Now().UTC(),
+	}, req.HistoryLimit))
+	if err != nil {
+		log.Error(err)
+		return "", err
+	}
 
-		// Delete existing webhook if it exists
-		var url string = fmt.Sprintf("%s/events", configmap[ngrokExistingTunnelKey])
-		if configmap[ngrokExistingTunnelKey] != "placeholder" {
-			request := &gitlabWrapper.ProjectHookRequest{
-				ProjectName: req.Repository,
-				CreateOpts: &gitlab.AddProjectHookOptions{
-					URL: &url,
-				},
-			}
-			err = gitlabClient.DeleteProjectWebhook(request)
-			if err != nil {
-				log.Errorf("error deleting existing webhook: %s", err)
-			}
-		} else {
-			log.Info("configmap entry is placeholder value, creating initial webhook token")
+	if previousURL != "" && previousURL != webhookURL {
+		previousID := existing.HookID
+		if previousID == hookID {
+			previousID = 0
 		}
+		deletePreviousWebhook(req, provider, recorder, previousID, previousURL, hookID)
+	}
 
-		if !req.Cleanup {
-			// Get new tunnel address
-			newWebhookEndpoint, err := GetNgrokTunnelURL(ngrokAPIAddr)
-			if err != nil {
-				return err
-			}
+	// Keep the ngrok ConfigMap entry current for consumers that read it,
+	// creating the ConfigMap on clusters without ngrok
+	err = kube.UpdateConfigMap(atlantisNamespace, ngrokConfigMapName, ngrokExistingTunnelKey, newWebhookEndpoint)
+	if err != nil {
+		log.Error(err)
+		return "", err
+	}
+	if webhookURL != previousURL {
+		recorder.event(EventReasonURLChanged, "public url changed from %s to %s", previousURL, webhookURL)
+	}
 
-			// Get webhook token from Atlantis secret
-			secret, err := kubernetes.ReadSecretV2(req.KubeInClusterConfig, atlantisNamespace, atlantisSecretName)
-			if err != nil {
-				return err
-			}
+	// The hook is recorded first so a failed verification does not leak it
+	if req.Verify {
+		err = verifyWebhook(provider, hookID, req.VerifyTimeout)
+		switch {
+		case errors.Is(err, errVerifyInconclusive):
+			log.Warn(err)
+			recorder.warning(EventReasonHookVerifyInconclusive, "%s", err)
+		case err != nil:
+			recorder.warning(EventReasonHookVerifyFailed, "%s", err)
+			return "", err
+		default:
+			recorder.event(EventReasonHookVerified, "webhook %d for %s on %s/%s accepted a test event", hookID, webhookURL, req.Owner, req.Repository)
+		}
+	}
 
-			// Create new repository secret
-			var enabled bool = true
-			var webhookURL string = fmt.Sprintf("%s/events", newWebhookEndpoint)
-			var token string = secret[atlantisSecretTokenKey]
-
-			request := &gitlabWrapper.ProjectHookRequest{
-				ProjectName: req.Repository,
-				CreateOpts: &gitlab.AddProjectHookOptions{
-					MergeRequestsEvents: &enabled,
-					NoteEvents:          &enabled,
-					PushEvents:          &enabled,
-					Token:               &token,
-					URL:                 &webhookURL,
-				},
-			}
-			err = gitlabClient.CreateProjectWebhook(request)
-			if err != nil {
-				return err
-			}
+	return newWebhookEndpoint, nil
+}
 
-			err = kubernetes.UpdateConfigMapV2(req.KubeInClusterConfig, atlantisNamespace, ngrokConfigMapName, ngrokExistingTunnelKey, newWebhookEndpoint)
-			if err != nil {
-				log.Error(err)
-				return err
-			}
+// upsertWebhook updates the hook delivering to url, preferring hookID, or
+// creates one if there is none, and returns its ID and whether it was created
+func upsertWebhook(provider webhookProvider, hookID int64, url string, token string) (int64, bool, error) {
+	hooks, err := provider.ListWebhooks()
+	if err != nil {
+		return 0, false, err
+	}
+	hookIDs := matchingWebhookIDs(hooks, url)
+	if len(hookIDs) == 0 {
+		hookID, err = provider.CreateWebhook(url, token)
+		return hookID, true, err
+	}
+
+	updateID := hookIDs[0]
+	for _, id := range hookIDs {
+		if id == hookID {
+			updateID = id
 		}
+	}
+	err = provider.UpdateWebhook(updateID, url, token)
+	if err != nil {
+		return 0, false, fmt.Errorf("error updating webhook %d: %s", updateID, err)
+	}
+	return updateID, false, nil
+}
 
+// deletePreviousWebhook removes the webhook replaced by a sync, by ID when
+// known and otherwise every hook delivering to url except keepID
+// Failures are logged and recorded as Events, leaving the hook for gc
+func deletePreviousWebhook(req WebhookOptions, provider webhookProvider, recorder *syncRecorder, hookID int64, url string, keepID int64) {
+	err := deletePreviousHooks(req, provider, hookID, url, keepID)
+	if err != nil {
+		log.Errorf("error deleting existing webhook: %s", err)
+		recorder.warning(EventReasonHookDeleteFailed, "error deleting webhook %s from %s/%s: %s", url, req.Owner, req.Repository, err)
+		return
 	}
+	recorder.event(EventReasonHookDeleted, "deleted webhook %s from %s/%s", url, req.Owner, req.Repository)
+}
 
-	return nil
+// deletePreviousHooks deletes the hooks selected by deletePreviousWebhook
+func deletePreviousHooks(req WebhookOptions, provider webhookProvider, hookID int64, url string, keepID int64) error {
+	if hookID != 0 {
+		return provider.DeleteWebhookByID(hookID)
+	}
+
+	hooks, err := provider.ListWebhooks()
+	if err != nil {
+		return err
+	}
+	hookIDs := make([]int64, 0)
+	for _, id := range matchingWebhookIDs(hooks, url) {
+		if id != keepID {
+			hookIDs = append(hookIDs, id)
+		}
+	}
+	if req.Strict && len(hookIDs) > 1 {
+		return fmt.Errorf("%d hooks deliver to %s: %v", len(hookIDs), url, hookIDs)
+	}
+	return deleteWebhooks(provider, hookIDs)
+}
+
+// newKubernetesClient builds a Kubernetes client from the request options
+// An explicit kubeconfig or context implies a local configuration
+func newKubernetesClient(req WebhookOptions) (*kubernetes.Client, error) {
+	return kubernetes.NewClient(kubernetes.ClientOptions{
+		InCluster:  req.KubeInClusterConfig && req.Kubeconfig == "" && req.KubeContext == "",
+		Kubeconfig: req.Kubeconfig,
+		Context:    req.KubeContext,
+	})
+}
+
+// newStateStore returns the state store selected by the request options
+func newStateStore(req WebhookOptions, kube *kubernetes.Client) (state.Store, error) {
+	return state.NewStore(state.Options{
+		Backend:   req.StateBackend,
+		Namespace: req.StateNamespace,
+		Name:      req.StateName,
+		Path:      req.StatePath,
+	}, kube)
 }
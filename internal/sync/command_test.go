@@ -0,0 +1,345 @@
+package sync
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kubefirst/git-helper/internal/fakeforge"
+	"github.com/kubefirst/git-helper/internal/kubernetes"
+	"github.com/kubefirst/git-helper/internal/state"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// failingForge wraps the fake forge handler, failing hook requests made
+// with the given methods
+func failingForge(handler http.Handler, methods ...string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for _, method := range methods {
+			if r.Method == method && strings.Contains(r.URL.Path, "/hooks") {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusInternalServerError)
+				fmt.Fprint(w, `{"message":"injected failure"}`)
+				return
+			}
+		}
+		handler.ServeHTTP(w, r)
+	})
+}
+
+// newTestKube returns a Kubernetes client backed by the fake clientset with
+// the ngrok ConfigMap set to tunnelURL and the Atlantis secrets in place
+// An empty tunnelURL leaves out the ngrok ConfigMap, as on clusters without
+// ngrok
+func newTestKube(tunnelURL string) *kubernetes.Client {
+	objects := []runtime.Object{
+		&v1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: atlantisSecretName, Namespace: atlantisNamespace},
+			Data:       map[string][]byte{"ATLANTIS_GH_WEBHOOK_SECRET": []byte("secret")},
+		},
+	}
+	if tunnelURL != "" {
+		objects = append(objects, &v1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: ngrokConfigMapName, Namespace: atlantisNamespace},
+			Data:       map[string]string{ngrokExistingTunnelKey: tunnelURL},
+		})
+	}
+	return kubernetes.NewClientFromInterface(fake.NewSimpleClientset(objects...), nil)
+}
+
+func TestReplaceAtlantisWebhook(t *testing.T) {
+	const oldURL, newURL = "https://old.ngrok.io", "https://new.ngrok.io"
+
+	tests := []struct {
+		name string
+		// configMapURL is the tunnel url recorded in the ngrok ConfigMap
+		configMapURL string
+		// existing registers a hook for oldURL before the sync, recorded in
+		// the state store if inState is set
+		existing bool
+		inState  bool
+		// existingURL is the public url of the existing hook, oldURL if empty
+		existingURL string
+		cleanup     bool
+		restart     bool
+		// urlSource selects how the public url becomes the hook url
+		urlSource string
+		failing   []string
+		// preflight enables the pre-flight check, failing it if unreachable
+		preflight   bool
+		unreachable bool
+		// wantHooks are the hook urls left on the repository
+		wantHooks     []string
+		wantStateURL  string
+		wantConfigMap string
+		wantErr       bool
+	}{
+		{
+			name:          "If the configmap holds the placeholder, should create the hook",
+			configMapURL:  "placeholder",
+			wantHooks:     []string{newURL + "/events"},
+			wantStateURL:  newURL + "/events",
+			wantConfigMap: newURL,
+		},
+		{
+			name:          "If the url rotated, should replace the hook recorded in state",
+			configMapURL:  oldURL,
+			existing:      true,
+			inState:       true,
+			wantHooks:     []string{newURL + "/events"},
+			wantStateURL:  newURL + "/events",
+			wantConfigMap: newURL,
+		},
+		{
+			name:          "If the url rotated without state, should replace the hook by configmap url",
+			configMapURL:  oldURL,
+			existing:      true,
+			wantHooks:     []string{newURL + "/events"},
+			wantStateURL:  newURL + "/events",
+			wantConfigMap: newURL,
+		},
+		{
+			name:          "If cleaning up, should delete the hook without creating one",
+			configMapURL:  oldURL,
+			existing:      true,
+			inState:       true,
+			cleanup:       true,
+			wantHooks:     []string{},
+			wantStateURL:  "",
+			wantConfigMap: oldURL,
+		},
+		{
+			name:          "If restarting, should wait for a url other than the previous one",
+			configMapURL:  oldURL,
+			existing:      true,
+			inState:       true,
+			restart:       true,
+			wantHooks:     []string{newURL + "/events"},
+			wantStateURL:  newURL + "/events",
+			wantConfigMap: newURL,
+		},
+		{
+			name:          "If deleting the old hook fails, should still create the new hook",
+			configMapURL:  oldURL,
+			existing:      true,
+			inState:       true,
+			failing:       []string{http.MethodDelete},
+			wantHooks:     []string{oldURL + "/events", newURL + "/events"},
+			wantStateURL:  newURL + "/events",
+			wantConfigMap: newURL,
+		},
+		{
+			name:          "If the pre-flight check passes, should replace the hook",
+			configMapURL:  oldURL,
+			existing:      true,
+			inState:       true,
+			preflight:     true,
+			wantHooks:     []string{newURL + "/events"},
+			wantStateURL:  newURL + "/events",
+			wantConfigMap: newURL,
+		},
+		{
+			name:          "If the pre-flight check fails, should keep the old hook",
+			configMapURL:  oldURL,
+			existing:      true,
+			inState:       true,
+			preflight:     true,
+			unreachable:   true,
+			wantHooks:     []string{oldURL + "/events"},
+			wantStateURL:  oldURL + "/events",
+			wantConfigMap: oldURL,
+			wantErr:       true,
+		},
+		{
+			name:          "If restarting with a url source other than ngrok, should fail",
+			configMapURL:  oldURL,
+			existing:      true,
+			inState:       true,
+			restart:       true,
+			urlSource:     URLSourceIngress,
+			wantHooks:     []string{oldURL + "/events"},
+			wantStateURL:  oldURL + "/events",
+			wantConfigMap: oldURL,
+			wantErr:       true,
+		},
+		{
+			name:          "If the url source is url, should register the url as given",
+			configMapURL:  oldURL,
+			existing:      true,
+			inState:       true,
+			urlSource:     URLSourceURL,
+			wantHooks:     []string{newURL},
+			wantStateURL:  newURL,
+			wantConfigMap: newURL,
+		},
+		{
+			name:          "If the url is unchanged without state or hook, should create the hook",
+			configMapURL:  newURL,
+			wantHooks:     []string{newURL + "/events"},
+			wantStateURL:  newURL + "/events",
+			wantConfigMap: newURL,
+		},
+		{
+			name:          "If the url is unchanged, should update the hook recorded in state",
+			configMapURL:  newURL,
+			existing:      true,
+			inState:       true,
+			existingURL:   newURL,
+			wantHooks:     []string{newURL + "/events"},
+			wantStateURL:  newURL + "/events",
+			wantConfigMap: newURL,
+		},
+		{
+			name:          "If a hook already delivers to the new url, should update it",
+			configMapURL:  newURL,
+			existing:      true,
+			existingURL:   newURL,
+			wantHooks:     []string{newURL + "/events"},
+			wantStateURL:  newURL + "/events",
+			wantConfigMap: newURL,
+		},
+		{
+			name:          "If there is no ngrok configmap, should create the hook and the configmap",
+			urlSource:     URLSourceIngress,
+			wantHooks:     []string{newURL + "/events"},
+			wantStateURL:  newURL + "/events",
+			wantConfigMap: newURL,
+		},
+		{
+			name:          "If creating the hook fails, should keep the old hook, state and configmap",
+			configMapURL:  oldURL,
+			existing:      true,
+			inState:       true,
+			failing:       []string{http.MethodPost},
+			wantHooks:     []string{oldURL + "/events"},
+			wantStateURL:  oldURL + "/events",
+			wantConfigMap: oldURL,
+			wantErr:       true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			forge := fakeforge.New()
+			forge.AddGitHubRepository("kubefirst", "gitops")
+			handler := forge.Handler()
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				handler.ServeHTTP(w, r)
+			}))
+			defer server.Close()
+
+			t.Setenv("GIT_TOKEN", "fake")
+			t.Setenv("GITHUB_API_URL", server.URL+fakeforge.GitHubPrefix)
+
+			req := WebhookOptions{
+				Provider:   "github",
+				Owner:      "kubefirst",
+				Repository: "gitops",
+				Cleanup:    tt.cleanup,
+				Restart:    tt.restart,
+				Preflight:  tt.preflight,
+				URLSource:  tt.urlSource,
+			}
+			kube := newTestKube(tt.configMapURL)
+			store := state.NewMemoryStore()
+			key := state.Key(req.Provider, req.Owner, req.Repository)
+
+			provider, err := newWebhookProvider(req)
+			if err != nil {
+				t.Fatalf("newWebhookProvider() error = %v", err)
+			}
+			if tt.existing {
+				existingURL := tt.existingURL
+				if existingURL == "" {
+					existingURL = oldURL
+				}
+				hookID, err := provider.CreateWebhook(existingURL+"/events", "stale")
+				if err != nil {
+					t.Fatalf("CreateWebhook() error = %v", err)
+				}
+				if tt.inState {
+					err = store.Put(key, state.State{Provider: req.Provider, Owner: req.Owner, Repository: req.Repository, HookID: hookID, URL: existingURL + "/events"})
+					if err != nil {
+						t.Fatalf("Put() error = %v", err)
+					}
+				}
+			}
+			// Failures are injected only once the repository is set up
+			handler = failingForge(forge.Handler(), tt.failing...)
+
+			var restarted bool
+			var restartDeadline time.Time
+			deps := syncDependencies{
+				kube:     kube,
+				store:    store,
+				recorder: newSyncRecorder(req, kube),
+				provider: provider,
+				restart: func(deadline time.Time) (string, error) {
+					restarted = true
+					restartDeadline = deadline
+					return oldURL, nil
+				},
+				publicURL: func(previous string, deadline time.Time) (string, error) {
+					if tt.restart && previous != oldURL {
+						return "", fmt.Errorf("previous tunnel url = %s, want %s", previous, oldURL)
+					}
+					if !deadline.Equal(restartDeadline) {
+						return "", fmt.Errorf("tunnel deadline = %s, want the restart deadline %s", deadline, restartDeadline)
+					}
+					return newURL, nil
+				},
+				preflight: func(publicURL string) error {
+					if tt.unreachable {
+						return fmt.Errorf("%s answered 502, expected 200", publicURL)
+					}
+					return nil
+				},
+			}
+
+			_, err = replaceAtlantisWebhook(req, deps)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("replaceAtlantisWebhook() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if wantRestarted := tt.restart && !tt.wantErr; restarted != wantRestarted {
+				t.Errorf("restarted = %v, want %v", restarted, wantRestarted)
+			}
+
+			hooks := forge.GitHubHooks("kubefirst", "gitops")
+			got := make([]string, 0, len(hooks))
+			for _, hook := range hooks {
+				got = append(got, hook.URL)
+			}
+			if strings.Join(got, ",") != strings.Join(tt.wantHooks, ",") {
+				t.Errorf("hooks = %v, want %v", got, tt.wantHooks)
+			}
+
+			current, err := store.Get(key)
+			if err != nil && err != state.ErrNotFound {
+				t.Fatalf("Get() error = %v", err)
+			}
+			if current.URL != tt.wantStateURL {
+				t.Errorf("state url = %s, want %s", current.URL, tt.wantStateURL)
+			}
+			// The synced hook carries the current Atlantis secret
+			for _, hook := range hooks {
+				if !tt.wantErr && hook.URL == current.URL && hook.Secret != "secret" {
+					t.Errorf("secret of hook %d = %s, want secret", hook.ID, hook.Secret)
+				}
+			}
+
+			configmap, err := kube.ReadConfigMap(atlantisNamespace, ngrokConfigMapName)
+			if err != nil {
+				t.Fatalf("ReadConfigMap() error = %v", err)
+			}
+			if configmap[ngrokExistingTunnelKey] != tt.wantConfigMap {
+				t.Errorf("%s = %s, want %s", ngrokExistingTunnelKey, configmap[ngrokExistingTunnelKey], tt.wantConfigMap)
+			}
+		})
+	}
+}
@@ -0,0 +1,112 @@
+package sync
+
+import (
+	"fmt"
+
+	"github.com/kubefirst/git-helper/internal/state"
+	log "github.com/sirupsen/logrus"
+)
+
+// FindDuplicateWebhooks returns the hooks of the selected repositories that
+// deliver to the same url as another hook, leaving out the canonical hook
+// kept for each url
+// The canonical hook is the one recorded in the state store if it is among
+// the duplicates, and otherwise the most recently created one
+func FindDuplicateWebhooks(req WebhookOptions, opts DedupeOptions) ([]DuplicateWebhook, error) {
+	client, err := newWebhookClient(req)
+	if err != nil {
+		return []DuplicateWebhook{}, err
+	}
+
+	repositories, err := selectRepositories(req, client, opts.RepositorySelector)
+	if err != nil {
+		return []DuplicateWebhook{}, err
+	}
+
+	var store state.Store
+	if opts.FromState {
+		kube, err := newKubernetesClient(req)
+		if err != nil {
+			return []DuplicateWebhook{}, err
+		}
+		store, err = newStateStore(req, kube)
+		if err != nil {
+			return []DuplicateWebhook{}, err
+		}
+	}
+
+	duplicates := make([]DuplicateWebhook, 0)
+	for _, repository := range repositories {
+		var keepID int64
+		if store != nil {
+			existing, err := store.Get(state.Key(req.Provider, req.Owner, repository))
+			if err != nil && err != state.ErrNotFound {
+				log.Warnf("could not read the webhook state of %s/%s, keeping the newest hooks: %s", req.Owner, repository, err)
+			}
+			keepID = existing.HookID
+		}
+
+		hooks, err := client.Repository(repository).ListWebhooks()
+		if err != nil {
+			return []DuplicateWebhook{}, fmt.Errorf("error listing webhooks of %s/%s: %s", req.Owner, repository, err)
+		}
+		duplicates = append(duplicates, duplicateWebhooks(repository, hooks, opts.URL, keepID)...)
+	}
+
+	return duplicates, nil
+}
+
+// duplicateWebhooks groups the hooks of a repository by url and returns all
+// but the canonical hook of each group, optionally only for a single url
+func duplicateWebhooks(repository string, hooks []webhook, url string, keepID int64) []DuplicateWebhook {
+	groups := make(map[string][]webhook)
+	order := make([]string, 0)
+	for _, hook := range hooks {
+		if url != "" && hook.URL != url {
+			continue
+		}
+		if _, ok := groups[hook.URL]; !ok {
+			order = append(order, hook.URL)
+		}
+		groups[hook.URL] = append(groups[hook.URL], hook)
+	}
+
+	duplicates := make([]DuplicateWebhook, 0)
+	for _, hookURL := range order {
+		group := groups[hookURL]
+		if len(group) < 2 {
+			continue
+		}
+
+		kept := canonicalWebhook(group, keepID)
+		for _, hook := range group {
+			if hook.ID == kept {
+				continue
+			}
+			duplicates = append(duplicates, DuplicateWebhook{
+				RepositoryWebhook: RepositoryWebhook{
+					Repository: repository,
+					HookID:     hook.ID,
+					URL:        hook.URL,
+				},
+				KeptHookID: kept,
+			})
+		}
+	}
+	return duplicates
+}
+
+// canonicalWebhook returns keepID if it is part of group, otherwise the
+// highest, most recently created, hook ID
+func canonicalWebhook(group []webhook, keepID int64) int64 {
+	var newest int64
+	for _, hook := range group {
+		if keepID != 0 && hook.ID == keepID {
+			return keepID
+		}
+		if hook.ID > newest {
+			newest = hook.ID
+		}
+	}
+	return newest
+}
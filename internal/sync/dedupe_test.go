@@ -0,0 +1,56 @@
+package sync
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDuplicateWebhooks(t *testing.T) {
+	hooks := []webhook{
+		{ID: 10, URL: "https://a.ngrok.io/events"},
+		{ID: 12, URL: "https://a.ngrok.io/events"},
+		{ID: 11, URL: "https://a.ngrok.io/events"},
+		{ID: 20, URL: "https://b.ngrok.io/events"},
+		{ID: 30, URL: "https://c.ngrok.io/events"},
+		{ID: 31, URL: "https://c.ngrok.io/events"},
+	}
+
+	tests := []struct {
+		name   string
+		url    string
+		keepID int64
+		want   map[int64]int64
+	}{
+		{
+			name: "If no hook is recorded in state, should keep the newest hook of each url",
+			want: map[int64]int64{10: 12, 11: 12, 30: 31},
+		},
+		{
+			name:   "If a hook is recorded in state, should keep it",
+			keepID: 10,
+			want:   map[int64]int64{12: 10, 11: 10, 30: 31},
+		},
+		{
+			name:   "If the state hook delivers to another url, should keep the newest hook",
+			keepID: 20,
+			want:   map[int64]int64{10: 12, 11: 12, 30: 31},
+		},
+		{
+			name: "If a url is given, should only dedupe hooks of that url",
+			url:  "https://c.ngrok.io/events",
+			want: map[int64]int64{30: 31},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := make(map[int64]int64)
+			for _, hook := range duplicateWebhooks("repo", hooks, tt.url, tt.keepID) {
+				got[hook.HookID] = hook.KeptHookID
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("duplicateWebhooks() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
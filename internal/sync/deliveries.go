@@ -0,0 +1,140 @@
+package sync
+
+import (
+	"fmt"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// ListWebhookDeliveries returns the ID of the selected hook of a repository
+// and its most recent deliveries
+func ListWebhookDeliveries(req WebhookOptions, opts DeliveriesOptions) (int64, []Delivery, error) {
+	provider, err := newWebhookProvider(req)
+	if err != nil {
+		return 0, []Delivery{}, err
+	}
+
+	hookID, err := selectWebhook(provider, opts)
+	if err != nil {
+		return 0, []Delivery{}, err
+	}
+
+	deliveries, err := provider.ListDeliveries(hookID, opts.Limit)
+	if err != nil {
+		return 0, []Delivery{}, fmt.Errorf("error listing deliveries of hook %d on %s/%s: %s", hookID, req.Owner, req.Repository, err)
+	}
+	if opts.FailedOnly {
+		failed := make([]Delivery, 0)
+		for _, delivery := range deliveries {
+			if delivery.Failed() {
+				failed = append(failed, delivery)
+			}
+		}
+		deliveries = failed
+	}
+
+	return hookID, deliveries, nil
+}
+
+// FindRedeliveries returns the ID of the selected hook of a repository and
+// its failed deliveries that should be sent again
+func FindRedeliveries(req WebhookOptions, opts RedeliverOptions) (int64, []Delivery, error) {
+	opts.FailedOnly = false
+	hookID, deliveries, err := ListWebhookDeliveries(req, opts.DeliveriesOptions)
+	if err != nil {
+		return 0, []Delivery{}, err
+	}
+
+	var since time.Time
+	if opts.Since > 0 {
+		since = time.Now().Add(-opts.Since)
+	}
+
+	return hookID, failedDeliveries(deliveries, since), nil
+}
+
+// RedeliverWebhookDeliveries sends the given deliveries of a hook again,
+// continuing past failures
+func RedeliverWebhookDeliveries(req WebhookOptions, hookID int64, deliveries []Delivery) error {
+	provider, err := newWebhookProvider(req)
+	if err != nil {
+		return err
+	}
+
+	return redeliver(req, provider, hookID, deliveries)
+}
+
+// redeliver sends deliveries of a hook again through provider
+func redeliver(req WebhookOptions, provider webhookProvider, hookID int64, deliveries []Delivery) error {
+	failed := 0
+	for _, delivery := range deliveries {
+		err := provider.Redeliver(hookID, delivery.ID)
+		if err != nil {
+			log.Errorf("error redelivering %s delivery %d of hook %d on %s/%s: %s", delivery.Event, delivery.ID, hookID, req.Owner, req.Repository, err)
+			failed++
+			continue
+		}
+		log.Infof("redelivered %s delivery %d of hook %d on %s/%s", delivery.Event, delivery.ID, hookID, req.Owner, req.Repository)
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d deliveries could not be redelivered", failed, len(deliveries))
+	}
+
+	return nil
+}
+
+// selectWebhook returns the ID of the hook selected by the options, or of the
+// only hook of the repository
+func selectWebhook(provider webhookProvider, opts DeliveriesOptions) (int64, error) {
+	if opts.HookID != 0 {
+		return opts.HookID, nil
+	}
+
+	hooks, err := provider.ListWebhooks()
+	if err != nil {
+		return 0, err
+	}
+
+	candidates := make([]webhook, 0)
+	for _, hook := range hooks {
+		if opts.URL == "" || normalizeHookURL(hook.URL) == normalizeHookURL(opts.URL) {
+			candidates = append(candidates, hook)
+		}
+	}
+
+	switch {
+	case len(candidates) == 1:
+		return candidates[0].ID, nil
+	case len(candidates) == 0 && opts.URL != "":
+		return 0, fmt.Errorf("no hook delivers to %s", opts.URL)
+	case len(candidates) == 0:
+		return 0, fmt.Errorf("repository has no hooks")
+	default:
+		return 0, fmt.Errorf("%d hooks match, select one with --hook-id or --url", len(candidates))
+	}
+}
+
+// failedDeliveries returns the failed deliveries made after since whose
+// event was not delivered successfully later on, keeping only the most
+// recent attempt of each event
+// deliveries must be ordered most recent first
+func failedDeliveries(deliveries []Delivery, since time.Time) []Delivery {
+	seen := make(map[string]bool)
+	failed := make([]Delivery, 0)
+	for _, delivery := range deliveries {
+		if !since.IsZero() && delivery.DeliveredAt.Before(since) {
+			continue
+		}
+		if delivery.GUID != "" {
+			if seen[delivery.GUID] {
+				continue
+			}
+			seen[delivery.GUID] = true
+		}
+		if delivery.Failed() {
+			failed = append(failed, delivery)
+		}
+	}
+	return failed
+}
@@ -0,0 +1,173 @@
+package sync
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/kubefirst/git-helper/internal/fakeforge"
+)
+
+func TestFailedDeliveries(t *testing.T) {
+	now := time.Now()
+	deliveries := []Delivery{
+		{ID: 6, GUID: "c", StatusCode: 502, DeliveredAt: now.Add(-time.Minute)},
+		{ID: 5, GUID: "b", StatusCode: 200, DeliveredAt: now.Add(-2 * time.Minute), Redelivery: true},
+		{ID: 4, GUID: "c", StatusCode: 502, DeliveredAt: now.Add(-3 * time.Minute)},
+		{ID: 3, GUID: "b", StatusCode: 0, DeliveredAt: now.Add(-4 * time.Minute)},
+		{ID: 2, GUID: "a", StatusCode: 204, DeliveredAt: now.Add(-5 * time.Minute)},
+		{ID: 1, GUID: "d", StatusCode: 404, DeliveredAt: now.Add(-48 * time.Hour)},
+	}
+
+	tests := []struct {
+		name  string
+		since time.Time
+		want  []int64
+	}{
+		{
+			name: "If an event was never delivered, should keep its latest failed attempt",
+			want: []int64{6, 1},
+		},
+		{
+			name:  "If since is set, should skip earlier deliveries",
+			since: now.Add(-time.Hour),
+			want:  []int64{6},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := make([]int64, 0)
+			for _, delivery := range failedDeliveries(deliveries, tt.since) {
+				got = append(got, delivery.ID)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("failedDeliveries() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSelectWebhook(t *testing.T) {
+	tests := []struct {
+		name    string
+		hooks   []webhook
+		opts    DeliveriesOptions
+		want    int64
+		wantErr bool
+	}{
+		{
+			name:  "If there is a single hook, should use it",
+			hooks: []webhook{{ID: 1, URL: "https://a.ngrok.io/events"}},
+			want:  1,
+		},
+		{
+			name:    "If several hooks could be meant, should return an error",
+			hooks:   []webhook{{ID: 1, URL: "https://a.ngrok.io/events"}, {ID: 2, URL: "https://b.ngrok.io/events"}},
+			wantErr: true,
+		},
+		{
+			name:  "If a url is given, should select by it with or without the events path",
+			hooks: []webhook{{ID: 1, URL: "https://a.ngrok.io/events"}, {ID: 2, URL: "https://b.ngrok.io/events"}},
+			opts:  DeliveriesOptions{URL: "https://b.ngrok.io"},
+			want:  2,
+		},
+		{
+			name:  "If a hook ID is given, should use it as is",
+			hooks: []webhook{},
+			opts:  DeliveriesOptions{HookID: 7},
+			want:  7,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &fakeClient{hooks: map[string][]webhook{"gitops": tt.hooks}}
+			got, err := selectWebhook(client.Repository("gitops"), tt.opts)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("selectWebhook() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("selectWebhook() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGitLabDeliveryGUID(t *testing.T) {
+	tests := []struct {
+		name    string
+		headers map[string]string
+		want    string
+	}{
+		{
+			name:    "If the idempotency key is set, should use it",
+			headers: map[string]string{"X-Gitlab-Idempotency-Key": "key", "X-Gitlab-Event-UUID": "uuid"},
+			want:    "key",
+		},
+		{
+			name:    "If only the event uuid is set, should use it regardless of case",
+			headers: map[string]string{"x-gitlab-event-uuid": "uuid"},
+			want:    "uuid",
+		},
+		{
+			name: "If no headers were recorded, should return an empty GUID",
+			want: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := gitlabEventGUID(tt.headers); got != tt.want {
+				t.Errorf("gitlabEventGUID() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+
+	t.Run("If a GitLab event was resent and failed again, should redeliver it once", func(t *testing.T) {
+		target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusBadGateway)
+		}))
+		defer target.Close()
+		forge := fakeforge.New()
+		forge.AddGitLabProject("kubefirst", "gitops")
+		server := httptest.NewServer(forge.Handler())
+		defer server.Close()
+		t.Setenv("GIT_TOKEN", "fake")
+		t.Setenv("GITLAB_API_URL", server.URL+fakeforge.GitLabPrefix)
+
+		provider, err := newWebhookProvider(WebhookOptions{Provider: "gitlab", Owner: "kubefirst", Repository: "gitops"})
+		if err != nil {
+			t.Fatalf("newWebhookProvider() error = %v", err)
+		}
+		hookID, err := provider.CreateWebhook(target.URL, "secret")
+		if err != nil {
+			t.Fatalf("CreateWebhook() error = %v", err)
+		}
+		_, err = provider.PingWebhook(hookID, time.Second)
+		if err != nil {
+			t.Fatalf("PingWebhook() error = %v", err)
+		}
+		deliveries, err := provider.ListDeliveries(hookID, 0)
+		if err != nil || len(deliveries) != 1 {
+			t.Fatalf("ListDeliveries() = %v, %v, want one delivery", deliveries, err)
+		}
+		err = provider.Redeliver(hookID, deliveries[0].ID)
+		if err != nil {
+			t.Fatalf("Redeliver() error = %v", err)
+		}
+
+		deliveries, err = provider.ListDeliveries(hookID, 0)
+		if err != nil || len(deliveries) != 2 {
+			t.Fatalf("ListDeliveries() = %v, %v, want two deliveries", deliveries, err)
+		}
+		if deliveries[0].GUID == "" || deliveries[0].GUID != deliveries[1].GUID {
+			t.Errorf("delivery GUIDs = %q, %q, want the same non-empty GUID", deliveries[0].GUID, deliveries[1].GUID)
+		}
+		if failed := failedDeliveries(deliveries, time.Time{}); len(failed) != 1 {
+			t.Errorf("failedDeliveries() = %v, want only the latest attempt", failed)
+		}
+	})
+}
@@ -0,0 +1,104 @@
+package sync
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/kubefirst/git-helper/internal/kubernetes"
+	log "github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+)
+
+const (
+	EventReasonHookCreated            string = "HookCreated"
+	EventReasonHookUpdated            string = "HookUpdated"
+	EventReasonHookDeleted            string = "HookDeleted"
+	EventReasonHookDeleteFailed       string = "HookDeleteFailed"
+	EventReasonHookVerified           string = "HookVerified"
+	EventReasonHookVerifyFailed       string = "HookVerifyFailed"
+	EventReasonHookVerifyInconclusive string = "HookVerifyInconclusive"
+	EventReasonURLChanged             string = "TunnelURLChanged"
+	EventReasonPreflightFailed        string = "PreflightFailed"
+	EventReasonSyncFailed             string = "SyncFailed"
+
+	annotationPrefix string = "git-helper.kubefirst.io/"
+)
+
+// syncRecorder records sync progress as Events and annotations on a
+// Kubernetes object
+// Recording is best effort and never fails the sync itself
+type syncRecorder struct {
+	kube *kubernetes.Client
+	ref  *v1.ObjectReference
+	req  WebhookOptions
+}
+
+// newSyncRecorder returns a recorder for the object selected by the request
+// options, or a recorder that only logs if events are disabled or the object
+// cannot be found
+func newSyncRecorder(req WebhookOptions, kube *kubernetes.Client) *syncRecorder {
+	recorder := &syncRecorder{kube: kube, req: req}
+	if !req.RecordEvents {
+		return recorder
+	}
+
+	ref, err := kube.ObjectReference(req.EventObjectKind, atlantisNamespace, req.EventObjectName)
+	if err != nil {
+		log.Warnf("not recording Events: %s", err)
+		return recorder
+	}
+	recorder.ref = ref
+
+	return recorder
+}
+
+// event records a Normal Event
+func (r *syncRecorder) event(reason string, format string, args ...interface{}) {
+	r.record(v1.EventTypeNormal, reason, fmt.Sprintf(format, args...))
+}
+
+// warning records a Warning Event
+func (r *syncRecorder) warning(reason string, format string, args ...interface{}) {
+	r.record(v1.EventTypeWarning, reason, fmt.Sprintf(format, args...))
+}
+
+// record creates an Event if a target object is available
+func (r *syncRecorder) record(eventType string, reason string, message string) {
+	if r.ref == nil {
+		return
+	}
+	err := r.kube.RecordEvent(r.ref, eventType, reason, message)
+	if err != nil {
+		log.Warnf("error recording Event: %s", err)
+	}
+}
+
+// result annotates the target object with the outcome of the sync
+func (r *syncRecorder) result(url string, syncErr error) {
+	if syncErr != nil {
+		r.warning(EventReasonSyncFailed, "sync of %s/%s failed: %s", r.req.Owner, r.req.Repository, syncErr)
+	}
+	if r.ref == nil {
+		return
+	}
+
+	annotations := map[string]string{
+		annotationPrefix + "last-sync-time":       time.Now().UTC().Format(time.RFC3339),
+		annotationPrefix + "last-sync-provider":   r.req.Provider,
+		annotationPrefix + "last-sync-repository": fmt.Sprintf("%s/%s", r.req.Owner, r.req.Repository),
+		annotationPrefix + "last-sync-result":     "Succeeded",
+		annotationPrefix + "last-sync-message":    "",
+	}
+	if url != "" {
+		annotations[annotationPrefix+"last-sync-url"] = url
+	}
+	if syncErr != nil {
+		annotations[annotationPrefix+"last-sync-result"] = "Failed"
+		annotations[annotationPrefix+"last-sync-message"] = syncErr.Error()
+	}
+
+	err := r.kube.AnnotateObject(r.ref, annotations)
+	if err != nil {
+		log.Warnf("error recording sync result: %s", err)
+	}
+}
@@ -0,0 +1,122 @@
+package sync
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/kubefirst/git-helper/internal/kubernetes"
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestSyncRecorder(t *testing.T) {
+	tests := []struct {
+		name         string
+		recordEvents bool
+		kind         string
+		objectName   string
+		syncErr      error
+		wantReasons  []string
+		wantResult   string
+	}{
+		{
+			name:         "If events are disabled, should record nothing",
+			recordEvents: false,
+			kind:         "ConfigMap",
+			objectName:   "ngrok",
+		},
+		{
+			name:         "If the sync succeeded, should record the Event and annotate the ConfigMap",
+			recordEvents: true,
+			kind:         "ConfigMap",
+			objectName:   "ngrok",
+			wantReasons:  []string{EventReasonHookCreated},
+			wantResult:   "Succeeded",
+		},
+		{
+			name:         "If the sync failed, should record a SyncFailed Warning and annotate the failure",
+			recordEvents: true,
+			kind:         "Deployment",
+			objectName:   "atlantis",
+			syncErr:      errors.New("hook api unavailable"),
+			wantReasons:  []string{EventReasonHookCreated, EventReasonSyncFailed},
+			wantResult:   "Failed",
+		},
+		{
+			name:         "If the object does not exist, should record nothing",
+			recordEvents: true,
+			kind:         "ConfigMap",
+			objectName:   "missing",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clientset := fake.NewSimpleClientset(
+				&v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "ngrok", Namespace: atlantisNamespace}},
+				&appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "atlantis", Namespace: atlantisNamespace}},
+			)
+			kube := kubernetes.NewClientFromInterface(clientset, nil)
+			req := WebhookOptions{
+				Owner:           "kubefirst",
+				Repository:      "gitops",
+				Provider:        "github",
+				RecordEvents:    tt.recordEvents,
+				EventObjectKind: tt.kind,
+				EventObjectName: tt.objectName,
+			}
+
+			recorder := newSyncRecorder(req, kube)
+			recorder.event(EventReasonHookCreated, "created hook %d", 1)
+			recorder.result("https://example.ngrok.io/events", tt.syncErr)
+
+			events, err := clientset.CoreV1().Events(atlantisNamespace).List(context.Background(), metav1.ListOptions{})
+			if err != nil {
+				t.Fatalf("List() error = %v", err)
+			}
+			reasons := make(map[string]string)
+			for _, event := range events.Items {
+				reasons[event.Reason] = event.Type
+				if event.InvolvedObject.Kind != tt.kind || event.InvolvedObject.Name != tt.objectName {
+					t.Errorf("event on %s %s, want %s %s", event.InvolvedObject.Kind, event.InvolvedObject.Name, tt.kind, tt.objectName)
+				}
+			}
+			if len(reasons) != len(tt.wantReasons) {
+				t.Errorf("event reasons = %v, want %v", reasons, tt.wantReasons)
+			}
+			for _, reason := range tt.wantReasons {
+				if _, ok := reasons[reason]; !ok {
+					t.Errorf("missing %s event, got %v", reason, reasons)
+				}
+			}
+			if eventType, ok := reasons[EventReasonSyncFailed]; ok && eventType != v1.EventTypeWarning {
+				t.Errorf("SyncFailed event type = %s, want %s", eventType, v1.EventTypeWarning)
+			}
+
+			var annotations map[string]string
+			switch tt.kind {
+			case "ConfigMap":
+				configMap, err := clientset.CoreV1().ConfigMaps(atlantisNamespace).Get(context.Background(), "ngrok", metav1.GetOptions{})
+				if err != nil {
+					t.Fatalf("Get() error = %v", err)
+				}
+				annotations = configMap.Annotations
+			case "Deployment":
+				deployment, err := clientset.AppsV1().Deployments(atlantisNamespace).Get(context.Background(), "atlantis", metav1.GetOptions{})
+				if err != nil {
+					t.Fatalf("Get() error = %v", err)
+				}
+				annotations = deployment.Annotations
+			}
+			if got := annotations[annotationPrefix+"last-sync-result"]; got != tt.wantResult {
+				t.Errorf("last-sync-result = %q, want %q", got, tt.wantResult)
+			}
+			if tt.syncErr != nil && annotations[annotationPrefix+"last-sync-message"] != tt.syncErr.Error() {
+				t.Errorf("last-sync-message = %q, want %q", annotations[annotationPrefix+"last-sync-message"], tt.syncErr.Error())
+			}
+		})
+	}
+}
@@ -0,0 +1,112 @@
+package sync
+
+import (
+	"fmt"
+	"sort"
+	stdsync "sync"
+	"time"
+)
+
+// fakeClient is an in-memory webhookClient
+type fakeClient struct {
+	mu     stdsync.Mutex
+	nextID int64
+	hooks  map[string][]webhook
+	fail   map[string]bool
+	// deliveries are the deliveries of each hook, most recent first
+	deliveries  map[int64][]Delivery
+	redelivered []int64
+	// filters records the filter of every Repositories call
+	filters []repositoryFilter
+	// ping is the result of PingWebhook
+	ping    Delivery
+	pingErr error
+}
+
+func (c *fakeClient) Repositories(filter repositoryFilter) ([]string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.filters = append(c.filters, filter)
+	names := make([]string, 0, len(c.hooks))
+	for name := range c.hooks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+func (c *fakeClient) Repository(name string) webhookProvider {
+	return &fakeProvider{client: c, repository: name}
+}
+
+// fakeProvider is the webhookProvider of a fakeClient repository
+type fakeProvider struct {
+	client     *fakeClient
+	repository string
+}
+
+func (p *fakeProvider) ListWebhooks() ([]webhook, error) {
+	p.client.mu.Lock()
+	defer p.client.mu.Unlock()
+	if p.client.fail[p.repository] {
+		return []webhook{}, fmt.Errorf("repository %s unavailable", p.repository)
+	}
+	return append([]webhook{}, p.client.hooks[p.repository]...), nil
+}
+
+func (p *fakeProvider) CreateWebhook(url string, token string) (int64, error) {
+	p.client.mu.Lock()
+	defer p.client.mu.Unlock()
+	p.client.nextID++
+	p.client.hooks[p.repository] = append(p.client.hooks[p.repository], webhook{ID: p.client.nextID, URL: url})
+	return p.client.nextID, nil
+}
+
+func (p *fakeProvider) UpdateWebhook(hookID int64, url string, token string) error {
+	p.client.mu.Lock()
+	defer p.client.mu.Unlock()
+	for i, hook := range p.client.hooks[p.repository] {
+		if hook.ID == hookID {
+			p.client.hooks[p.repository][i].URL = url
+		}
+	}
+	return nil
+}
+
+func (p *fakeProvider) DeleteWebhookByID(hookID int64) error {
+	p.client.mu.Lock()
+	defer p.client.mu.Unlock()
+	kept := make([]webhook, 0)
+	for _, hook := range p.client.hooks[p.repository] {
+		if hook.ID != hookID {
+			kept = append(kept, hook)
+		}
+	}
+	p.client.hooks[p.repository] = kept
+	return nil
+}
+
+func (p *fakeProvider) DeleteWebhookByURL(url string) error {
+	return fmt.Errorf("not implemented")
+}
+
+func (p *fakeProvider) ListDeliveries(hookID int64, limit int) ([]Delivery, error) {
+	p.client.mu.Lock()
+	defer p.client.mu.Unlock()
+	deliveries := p.client.deliveries[hookID]
+	if limit > 0 && len(deliveries) > limit {
+		deliveries = deliveries[:limit]
+	}
+	return append([]Delivery{}, deliveries...), nil
+}
+
+func (p *fakeProvider) PingWebhook(hookID int64, timeout time.Duration) (Delivery, error) {
+	return p.client.ping, p.client.pingErr
+}
+
+func (p *fakeProvider) Redeliver(hookID int64, deliveryID int64) error {
+	p.client.mu.Lock()
+	defer p.client.mu.Unlock()
+	p.client.redelivered = append(p.client.redelivered, deliveryID)
+	return nil
+}
@@ -0,0 +1,331 @@
+package sync
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/kubefirst/git-helper/internal/fakeforge"
+	"github.com/kubefirst/git-helper/internal/kubernetes"
+	"github.com/kubefirst/git-helper/internal/state"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// TestSynchronizeAtlantisWebhookFakeForge runs the ngrok Atlantis sync end to
+// end against the fake forge and a fake Kubernetes clientset
+func TestSynchronizeAtlantisWebhookFakeForge(t *testing.T) {
+	tests := []struct {
+		name     string
+		provider string
+		owner    string
+		repo     string
+		hooks    func(forge *fakeforge.Server) []fakeforge.Hook
+	}{
+		{
+			name:     "github",
+			provider: "github",
+			owner:    "kubefirst",
+			repo:     "gitops",
+			hooks: func(forge *fakeforge.Server) []fakeforge.Hook {
+				return forge.GitHubHooks("kubefirst", "gitops")
+			},
+		},
+		{
+			name:     "gitlab",
+			provider: "gitlab",
+			owner:    "kubefirst",
+			repo:     "gitops",
+			hooks: func(forge *fakeforge.Server) []fakeforge.Hook {
+				return forge.GitLabHooks("kubefirst/gitops")
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			forge := fakeforge.New()
+			forge.AddGitHubRepository("kubefirst", "gitops")
+			forge.AddGitLabProject("kubefirst", "gitops")
+			forge.SetTunnels(fakeforge.Tunnel{Name: "atlantis", PublicURL: "https://first.ngrok.io", Proto: "https", Addr: "http://atlantis:4141"})
+			server := httptest.NewServer(forge.Handler())
+			defer server.Close()
+
+			t.Setenv("GIT_TOKEN", "fake")
+			t.Setenv("GITHUB_API_URL", server.URL+fakeforge.GitHubPrefix)
+			t.Setenv("GITLAB_API_URL", server.URL+fakeforge.GitLabPrefix)
+
+			kube := kubernetes.NewClientFromInterface(fake.NewSimpleClientset(
+				&v1.ConfigMap{
+					ObjectMeta: metav1.ObjectMeta{Name: ngrokConfigMapName, Namespace: atlantisNamespace},
+					Data:       map[string]string{ngrokExistingTunnelKey: "placeholder"},
+				},
+				&v1.Secret{
+					ObjectMeta: metav1.ObjectMeta{Name: atlantisSecretName, Namespace: atlantisNamespace},
+					Data: map[string][]byte{
+						"ATLANTIS_GH_WEBHOOK_SECRET":     []byte("secret"),
+						"ATLANTIS_GITLAB_WEBHOOK_SECRET": []byte("secret"),
+					},
+				},
+			), nil)
+
+			req := WebhookOptions{
+				Provider:       tt.provider,
+				Owner:          tt.owner,
+				Repository:     tt.repo,
+				NgrokAPIURL:    server.URL + fakeforge.NgrokPath,
+				StateBackend:   state.BackendConfigMap,
+				StateNamespace: atlantisNamespace,
+				StateName:      ngrokConfigMapName,
+			}
+
+			// The first sync creates the hook, the second replaces it once the
+			// tunnel url changes
+			for _, publicURL := range []string{"https://first.ngrok.io", "https://second.ngrok.io"} {
+				forge.SetTunnels(fakeforge.Tunnel{Name: "atlantis", PublicURL: publicURL, Proto: "https", Addr: "http://atlantis:4141"})
+				err := synchronizeAtlantisWebhook(req, kube)
+				if err != nil {
+					t.Fatalf("synchronizeAtlantisWebhook() error = %v", err)
+				}
+
+				hooks := tt.hooks(forge)
+				if len(hooks) != 1 || hooks[0].URL != publicURL+"/events" || hooks[0].Secret != "secret" {
+					t.Fatalf("hooks after sync to %s = %+v", publicURL, hooks)
+				}
+			}
+
+			configmap, err := kube.ReadConfigMap(atlantisNamespace, ngrokConfigMapName)
+			if err != nil {
+				t.Fatalf("ReadConfigMap() error = %v", err)
+			}
+			if configmap[ngrokExistingTunnelKey] != "https://second.ngrok.io" {
+				t.Errorf("%s = %s, want https://second.ngrok.io", ngrokExistingTunnelKey, configmap[ngrokExistingTunnelKey])
+			}
+		})
+	}
+}
+
+// TestFakeForgeDelivery checks that pings are delivered and recorded
+// TestSynchronizeRelayWebhookFakeForge runs the sync the relay makes with
+// --sync-webhook on a cluster without ngrok, pointing the webhook at a
+// channel url
+func TestSynchronizeRelayWebhookFakeForge(t *testing.T) {
+	const channelURL = "https://smee.io/atlantis"
+
+	forge := fakeforge.New()
+	forge.AddGitHubRepository("kubefirst", "gitops")
+	server := httptest.NewServer(forge.Handler())
+	defer server.Close()
+
+	t.Setenv("GIT_TOKEN", "fake")
+	t.Setenv("GITHUB_API_URL", server.URL+fakeforge.GitHubPrefix)
+
+	// Only the Atlantis secret exists, there is no ngrok ConfigMap
+	kube := newTestKube("")
+	req := WebhookOptions{
+		Provider:       "github",
+		Owner:          "kubefirst",
+		Repository:     "gitops",
+		URLSource:      URLSourceURL,
+		Url:            channelURL,
+		StateBackend:   state.BackendConfigMap,
+		StateNamespace: atlantisNamespace,
+		StateName:      ngrokConfigMapName,
+	}
+
+	// Restarting the relay syncs the same url again
+	for i := 0; i < 2; i++ {
+		err := synchronizeAtlantisWebhook(req, kube)
+		if err != nil {
+			t.Fatalf("synchronizeAtlantisWebhook() run %d error = %v", i+1, err)
+		}
+		hooks := forge.GitHubHooks("kubefirst", "gitops")
+		if len(hooks) != 1 || hooks[0].URL != channelURL || hooks[0].Secret != "secret" {
+			t.Fatalf("hooks after run %d = %+v", i+1, hooks)
+		}
+	}
+}
+
+func TestFakeForgeDelivery(t *testing.T) {
+	received := make(chan *http.Request, 1)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received <- r
+	}))
+	defer receiver.Close()
+
+	forge := fakeforge.New()
+	forge.AddGitHubRepository("kubefirst", "gitops")
+	server := httptest.NewServer(forge.Handler())
+	defer server.Close()
+	t.Setenv("GIT_TOKEN", "fake")
+	t.Setenv("GITHUB_API_URL", server.URL+fakeforge.GitHubPrefix)
+
+	provider, err := newWebhookProvider(WebhookOptions{Provider: "github", Owner: "kubefirst", Repository: "gitops"})
+	if err != nil {
+		t.Fatalf("newWebhookProvider() error = %v", err)
+	}
+	hookID, err := provider.CreateWebhook(receiver.URL, "secret")
+	if err != nil {
+		t.Fatalf("CreateWebhook() error = %v", err)
+	}
+
+	resp, err := http.Post(server.URL+fakeforge.GitHubPrefix+"repos/kubefirst/gitops/hooks/"+strconv.FormatInt(hookID, 10)+"/pings", "application/json", nil)
+	if err != nil {
+		t.Fatalf("ping error = %v", err)
+	}
+	resp.Body.Close()
+
+	r := <-received
+	if r.Header.Get("X-GitHub-Event") != "ping" || r.Header.Get("X-Hub-Signature-256") == "" {
+		t.Errorf("delivery headers = %v", r.Header)
+	}
+	hooks := forge.GitHubHooks("kubefirst", "gitops")
+	if len(hooks) != 1 || len(hooks[0].Deliveries) != 1 || hooks[0].Deliveries[0].StatusCode != http.StatusOK {
+		t.Errorf("recorded deliveries = %+v", hooks)
+	}
+}
+
+// TestRedeliverFakeForge checks that failed deliveries are found and sent
+// again once the receiver is fixed
+func TestRedeliverFakeForge(t *testing.T) {
+	tests := []struct {
+		name     string
+		provider string
+		trigger  func(server string, hookID int64) string
+	}{
+		{
+			name:     "github",
+			provider: "github",
+			trigger: func(server string, hookID int64) string {
+				return server + fakeforge.GitHubPrefix + "repos/kubefirst/gitops/hooks/" + strconv.FormatInt(hookID, 10) + "/pings"
+			},
+		},
+		{
+			name:     "gitlab",
+			provider: "gitlab",
+			trigger: func(server string, hookID int64) string {
+				return server + fakeforge.GitLabPrefix + "api/v4/projects/kubefirst%2Fgitops/hooks/" + strconv.FormatInt(hookID, 10) + "/test/push_events"
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var status atomic.Int32
+			status.Store(http.StatusBadGateway)
+			receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(int(status.Load()))
+			}))
+			defer receiver.Close()
+
+			forge := fakeforge.New()
+			forge.AddGitHubRepository("kubefirst", "gitops")
+			forge.AddGitLabProject("kubefirst", "gitops")
+			server := httptest.NewServer(forge.Handler())
+			defer server.Close()
+			t.Setenv("GIT_TOKEN", "fake")
+			t.Setenv("GITHUB_API_URL", server.URL+fakeforge.GitHubPrefix)
+			t.Setenv("GITLAB_API_URL", server.URL+fakeforge.GitLabPrefix)
+
+			req := WebhookOptions{Provider: tt.provider, Owner: "kubefirst", Repository: "gitops"}
+			provider, err := newWebhookProvider(req)
+			if err != nil {
+				t.Fatalf("newWebhookProvider() error = %v", err)
+			}
+			hookID, err := provider.CreateWebhook(receiver.URL, "secret")
+			if err != nil {
+				t.Fatalf("CreateWebhook() error = %v", err)
+			}
+			resp, err := http.Post(tt.trigger(server.URL, hookID), "application/json", nil)
+			if err != nil {
+				t.Fatalf("trigger error = %v", err)
+			}
+			resp.Body.Close()
+
+			selected, deliveries, err := FindRedeliveries(req, RedeliverOptions{Since: time.Hour})
+			if err != nil {
+				t.Fatalf("FindRedeliveries() error = %v", err)
+			}
+			if selected != hookID || len(deliveries) != 1 || deliveries[0].StatusCode != http.StatusBadGateway {
+				t.Fatalf("FindRedeliveries() = %d, %+v", selected, deliveries)
+			}
+
+			status.Store(http.StatusOK)
+			err = RedeliverWebhookDeliveries(req, hookID, deliveries)
+			if err != nil {
+				t.Fatalf("RedeliverWebhookDeliveries() error = %v", err)
+			}
+
+			_, listed, err := ListWebhookDeliveries(req, DeliveriesOptions{HookID: hookID})
+			if err != nil {
+				t.Fatalf("ListWebhookDeliveries() error = %v", err)
+			}
+			if len(listed) != 2 || listed[0].StatusCode != http.StatusOK {
+				t.Errorf("ListWebhookDeliveries() = %+v", listed)
+			}
+		})
+	}
+}
+
+// TestVerifyWebhookFakeForge checks that verification reports the status the
+// endpoint answered a ping with
+func TestVerifyWebhookFakeForge(t *testing.T) {
+	tests := []struct {
+		name     string
+		provider string
+		status   int
+		closed   bool
+		wantErr  string
+	}{
+		{name: "github accepted", provider: "github", status: http.StatusOK},
+		{name: "github rejected", provider: "github", status: http.StatusBadGateway, wantErr: "answered 502"},
+		{name: "github unreachable", provider: "github", closed: true, wantErr: "answered 0"},
+		{name: "gitlab accepted", provider: "gitlab", status: http.StatusNoContent},
+		{name: "gitlab rejected", provider: "gitlab", status: http.StatusNotFound, wantErr: "answered 404"},
+		{name: "gitlab unreachable", provider: "gitlab", closed: true, wantErr: "answered 0"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+			}))
+			if tt.closed {
+				receiver.Close()
+			} else {
+				defer receiver.Close()
+			}
+
+			forge := fakeforge.New()
+			forge.AddGitHubRepository("kubefirst", "gitops")
+			forge.AddGitLabProject("kubefirst", "gitops")
+			server := httptest.NewServer(forge.Handler())
+			defer server.Close()
+			t.Setenv("GIT_TOKEN", "fake")
+			t.Setenv("GITHUB_API_URL", server.URL+fakeforge.GitHubPrefix)
+			t.Setenv("GITLAB_API_URL", server.URL+fakeforge.GitLabPrefix)
+
+			provider, err := newWebhookProvider(WebhookOptions{Provider: tt.provider, Owner: "kubefirst", Repository: "gitops"})
+			if err != nil {
+				t.Fatalf("newWebhookProvider() error = %v", err)
+			}
+			hookID, err := provider.CreateWebhook(receiver.URL, "secret")
+			if err != nil {
+				t.Fatalf("CreateWebhook() error = %v", err)
+			}
+
+			err = verifyWebhook(provider, hookID, time.Second)
+			if tt.wantErr == "" && err != nil {
+				t.Errorf("verifyWebhook() error = %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Errorf("verifyWebhook() error = %v, want %s", err, tt.wantErr)
+			}
+		})
+	}
+}
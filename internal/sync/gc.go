@@ -0,0 +1,174 @@
+package sync
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/kubefirst/git-helper/internal/kubernetes"
+	log "github.com/sirupsen/logrus"
+)
+
+// defaultOrphanPattern matches hooks delivering to ngrok tunnel domains and
+// is used when neither a host nor a match pattern is given
+const defaultOrphanPattern string = `^https?://[^/]+\.ngrok(-free)?\.(io|app|dev)(:[0-9]+)?(/|$)`
+
+// hookMatcher decides whether a hook url is a garbage collection candidate
+type hookMatcher struct {
+	host  string
+	match *regexp.Regexp
+}
+
+// newHookMatcher compiles the host and url filters of the gc options
+func newHookMatcher(opts GCOptions) (hookMatcher, error) {
+	pattern := opts.Match
+	if pattern == "" && opts.Host == "" {
+		pattern = defaultOrphanPattern
+	}
+
+	matcher := hookMatcher{host: opts.Host}
+	if opts.Host != "" {
+		_, err := path.Match(opts.Host, "")
+		if err != nil {
+			return hookMatcher{}, fmt.Errorf("invalid host pattern %s: %s", opts.Host, err)
+		}
+	}
+	if pattern != "" {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return hookMatcher{}, fmt.Errorf("invalid match pattern %s: %s", pattern, err)
+		}
+		matcher.match = re
+	}
+	return matcher, nil
+}
+
+// matches reports whether hookURL passes every configured filter
+func (m hookMatcher) matches(hookURL string) bool {
+	if m.host != "" {
+		parsed, err := url.Parse(hookURL)
+		if err != nil {
+			return false
+		}
+		ok, _ := path.Match(m.host, parsed.Hostname())
+		if !ok {
+			return false
+		}
+	}
+	if m.match != nil && !m.match.MatchString(hookURL) {
+		return false
+	}
+	return true
+}
+
+// FindOrphanedWebhooks returns the hooks of the selected repositories that
+// match the gc filters but do not deliver to an active url
+func FindOrphanedWebhooks(req WebhookOptions, opts GCOptions) ([]RepositoryWebhook, error) {
+	matcher, err := newHookMatcher(opts)
+	if err != nil {
+		return []RepositoryWebhook{}, err
+	}
+
+	client, err := newWebhookClient(req)
+	if err != nil {
+		return []RepositoryWebhook{}, err
+	}
+
+	repositories, err := selectRepositories(req, client, opts.RepositorySelector)
+	if err != nil {
+		return []RepositoryWebhook{}, err
+	}
+
+	var kube *kubernetes.Client
+	if opts.FromState {
+		kube, err = newKubernetesClient(req)
+		if err != nil {
+			return []RepositoryWebhook{}, err
+		}
+	}
+
+	orphaned := make([]RepositoryWebhook, 0)
+	for _, repository := range repositories {
+		active := append([]string{}, opts.KeepURLs...)
+		if opts.FromState {
+			repoReq := req
+			repoReq.Repository = repository
+			registered, err := registeredURL(repoReq, kube)
+			if err != nil {
+				log.Warnf("could not read the active url of %s/%s, keeping its hooks: %s", req.Owner, repository, err)
+				continue
+			}
+			if registered != "" {
+				active = append(active, registered)
+			}
+		}
+
+		hooks, err := client.Repository(repository).ListWebhooks()
+		if err != nil {
+			return []RepositoryWebhook{}, fmt.Errorf("error listing webhooks of %s/%s: %s", req.Owner, repository, err)
+		}
+		orphaned = append(orphaned, orphanedWebhooks(repository, hooks, matcher, active)...)
+	}
+
+	return orphaned, nil
+}
+
+// DeleteWebhooks deletes the given hooks, continuing past failures
+func DeleteWebhooks(req WebhookOptions, hooks []RepositoryWebhook) error {
+	client, err := newWebhookClient(req)
+	if err != nil {
+		return err
+	}
+
+	failed := 0
+	for _, hook := range hooks {
+		err := client.Repository(hook.Repository).DeleteWebhookByID(hook.HookID)
+		if err != nil {
+			log.Errorf("error deleting webhook %d (%s) from %s/%s: %s", hook.HookID, hook.URL, req.Owner, hook.Repository, err)
+			failed++
+			continue
+		}
+		log.Infof("deleted webhook %d (%s) from %s/%s", hook.HookID, hook.URL, req.Owner, hook.Repository)
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d webhooks could not be deleted", failed, len(hooks))
+	}
+
+	return nil
+}
+
+// orphanedWebhooks filters the hooks of a repository down to those matching
+// the gc filters that do not deliver to one of the active urls
+func orphanedWebhooks(repository string, hooks []webhook, matcher hookMatcher, active []string) []RepositoryWebhook {
+	orphaned := make([]RepositoryWebhook, 0)
+	for _, hook := range hooks {
+		if !matcher.matches(hook.URL) || isActiveURL(hook.URL, active) {
+			continue
+		}
+		orphaned = append(orphaned, RepositoryWebhook{
+			Repository: repository,
+			HookID:     hook.ID,
+			URL:        hook.URL,
+		})
+	}
+	return orphaned
+}
+
+// isActiveURL reports whether a hook delivers to one of the active urls,
+// which may be given with or without the /events path
+func isActiveURL(hookURL string, active []string) bool {
+	normalized := normalizeHookURL(hookURL)
+	for _, activeURL := range active {
+		if normalizeHookURL(activeURL) == normalized {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeHookURL strips the trailing slash and /events path from a url
+func normalizeHookURL(hookURL string) string {
+	return strings.TrimSuffix(strings.TrimSuffix(hookURL, "/"), "/events")
+}
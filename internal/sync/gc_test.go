@@ -0,0 +1,65 @@
+package sync
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestOrphanedWebhooks(t *testing.T) {
+	hooks := []webhook{
+		{ID: 1, URL: "https://abc123.ngrok.io/events"},
+		{ID: 2, URL: "https://def456.ngrok-free.app/events"},
+		{ID: 3, URL: "https://atlantis.example.com/events"},
+		{ID: 4, URL: "https://ghi789.ngrok.io/events"},
+	}
+
+	tests := []struct {
+		name   string
+		opts   GCOptions
+		active []string
+		want   []int64
+	}{
+		{
+			name:   "If no pattern is given, should match ngrok hooks except the active url",
+			active: []string{"https://abc123.ngrok.io"},
+			want:   []int64{2, 4},
+		},
+		{
+			name:   "If the active url has the events path, should keep that hook",
+			active: []string{"https://ghi789.ngrok.io/events"},
+			want:   []int64{1, 2},
+		},
+		{
+			name: "If a host glob is given, should match only hooks on that host",
+			opts: GCOptions{Host: "*.ngrok.io"},
+			want: []int64{1, 4},
+		},
+		{
+			name:   "If a regex is given, should match hooks whose url matches it",
+			opts:   GCOptions{Match: `example\.com`},
+			active: []string{"https://abc123.ngrok.io"},
+			want:   []int64{3},
+		},
+		{
+			name: "If a host glob and a regex are given, should match hooks matching both",
+			opts: GCOptions{Host: "*.ngrok.io", Match: `ghi`},
+			want: []int64{4},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			matcher, err := newHookMatcher(tt.opts)
+			if err != nil {
+				t.Fatalf("newHookMatcher() error = %v", err)
+			}
+			got := make([]int64, 0)
+			for _, hook := range orphanedWebhooks("repo", hooks, matcher, tt.active) {
+				got = append(got, hook.HookID)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("orphanedWebhooks() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,146 @@
+package sync
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/kubefirst/git-helper/internal/kubernetes"
+	"github.com/kubefirst/git-helper/internal/state"
+	log "github.com/sirupsen/logrus"
+)
+
+// WebhookHistory returns the recorded state, including previously registered
+// hooks, for the repository selected by the request options
+func WebhookHistory(req WebhookOptions) (state.State, error) {
+	kube, err := newKubernetesClient(req)
+	if err != nil {
+		return state.State{}, err
+	}
+	store, err := newStateStore(req, kube)
+	if err != nil {
+		return state.State{}, err
+	}
+
+	existing, err := store.Get(state.Key(req.Provider, req.Owner, req.Repository))
+	if err == state.ErrNotFound {
+		return state.State{}, fmt.Errorf("no webhook state recorded for %s/%s", req.Owner, req.Repository)
+	}
+
+	return existing, err
+}
+
+// RollbackAtlantisWebhook re-points the Atlantis webhook at a previously
+// registered url, either the url given in the request options or the
+// history entry at index to (1 being the most recent)
+func RollbackAtlantisWebhook(req WebhookOptions, to int) error {
+	kube, err := newKubernetesClient(req)
+	if err != nil {
+		return err
+	}
+
+	return withSyncLock(context.Background(), req, kube, func() error {
+		recorder := newSyncRecorder(req, kube)
+		url, err := rollbackAtlantisWebhook(req, kube, recorder, to)
+		recorder.result(url, err)
+		return err
+	})
+}
+
+// rollbackAtlantisWebhook creates a hook for the selected history entry, then
+// deletes the current hook, and returns the restored public url
+func rollbackAtlantisWebhook(req WebhookOptions, kube *kubernetes.Client, recorder *syncRecorder, to int) (string, error) {
+	store, err := newStateStore(req, kube)
+	if err != nil {
+		return "", err
+	}
+	key := state.Key(req.Provider, req.Owner, req.Repository)
+	existing, err := store.Get(key)
+	if err == state.ErrNotFound {
+		return "", fmt.Errorf("no webhook state recorded for %s/%s", req.Owner, req.Repository)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	target, err := historyTarget(existing, req.Url, to)
+	if err != nil {
+		return "", err
+	}
+
+	provider, err := newWebhookProvider(req)
+	if err != nil {
+		return "", err
+	}
+
+	// Get webhook token from Atlantis secret
+	secret, err := kube.ReadSecret(atlantisNamespace, atlantisSecretName)
+	if err != nil {
+		return "", err
+	}
+	token := secret[atlantisSecretTokenKeys[req.Provider]]
+
+	// Create the restored hook before removing the current one, updating
+	// the hook already delivering to the url instead if there is one
+	hookID, created, err := upsertWebhook(provider, existing.HookID, target.URL, token)
+	if err != nil {
+		return "", err
+	}
+	if created {
+		recorder.event(EventReasonHookCreated, "restored webhook %d for %s on %s/%s", hookID, target.URL, req.Owner, req.Repository)
+	} else {
+		recorder.event(EventReasonHookUpdated, "restored webhook %d for %s on %s/%s", hookID, target.URL, req.Owner, req.Repository)
+	}
+
+	if existing.HookID != 0 && existing.HookID != hookID {
+		err = provider.DeleteWebhookByID(existing.HookID)
+		if err != nil {
+			log.Errorf("error deleting existing webhook: %s", err)
+			recorder.warning(EventReasonHookDeleteFailed, "error deleting webhook %s from %s/%s: %s", existing.URL, req.Owner, req.Repository, err)
+		} else {
+			recorder.event(EventReasonHookDeleted, "deleted webhook %s from %s/%s", existing.URL, req.Owner, req.Repository)
+		}
+	}
+
+	err = store.Put(key, existing.Rotate(state.State{
+		Provider:   req.Provider,
+		Owner:      req.Owner,
+		Repository: req.Repository,
+		HookID:     hookID,
+		URL:        target.URL,
+		SecretHash: state.HashSecret(token),
+		UpdatedAt:  time.Now().UTC(),
+	}, req.HistoryLimit))
+	if err != nil {
+		return "", err
+	}
+
+	restored := strings.TrimSuffix(target.URL, "/events")
+	err = kube.UpdateConfigMap(atlantisNamespace, ngrokConfigMapName, ngrokExistingTunnelKey, restored)
+	if err != nil {
+		return "", err
+	}
+	recorder.event(EventReasonURLChanged, "public url rolled back from %s to %s", existing.URL, target.URL)
+	log.Infof("rolled back %s/%s to %s", req.Owner, req.Repository, target.URL)
+
+	return restored, nil
+}
+
+// historyTarget selects the history entry to roll back to, by url if given
+// and otherwise by its 1-based index
+func historyTarget(existing state.State, url string, to int) (state.HistoryEntry, error) {
+	if url != "" {
+		for _, entry := range existing.History {
+			if entry.URL == url || strings.TrimSuffix(entry.URL, "/events") == url {
+				return entry, nil
+			}
+		}
+		return state.HistoryEntry{}, fmt.Errorf("url %s not found in the webhook history", url)
+	}
+
+	if to < 1 || to > len(existing.History) {
+		return state.HistoryEntry{}, fmt.Errorf("history entry %d does not exist, %d entries are recorded", to, len(existing.History))
+	}
+	return existing.History[to-1], nil
+}
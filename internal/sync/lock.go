@@ -0,0 +1,34 @@
+package sync
+
+import (
+	"context"
+
+	"github.com/kubefirst/git-helper/internal/kubernetes"
+)
+
+// withSyncLock runs fn while holding the sync Lease if locking is enabled,
+// so that concurrent Jobs or replicas do not race on the same webhooks
+func withSyncLock(ctx context.Context, req WebhookOptions, kube *kubernetes.Client, fn func() error) error {
+	if !req.Lock {
+		return fn()
+	}
+
+	return kube.WithLease(ctx, kubernetes.LeaseOptions{
+		Name:           req.LockName,
+		Namespace:      req.LockNamespace,
+		AcquireTimeout: req.LockTimeout,
+	}, fn)
+}
+
+// runAsLeader runs fn directly, or only while holding the leader Lease if
+// leader election is enabled
+func runAsLeader(ctx context.Context, req WebhookOptions, kube *kubernetes.Client, fn func(ctx context.Context) error) error {
+	if !req.LeaderElect {
+		return fn(ctx)
+	}
+
+	return kube.RunWithLeaderElection(ctx, kubernetes.LeaseOptions{
+		Name:      req.LeaderElectionID,
+		Namespace: req.LockNamespace,
+	}, fn)
+}
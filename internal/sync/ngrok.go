@@ -2,36 +2,215 @@ package sync
 
 import (
 	"encoding/json"
+	"fmt"
 	"io"
 	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/kubefirst/git-helper/internal/kubernetes"
+	log "github.com/sirupsen/logrus"
 )
 
 const (
 	ngrokAPIAddr string = "http://ngrok:4040/api/tunnels"
+
+	// ngrokPollInterval is how often the ngrok api is queried while waiting
+	// for a tunnel to become available
+	ngrokPollInterval time.Duration = 2 * time.Second
+
+	// ngrokRequestTimeout bounds a single request to the ngrok api
+	ngrokRequestTimeout time.Duration = 10 * time.Second
 )
 
-// GetNgrokTunnelURL
-func GetNgrokTunnelURL(url string) (string, error) {
-	resp, err := http.Get(url)
+// GetNgrokTunnelURL returns the public url of the single tunnel reported by the
+// ngrok api at url that matches the selector
+func GetNgrokTunnelURL(url string, selector NgrokTunnelSelector) (string, error) {
+	return getNgrokTunnelURL(url, selector, ngrokRequestTimeout)
+}
+
+// getNgrokTunnelURL is GetNgrokTunnelURL with the request bounded by timeout
+func getNgrokTunnelURL(url string, selector NgrokTunnelSelector, timeout time.Duration) (string, error) {
+	tunnels, err := getNgrokTunnels(url, timeout)
 	if err != nil {
 		return "", err
 	}
 
-	body, err := io.ReadAll(resp.Body)
+	tunnel, err := selectNgrokTunnel(tunnels, selector)
+	if err != nil {
+		return "", err
+	}
+
+	return tunnel.Public_url, nil
+}
+
+// WaitForNgrokTunnelURL polls the ngrok api at url until it reports a tunnel
+// that matches the selector or the timeout is reached
+// If previous is set, a tunnel still using that url is not accepted
+func WaitForNgrokTunnelURL(url string, selector NgrokTunnelSelector, previous string, timeout time.Duration) (string, error) {
+	deadline := time.Now().Add(timeout)
+	for {
+		requestTimeout := time.Until(deadline)
+		if requestTimeout <= 0 {
+			return "", fmt.Errorf("timed out after %s waiting for ngrok tunnel", timeout)
+		}
+		if requestTimeout > ngrokRequestTimeout {
+			requestTimeout = ngrokRequestTimeout
+		}
+		publicURL, err := getNgrokTunnelURL(url, selector, requestTimeout)
+		if err == nil && previous != "" && publicURL == previous {
+			err = fmt.Errorf("ngrok still reports the previous tunnel url %s", previous)
+		}
+		if err == nil {
+			return publicURL, nil
+		}
+		if time.Now().Add(ngrokPollInterval).After(deadline) {
+			return "", fmt.Errorf("timed out after %s waiting for ngrok tunnel: %s", timeout, err)
+		}
+		log.Infof("waiting for ngrok tunnel: %s", err)
+		time.Sleep(ngrokPollInterval)
+	}
+}
+
+// ngrokTunnelURL returns the tunnel url selected by the request options,
+// waiting for it to appear if requested
+// After a restart, it waits until the restart deadline for a url that differs
+// from previous
+func ngrokTunnelURL(req WebhookOptions, previous string, deadline time.Time) (string, error) {
+	selector := NgrokTunnelSelector{
+		Name:  req.TunnelName,
+		Proto: req.TunnelProto,
+		Addr:  req.TunnelAddr,
+	}
+	apiURL := req.NgrokAPIURL
+	if apiURL == "" {
+		apiURL = ngrokAPIAddr
+	}
+	if req.Restart {
+		return WaitForNgrokTunnelURL(apiURL, selector, previous, time.Until(deadline))
+	}
+	if req.Wait {
+		return WaitForNgrokTunnelURL(apiURL, selector, "", req.WaitTimeout)
+	}
+	return GetNgrokTunnelURL(apiURL, selector)
+}
+
+// restartNgrok edits the ngrok trigger ConfigMap and waits until deadline for
+// the ngrok Deployment to come back up
+// It returns the tunnel url that was active before the restart
+func restartNgrok(req WebhookOptions, kube *kubernetes.Client, deadline time.Time) (string, error) {
+	configmap, err := kube.ReadConfigMap(atlantisNamespace, ngrokConfigMapName)
+	if err != nil {
+		return "", err
+	}
+	previous := configmap[ngrokExistingTunnelKey]
+
+	before, err := kube.ReadDeploymentPodRestarts(atlantisNamespace, req.NgrokDeployment)
+	if err != nil {
+		return "", err
+	}
+
+	log.Info("editing ngrok trigger ConfigMap to trigger restart")
+	uuid := uuid.New()
+	// Set the trigger configmap key value to a random uuid to trigger a reload
+	err = kube.UpdateConfigMap(atlantisNamespace, ngrokTriggerConfigMapName, ngrokExistingTriggerKey, uuid.String())
 	if err != nil {
 		return "", err
 	}
 
+	err = kube.WaitForDeploymentRestart(atlantisNamespace, req.NgrokDeployment, before, time.Until(deadline))
+	if err != nil {
+		return "", err
+	}
+
+	return previous, nil
+}
+
+// getNgrokTunnels returns all tunnels reported by the ngrok api at url, giving
+// up on the request after timeout
+func getNgrokTunnels(url string, timeout time.Duration) ([]NgrokTunnelDefinition, error) {
+	client := &http.Client{Timeout: timeout}
+	resp, err := client.Get(url)
+	if err != nil {
+		return []NgrokTunnelDefinition{}, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return []NgrokTunnelDefinition{}, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return []NgrokTunnelDefinition{}, fmt.Errorf("ngrok api returned status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
 	tunnels := NgrokTunnelResponse{}
 	jsonErr := json.Unmarshal(body, &tunnels)
 	if jsonErr != nil {
-		return "", err
+		return []NgrokTunnelDefinition{}, fmt.Errorf("error parsing ngrok api response: %s", jsonErr)
+	}
+
+	return tunnels.Tunnels, nil
+}
+
+// selectNgrokTunnel narrows tunnels down to the one matching the selector
+// If no protocol is requested, https tunnels are preferred over any others
+func selectNgrokTunnel(tunnels []NgrokTunnelDefinition, selector NgrokTunnelSelector) (NgrokTunnelDefinition, error) {
+	candidates := make([]NgrokTunnelDefinition, 0)
+	for _, tunnel := range tunnels {
+		if selector.Name != "" && tunnel.Name != selector.Name {
+			continue
+		}
+		if selector.Proto != "" && tunnel.Proto != selector.Proto {
+			continue
+		}
+		if selector.Addr != "" && !matchNgrokAddr(tunnel.Addr(), selector.Addr) {
+			continue
+		}
+		candidates = append(candidates, tunnel)
 	}
 
-	var publicURL string
-	for _, tunnel := range tunnels.Tunnels {
-		publicURL = tunnel.Public_url
+	if selector.Proto == "" {
+		https := make([]NgrokTunnelDefinition, 0)
+		for _, tunnel := range candidates {
+			if tunnel.Proto == "https" {
+				https = append(https, tunnel)
+			}
+		}
+		if len(https) > 0 {
+			candidates = https
+		}
 	}
 
-	return publicURL, nil
+	switch len(candidates) {
+	case 0:
+		return NgrokTunnelDefinition{}, fmt.Errorf("no ngrok tunnels found matching %s", selector)
+	case 1:
+		return candidates[0], nil
+	default:
+		names := make([]string, 0, len(candidates))
+		for _, tunnel := range candidates {
+			names = append(names, tunnel.Name)
+		}
+		return NgrokTunnelDefinition{}, fmt.Errorf("found %d ngrok tunnels matching %s (%s), narrow the selection by name, proto or addr", len(candidates), selector, strings.Join(names, ", "))
+	}
+}
+
+// matchNgrokAddr compares upstream addresses, ignoring the scheme when
+// only one side provides it
+func matchNgrokAddr(addr string, want string) bool {
+	if addr == want {
+		return true
+	}
+	return trimScheme(addr) == trimScheme(want)
+}
+
+// trimScheme removes a leading url scheme from addr if present
+func trimScheme(addr string) string {
+	if i := strings.Index(addr, "://"); i >= 0 {
+		return addr[i+3:]
+	}
+	return addr
 }
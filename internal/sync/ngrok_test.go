@@ -0,0 +1,156 @@
+package sync
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+var testTunnels []NgrokTunnelDefinition = []NgrokTunnelDefinition{
+	{
+		Name:       "atlantis",
+		Proto:      "https",
+		Public_url: "https://atlantis.ngrok.io",
+		Config:     map[string]interface{}{"addr": "http://atlantis:4141"},
+	},
+	{
+		Name:       "atlantis (http)",
+		Proto:      "http",
+		Public_url: "http://atlantis.ngrok.io",
+		Config:     map[string]interface{}{"addr": "http://atlantis:4141"},
+	},
+	{
+		Name:       "argocd",
+		Proto:      "https",
+		Public_url: "https://argocd.ngrok.io",
+		Config:     map[string]interface{}{"addr": "http://argocd-server:80"},
+	},
+}
+
+func TestSelectNgrokTunnel(t *testing.T) {
+	tests := []struct {
+		name     string
+		tunnels  []NgrokTunnelDefinition
+		selector NgrokTunnelSelector
+		want     string
+		wantErr  bool
+	}{
+		{
+			name:     "If there are no tunnels, should return an error",
+			tunnels:  []NgrokTunnelDefinition{},
+			selector: NgrokTunnelSelector{},
+			wantErr:  true,
+		},
+		{
+			name:     "If several https tunnels match, should return an error",
+			tunnels:  testTunnels,
+			selector: NgrokTunnelSelector{},
+			wantErr:  true,
+		},
+		{
+			name:     "If selecting by name, should return that tunnel",
+			tunnels:  testTunnels,
+			selector: NgrokTunnelSelector{Name: "argocd"},
+			want:     "https://argocd.ngrok.io",
+		},
+		{
+			name:     "If selecting by addr without scheme, should prefer https",
+			tunnels:  testTunnels,
+			selector: NgrokTunnelSelector{Addr: "atlantis:4141"},
+			want:     "https://atlantis.ngrok.io",
+		},
+		{
+			name:     "If selecting by proto, should return that tunnel",
+			tunnels:  testTunnels,
+			selector: NgrokTunnelSelector{Addr: "http://atlantis:4141", Proto: "http"},
+			want:     "http://atlantis.ngrok.io",
+		},
+		{
+			name:     "If nothing matches, should return an error",
+			tunnels:  testTunnels,
+			selector: NgrokTunnelSelector{Name: "vault"},
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := selectNgrokTunnel(tt.tunnels, tt.selector)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("selectNgrokTunnel() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got.Public_url != tt.want {
+				t.Errorf("selectNgrokTunnel() = %v, want %v", got.Public_url, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetNgrokTunnelURL(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		body    string
+		want    string
+		wantErr bool
+	}{
+		{
+			name:   "If the api returns a single tunnel, should return its url",
+			status: http.StatusOK,
+			body:   `{"tunnels":[{"name":"atlantis","proto":"https","public_url":"https://atlantis.ngrok.io"}]}`,
+			want:   "https://atlantis.ngrok.io",
+		},
+		{
+			name:    "If the api returns an error status, should return an error",
+			status:  http.StatusBadGateway,
+			body:    `bad gateway`,
+			wantErr: true,
+		},
+		{
+			name:    "If the api returns invalid json, should return an error",
+			status:  http.StatusOK,
+			body:    `{"tunnels":`,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+				w.Write([]byte(tt.body))
+			}))
+			defer server.Close()
+
+			got, err := GetNgrokTunnelURL(server.URL, NgrokTunnelSelector{})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("GetNgrokTunnelURL() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("GetNgrokTunnelURL() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWaitForNgrokTunnelURL(t *testing.T) {
+	t.Run("If the api hangs, should give up at the wait deadline", func(t *testing.T) {
+		release := make(chan struct{})
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			select {
+			case <-release:
+			case <-r.Context().Done():
+			}
+		}))
+		defer server.Close()
+		defer close(release)
+
+		start := time.Now()
+		_, err := WaitForNgrokTunnelURL(server.URL, NgrokTunnelSelector{}, "", 500*time.Millisecond)
+		if err == nil {
+			t.Fatal("WaitForNgrokTunnelURL() error = nil, want timeout")
+		}
+		if elapsed := time.Since(start); elapsed > 5*time.Second {
+			t.Errorf("WaitForNgrokTunnelURL() returned after %s, want it bounded by the wait timeout", elapsed)
+		}
+	})
+}
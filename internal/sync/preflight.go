@@ -0,0 +1,68 @@
+package sync
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// preflightPollInterval is how often the public url is requested while a
+// fresh tunnel is not routing yet
+const preflightPollInterval time.Duration = 2 * time.Second
+
+// checkReachable requests path through the public url until it answers with
+// the expected status code or the timeout is reached
+func checkReachable(publicURL string, path string, status int, timeout time.Duration) error {
+	if status == 0 {
+		status = http.StatusOK
+	}
+	target := strings.TrimSuffix(publicURL, "/") + "/" + strings.TrimPrefix(path, "/")
+	client := &http.Client{
+		Timeout: timeout,
+		// A redirect, e.g. to a login page, does not prove the consumer is reached
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	deadline := time.Now().Add(timeout)
+	for {
+		err := requestStatus(client, target, status)
+		if err == nil {
+			log.Infof("pre-flight check of %s succeeded", target)
+			return nil
+		}
+		if time.Now().Add(preflightPollInterval).After(deadline) {
+			return err
+		}
+		log.Infof("waiting for %s to become reachable: %s", target, err)
+		time.Sleep(preflightPollInterval)
+	}
+}
+
+// requestStatus sends a GET request to target and fails unless it answers
+// with status
+func requestStatus(client *http.Client, target string, status int) error {
+	req, err := http.NewRequest(http.MethodGet, target, nil)
+	if err != nil {
+		return err
+	}
+	// Skip the interstitial page ngrok serves to browsers on free domains
+	req.Header.Set("ngrok-skip-browser-warning", "true")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+
+	if resp.StatusCode != status {
+		return fmt.Errorf("%s answered %d, expected %d", target, resp.StatusCode, status)
+	}
+	return nil
+}
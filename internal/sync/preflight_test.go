@@ -0,0 +1,71 @@
+package sync
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestCheckReachable(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/healthz":
+			w.WriteHeader(http.StatusOK)
+		case "/login":
+			http.Redirect(w, r, "/sso", http.StatusFound)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closed.Close()
+
+	tests := []struct {
+		name    string
+		url     string
+		path    string
+		status  int
+		wantErr bool
+	}{
+		{
+			name: "If the path answers the expected status, should succeed",
+			url:  server.URL + "/",
+			path: "/healthz",
+		},
+		{
+			name:   "If another status is expected, should accept it",
+			url:    server.URL,
+			path:   "missing",
+			status: http.StatusNotFound,
+		},
+		{
+			name:    "If the status is unexpected, should return an error",
+			url:     server.URL,
+			path:    "/missing",
+			wantErr: true,
+		},
+		{
+			name:    "If the path redirects, should return an error without following it",
+			url:     server.URL,
+			path:    "/login",
+			wantErr: true,
+		},
+		{
+			name:    "If the tunnel is unreachable, should return an error",
+			url:     closed.URL,
+			path:    "/healthz",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkReachable(tt.url, tt.path, tt.status, time.Second)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("checkReachable() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
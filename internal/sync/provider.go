@@ -0,0 +1,416 @@
+package sync
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/go-github/v45/github"
+	"github.com/kubefirst/git-helper/internal/common"
+	githubWrapper "github.com/kubefirst/git-helper/internal/github"
+	gitlabWrapper "github.com/kubefirst/git-helper/internal/gitlab"
+	"github.com/kubefirst/git-helper/internal/hooks"
+	log "github.com/sirupsen/logrus"
+	"github.com/xanzy/go-gitlab"
+)
+
+// atlantisSecretTokenKeys maps each provider to the key of its webhook token
+// in the Atlantis secret
+var atlantisSecretTokenKeys map[string]string = map[string]string{
+	"github": "ATLANTIS_GH_WEBHOOK_SECRET",
+	"gitlab": "ATLANTIS_GITLAB_WEBHOOK_SECRET",
+}
+
+// webhook describes a hook registered on a repository or project
+type webhook struct {
+	ID  int64
+	URL string
+}
+
+// webhookProvider manages the webhooks of a single repository or project
+type webhookProvider interface {
+	// ListWebhooks returns all hooks of the repository
+	ListWebhooks() ([]webhook, error)
+	// CreateWebhook creates a hook delivering to url and returns its ID
+	CreateWebhook(url string, token string) (int64, error)
+	// UpdateWebhook points a single hook at url with the configured settings
+	UpdateWebhook(hookID int64, url string, token string) error
+	// DeleteWebhookByID deletes a single hook
+	DeleteWebhookByID(hookID int64) error
+	// DeleteWebhookByURL deletes every hook delivering to url
+	DeleteWebhookByURL(url string) error
+	// ListDeliveries returns up to limit of the most recent deliveries of a
+	// hook, most recent first
+	ListDeliveries(hookID int64, limit int) ([]Delivery, error)
+	// Redeliver sends a delivery of a hook again
+	Redeliver(hookID int64, deliveryID int64) error
+	// PingWebhook sends a test event to a hook and waits up to timeout for
+	// the result of its delivery
+	PingWebhook(hookID int64, timeout time.Duration) (Delivery, error)
+}
+
+// webhookClient manages the webhooks of the repositories or projects of an
+// owner, sharing a single api client
+type webhookClient interface {
+	// Repositories returns the names of the repositories of the owner,
+	// optionally limited by filter
+	Repositories(filter repositoryFilter) ([]string, error)
+	// Repository returns a webhookProvider for a single repository
+	Repository(name string) webhookProvider
+}
+
+// repositoryFilter limits the repositories listed by a webhookClient
+type repositoryFilter struct {
+	// Topic is supported by both providers
+	Topic string
+	// Team is a GitHub team slug
+	Team string
+	// Subgroup is a GitLab subgroup path relative to the owner group
+	Subgroup string
+}
+
+// newWebhookClient returns a webhookClient for the owner selected by the
+// request options, authenticated with GIT_TOKEN
+func newWebhookClient(req WebhookOptions) (webhookClient, error) {
+	switch req.Provider {
+	case "github":
+		client, err := githubWrapper.NewGitHubClient(os.Getenv("GIT_TOKEN"), req.Transport)
+		if err != nil {
+			return nil, err
+		}
+		return &githubClient{
+			client: client,
+			owner:  req.Owner,
+			strict: req.Strict,
+			hook:   req.Hook,
+		}, nil
+	case "gitlab":
+		client, err := gitlabWrapper.NewGitLabClient(os.Getenv("GIT_TOKEN"), req.Owner, req.Transport)
+		if err != nil {
+			return nil, err
+		}
+		client.Recursive = req.GitLabRecursive
+		if !req.Hook.IsActive() {
+			log.Warn("gitlab hooks cannot be created inactive, ignoring --active=false")
+		}
+		return &gitlabClient{client: client, strict: req.Strict, hook: req.Hook}, nil
+	default:
+		return nil, fmt.Errorf("unsupported provider %s", req.Provider)
+	}
+}
+
+// newWebhookProvider returns a webhookProvider for the repository selected by
+// the request options, authenticated with GIT_TOKEN
+func newWebhookProvider(req WebhookOptions) (webhookProvider, error) {
+	client, err := newWebhookClient(req)
+	if err != nil {
+		return nil, err
+	}
+	return client.Repository(req.Repository), nil
+}
+
+// githubClient manages the webhooks of the repositories of a GitHub
+// organization or user
+type githubClient struct {
+	client githubWrapper.GitHubWrapper
+	owner  string
+	strict bool
+	hook   hooks.Options
+}
+
+func (c *githubClient) Repositories(filter repositoryFilter) ([]string, error) {
+	if filter.Subgroup != "" {
+		return []string{}, fmt.Errorf("subgroups are only supported by gitlab")
+	}
+
+	var repos []*github.Repository
+	var err error
+	if filter.Team != "" {
+		repos, err = c.client.ListTeamRepositories(c.owner, filter.Team)
+	} else {
+		repos, err = c.client.ListOwnerRepositories(c.owner)
+	}
+	if err != nil {
+		return []string{}, err
+	}
+
+	names := make([]string, 0, len(repos))
+	for _, repo := range repos {
+		if repo.GetArchived() {
+			continue
+		}
+		if filter.Topic != "" {
+			_, found := common.FindInSlice(repo.Topics, filter.Topic)
+			if !found {
+				continue
+			}
+		}
+		names = append(names, repo.GetName())
+	}
+	return names, nil
+}
+
+func (c *githubClient) Repository(name string) webhookProvider {
+	return &githubProvider{
+		client:     c.client,
+		owner:      c.owner,
+		repository: name,
+		strict:     c.strict,
+		hook:       c.hook,
+	}
+}
+
+// gitlabClient manages the webhooks of the projects of a GitLab group
+type gitlabClient struct {
+	client gitlabWrapper.GitLabWrapper
+	strict bool
+	hook   hooks.Options
+}
+
+func (c *gitlabClient) Repositories(filter repositoryFilter) ([]string, error) {
+	if filter.Team != "" {
+		return []string{}, fmt.Errorf("teams are only supported by github")
+	}
+
+	var projects []gitlab.Project
+	var err error
+	if filter.Subgroup != "" {
+		projects, err = c.client.ListGroupProjects(c.client.Owner+"/"+strings.Trim(filter.Subgroup, "/"), filter.Topic)
+	} else {
+		projects, err = c.client.ListOwnerProjects(filter.Topic)
+	}
+	if err != nil {
+		return []string{}, err
+	}
+	names := make([]string, 0, len(projects))
+	for _, project := range projects {
+		names = append(names, c.client.RelativeProjectPath(project))
+	}
+	return names, nil
+}
+
+func (c *gitlabClient) Repository(name string) webhookProvider {
+	return &gitlabProvider{
+		client:  c.client,
+		project: name,
+		strict:  c.strict,
+		hook:    c.hook,
+	}
+}
+
+// githubProvider manages the webhooks of a GitHub repository
+type githubProvider struct {
+	client     githubWrapper.GitHubWrapper
+	owner      string
+	repository string
+	strict     bool
+	hook       hooks.Options
+}
+
+func (p *githubProvider) ListWebhooks() ([]webhook, error) {
+	hooks, err := p.client.ListRepoWebhooks(p.owner, p.repository)
+	if err != nil {
+		return []webhook{}, err
+	}
+	webhooks := make([]webhook, 0, len(hooks))
+	for _, hook := range hooks {
+		url, _ := hook.Config["url"].(string)
+		webhooks = append(webhooks, webhook{ID: hook.GetID(), URL: url})
+	}
+	return webhooks, nil
+}
+
+func (p *githubProvider) CreateWebhook(url string, token string) (int64, error) {
+	return p.client.CreateRepositoryWebhook(githubWrapper.RepositoryHookRequest{
+		Org:        p.owner,
+		Repository: p.repository,
+		Url:        url,
+		Token:      token,
+		Options:    p.hook,
+	})
+}
+
+func (p *githubProvider) UpdateWebhook(hookID int64, url string, token string) error {
+	return p.client.UpdateRepositoryWebhookByID(githubWrapper.RepositoryHookRequest{
+		Org:        p.owner,
+		Repository: p.repository,
+		Url:        url,
+		Token:      token,
+		Options:    p.hook,
+	}, hookID)
+}
+
+func (p *githubProvider) DeleteWebhookByID(hookID int64) error {
+	return p.client.DeleteRepositoryWebhookByID(p.owner, p.repository, hookID)
+}
+
+func (p *githubProvider) DeleteWebhookByURL(url string) error {
+	return p.client.DeleteRepositoryWebhook(githubWrapper.RepositoryHookRequest{
+		Org:        p.owner,
+		Repository: p.repository,
+		Url:        url,
+		Strict:     p.strict,
+	})
+}
+
+func (p *githubProvider) ListDeliveries(hookID int64, limit int) ([]Delivery, error) {
+	hookDeliveries, err := p.client.ListHookDeliveries(p.owner, p.repository, hookID, limit)
+	if err != nil {
+		return []Delivery{}, err
+	}
+	deliveries := make([]Delivery, 0, len(hookDeliveries))
+	for _, delivery := range hookDeliveries {
+		var seconds float64
+		if delivery.Duration != nil {
+			seconds = *delivery.Duration
+		}
+		deliveries = append(deliveries, Delivery{
+			ID:          delivery.GetID(),
+			GUID:        delivery.GetGUID(),
+			Event:       delivery.GetEvent(),
+			StatusCode:  delivery.GetStatusCode(),
+			Status:      delivery.GetStatus(),
+			DeliveredAt: delivery.GetDeliveredAt().Time,
+			Duration:    time.Duration(seconds * float64(time.Second)),
+			Redelivery:  delivery.GetRedelivery(),
+		})
+	}
+	return deliveries, nil
+}
+
+func (p *githubProvider) Redeliver(hookID int64, deliveryID int64) error {
+	return p.client.RedeliverHookDelivery(p.owner, p.repository, hookID, deliveryID)
+}
+
+// PingWebhook pings the hook and waits for the ping to show up in its
+// deliveries, which GitHub records asynchronously
+func (p *githubProvider) PingWebhook(hookID int64, timeout time.Duration) (Delivery, error) {
+	previous, err := p.ListDeliveries(hookID, verifyDeliveryLimit)
+	if err != nil {
+		return Delivery{}, err
+	}
+	err = p.client.PingRepositoryWebhook(p.owner, p.repository, hookID)
+	if err != nil {
+		return Delivery{}, err
+	}
+	return waitForDelivery(p, hookID, "ping", previous, timeout)
+}
+
+// gitlabProvider manages the webhooks of a GitLab project
+type gitlabProvider struct {
+	client  gitlabWrapper.GitLabWrapper
+	project string
+	strict  bool
+	hook    hooks.Options
+}
+
+func (p *gitlabProvider) ListWebhooks() ([]webhook, error) {
+	projectID, err := p.client.GetProjectID(p.project)
+	if err != nil {
+		return []webhook{}, err
+	}
+	hooks, err := p.client.ListProjectWebhooks(projectID)
+	if err != nil {
+		return []webhook{}, err
+	}
+	webhooks := make([]webhook, 0, len(hooks))
+	for _, hook := range hooks {
+		webhooks = append(webhooks, webhook{ID: int64(hook.ID), URL: hook.URL})
+	}
+	return webhooks, nil
+}
+
+func (p *gitlabProvider) CreateWebhook(url string, token string) (int64, error) {
+	opts, err := p.hook.GitLabHookOptions(url, token)
+	if err != nil {
+		return 0, err
+	}
+	hookID, err := p.client.CreateProjectWebhook(&gitlabWrapper.ProjectHookRequest{
+		ProjectName: p.project,
+		CreateOpts:  opts,
+	})
+	return int64(hookID), err
+}
+
+func (p *gitlabProvider) UpdateWebhook(hookID int64, url string, token string) error {
+	opts, err := p.hook.GitLabEditHookOptions(url, token)
+	if err != nil {
+		return err
+	}
+	return p.client.UpdateProjectWebhookByID(p.project, int(hookID), opts)
+}
+
+func (p *gitlabProvider) DeleteWebhookByID(hookID int64) error {
+	return p.client.DeleteProjectWebhookByID(p.project, int(hookID))
+}
+
+func (p *gitlabProvider) DeleteWebhookByURL(url string) error {
+	return p.client.DeleteProjectWebhook(&gitlabWrapper.ProjectHookRequest{
+		ProjectName: p.project,
+		Strict:      p.strict,
+		CreateOpts: &gitlab.AddProjectHookOptions{
+			URL: &url,
+		},
+	})
+}
+
+func (p *gitlabProvider) ListDeliveries(hookID int64, limit int) ([]Delivery, error) {
+	events, err := p.client.ListProjectHookEvents(p.project, int(hookID), limit)
+	if err != nil {
+		return []Delivery{}, err
+	}
+	deliveries := make([]Delivery, 0, len(events))
+	for _, event := range events {
+		// The response status is the http status code or an error message
+		statusCode, _ := strconv.Atoi(event.ResponseStatus)
+		delivery := Delivery{
+			ID:         int64(event.ID),
+			Event:      event.Trigger,
+			StatusCode: statusCode,
+			Status:     event.ResponseStatus,
+			Duration:   time.Duration(event.ExecutionDuration * float64(time.Second)),
+			GUID:       gitlabEventGUID(event.RequestHeaders),
+		}
+		if event.CreatedAt != nil {
+			delivery.DeliveredAt = *event.CreatedAt
+		}
+		deliveries = append(deliveries, delivery)
+	}
+	return deliveries, nil
+}
+
+// gitlabEventGUID returns the id GitLab keeps for an event across resends,
+// the X-Gitlab-Idempotency-Key header or else the X-Gitlab-Event-UUID header
+func gitlabEventGUID(headers map[string]string) string {
+	for _, name := range []string{"X-Gitlab-Idempotency-Key", "X-Gitlab-Event-UUID"} {
+		for key, value := range headers {
+			if strings.EqualFold(key, name) && value != "" {
+				return value
+			}
+		}
+	}
+	return ""
+}
+
+func (p *gitlabProvider) Redeliver(hookID int64, deliveryID int64) error {
+	return p.client.ResendProjectHookEvent(p.project, int(hookID), int(deliveryID))
+}
+
+// PingWebhook sends a push test event, whose result GitLab returns right away
+func (p *gitlabProvider) PingWebhook(hookID int64, timeout time.Duration) (Delivery, error) {
+	result, err := p.client.TestProjectWebhook(p.project, int(hookID), "push_events", timeout)
+	if err != nil {
+		return Delivery{}, err
+	}
+	if result.Inconclusive {
+		return Delivery{}, fmt.Errorf("%w: %s", errVerifyInconclusive, result.Message)
+	}
+	return Delivery{
+		Event:       "push_events",
+		StatusCode:  result.StatusCode,
+		Status:      result.Message,
+		DeliveredAt: time.Now().UTC(),
+	}, nil
+}
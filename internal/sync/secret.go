@@ -0,0 +1,96 @@
+package sync
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/kubefirst/git-helper/internal/kubernetes"
+)
+
+// errSecretKeyNotFound is returned when the Secret lacks the webhook secret key
+var errSecretKeyNotFound = errors.New("no key")
+
+// WebhookSecret returns the webhook secret selected by the request options:
+// Token when set, otherwise the SecretValues key (the Atlantis key of the
+// provider by default) of the Secret SecretNamespace/SecretName (the Atlantis
+// Secret by default) when UseSecret is set, and no secret at all otherwise
+func WebhookSecret(req WebhookOptions) (string, error) {
+	var kube *kubernetes.Client
+	if readsSecret(req) {
+		var err error
+		kube, err = newKubernetesClient(req)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	return webhookSecret(req, kube)
+}
+
+// WebhookSecrets returns the webhook secret of every provider, keyed by
+// provider, selected by the request options like WebhookSecret. Providers
+// whose key is missing from the Secret get no secret
+func WebhookSecrets(req WebhookOptions) (map[string]string, error) {
+	var kube *kubernetes.Client
+	if readsSecret(req) {
+		var err error
+		kube, err = newKubernetesClient(req)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return webhookSecrets(req, kube)
+}
+
+// readsSecret reports whether the request options read the webhook secret
+// from a Kubernetes Secret rather than taking the token
+func readsSecret(req WebhookOptions) bool {
+	return req.Token == "" && req.UseSecret
+}
+
+// webhookSecrets returns the webhook secret of every provider selected by the
+// request options
+func webhookSecrets(req WebhookOptions, kube *kubernetes.Client) (map[string]string, error) {
+	secrets := map[string]string{}
+	for provider := range atlantisSecretTokenKeys {
+		req.Provider = provider
+		secret, err := webhookSecret(req, kube)
+		if err != nil && !errors.Is(err, errSecretKeyNotFound) {
+			return nil, err
+		}
+		secrets[provider] = secret
+	}
+
+	return secrets, nil
+}
+
+// webhookSecret returns the webhook secret selected by the request options,
+// reading it from a Kubernetes Secret unless the token is used
+func webhookSecret(req WebhookOptions, kube *kubernetes.Client) (string, error) {
+	if !readsSecret(req) {
+		return req.Token, nil
+	}
+
+	namespace, name, key := req.SecretNamespace, req.SecretName, req.SecretValues
+	if namespace == "" {
+		namespace = atlantisNamespace
+	}
+	if name == "" {
+		name = atlantisSecretName
+	}
+	if key == "" {
+		key = atlantisSecretTokenKeys[req.Provider]
+	}
+
+	secret, err := kube.ReadSecret(namespace, name)
+	if err != nil {
+		return "", err
+	}
+	value, ok := secret[key]
+	if !ok {
+		return "", fmt.Errorf("%w %s in secret %s/%s", errSecretKeyNotFound, key, namespace, name)
+	}
+
+	return value, nil
+}
@@ -0,0 +1,100 @@
+package sync
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestWebhookSecret(t *testing.T) {
+	tests := []struct {
+		name    string
+		req     WebhookOptions
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "If a token is set, should prefer it over the Secret",
+			req:  WebhookOptions{Provider: "github", Token: "token", UseSecret: true},
+			want: "token",
+		},
+		{
+			name: "If no Secret is used, should return no secret",
+			req:  WebhookOptions{Provider: "github"},
+			want: "",
+		},
+		{
+			name: "If the Secret is used, should read the Atlantis secret by default",
+			req:  WebhookOptions{Provider: "github", UseSecret: true},
+			want: "secret",
+		},
+		{
+			name: "If the key is set, should read it instead of the provider key",
+			req:  WebhookOptions{Provider: "gitlab", UseSecret: true, SecretValues: "ATLANTIS_GH_WEBHOOK_SECRET"},
+			want: "secret",
+		},
+		{
+			name:    "If the key is missing, should fail",
+			req:     WebhookOptions{Provider: "gitlab", UseSecret: true},
+			wantErr: true,
+		},
+		{
+			name:    "If the Secret is missing, should fail",
+			req:     WebhookOptions{Provider: "github", UseSecret: true, SecretName: "missing"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := webhookSecret(tt.req, newTestKube("placeholder"))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("webhookSecret() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("webhookSecret() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWebhookSecrets(t *testing.T) {
+	tests := []struct {
+		name    string
+		req     WebhookOptions
+		want    map[string]string
+		wantErr bool
+	}{
+		{
+			name: "If a token is set, should use it for every provider",
+			req:  WebhookOptions{Token: "token", UseSecret: true},
+			want: map[string]string{"github": "token", "gitlab": "token"},
+		},
+		{
+			name: "If a provider key is missing, should leave that provider without a secret",
+			req:  WebhookOptions{UseSecret: true},
+			want: map[string]string{"github": "secret", "gitlab": ""},
+		},
+		{
+			name: "If the key is set, should read it for every provider",
+			req:  WebhookOptions{UseSecret: true, SecretValues: "ATLANTIS_GH_WEBHOOK_SECRET"},
+			want: map[string]string{"github": "secret", "gitlab": "secret"},
+		},
+		{
+			name:    "If the Secret is missing, should fail",
+			req:     WebhookOptions{UseSecret: true, SecretName: "missing"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := webhookSecrets(tt.req, newTestKube("placeholder"))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("webhookSecrets() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("webhookSecrets() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,85 @@
+package sync
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// selectRepositories returns the repositories chosen by the selector, falling
+// back to the repository of the request options
+func selectRepositories(req WebhookOptions, client webhookClient, selector RepositorySelector) ([]string, error) {
+	var match *regexp.Regexp
+	if selector.Match != "" {
+		var err error
+		match, err = regexp.Compile(selector.Match)
+		if err != nil {
+			return []string{}, fmt.Errorf("invalid repository pattern %s: %s", selector.Match, err)
+		}
+	}
+
+	repositories := append([]string{}, selector.Repositories...)
+	if selector.File != "" {
+		listed, err := readRepositoryFile(selector.File)
+		if err != nil {
+			return []string{}, err
+		}
+		repositories = append(repositories, listed...)
+	}
+
+	filter := repositoryFilter{
+		Topic:    selector.Topic,
+		Team:     selector.Team,
+		Subgroup: selector.Subgroup,
+	}
+	listAll := match != nil && len(repositories) == 0
+	if selector.AllRepositories || listAll || filter != (repositoryFilter{}) {
+		listed, err := client.Repositories(filter)
+		if err != nil {
+			return []string{}, fmt.Errorf("error listing repositories of %s: %s", req.Owner, err)
+		}
+		repositories = append(repositories, listed...)
+	}
+
+	if len(repositories) == 0 && req.Repository != "" {
+		repositories = []string{req.Repository}
+	}
+
+	selected := make([]string, 0, len(repositories))
+	seen := make(map[string]bool)
+	for _, repository := range repositories {
+		if seen[repository] || (match != nil && !match.MatchString(repository)) {
+			continue
+		}
+		seen[repository] = true
+		selected = append(selected, repository)
+	}
+	if len(selected) == 0 {
+		return []string{}, fmt.Errorf("no repositories selected, use --repository, --repositories-file, --all-repositories or a selector")
+	}
+
+	return selected, nil
+}
+
+// readRepositoryFile reads one repository per line, skipping blank lines and
+// # comments
+func readRepositoryFile(path string) ([]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return []string{}, fmt.Errorf("error reading repositories file %s: %s", path, err)
+	}
+
+	repositories := make([]string, 0)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		repositories = append(repositories, line)
+	}
+	return repositories, scanner.Err()
+}
@@ -0,0 +1,145 @@
+package sync
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestSelectRepositories(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "repositories.txt")
+	err := os.WriteFile(file, []byte("metaphor\n\n# skipped\n  gitops  \n"), 0o644)
+	if err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+
+	tests := []struct {
+		name        string
+		repository  string
+		selector    RepositorySelector
+		want        []string
+		wantFilters []repositoryFilter
+		wantErr     bool
+	}{
+		{
+			name:       "If no selector is given, should fall back to the request repository",
+			repository: "gitops",
+			want:       []string{"gitops"},
+		},
+		{
+			name:     "If repositories and a file are given, should combine them without duplicates",
+			selector: RepositorySelector{Repositories: []string{"gitops", "console"}, File: file},
+			want:     []string{"gitops", "console", "metaphor"},
+		},
+		{
+			name:        "If all repositories are selected, should list them",
+			selector:    RepositorySelector{AllRepositories: true},
+			want:        []string{"console", "gitops", "metaphor"},
+			wantFilters: []repositoryFilter{{}},
+		},
+		{
+			name:        "If a topic is given, should list repositories with the topic filter",
+			selector:    RepositorySelector{Topic: "atlantis"},
+			want:        []string{"console", "gitops", "metaphor"},
+			wantFilters: []repositoryFilter{{Topic: "atlantis"}},
+		},
+		{
+			name:        "If only a pattern is given, should list repositories matching it",
+			selector:    RepositorySelector{Match: "^meta"},
+			want:        []string{"metaphor"},
+			wantFilters: []repositoryFilter{{}},
+		},
+		{
+			name:     "If a pattern is given with repositories, should filter them without listing",
+			selector: RepositorySelector{Repositories: []string{"gitops", "metaphor"}, Match: "ops$"},
+			want:     []string{"gitops"},
+		},
+		{
+			name:     "If the pattern is invalid, should return an error",
+			selector: RepositorySelector{Match: "("},
+			wantErr:  true,
+		},
+		{
+			name:     "If nothing matches, should return an error",
+			selector: RepositorySelector{Repositories: []string{"gitops"}, Match: "^console$"},
+			wantErr:  true,
+		},
+		{
+			name:     "If the file does not exist, should return an error",
+			selector: RepositorySelector{File: filepath.Join(dir, "missing.txt")},
+			wantErr:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &fakeClient{hooks: map[string][]webhook{"gitops": {}, "metaphor": {}, "console": {}}}
+			req := WebhookOptions{Owner: "kubefirst", Repository: tt.repository}
+
+			got, err := selectRepositories(req, client, tt.selector)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("selectRepositories() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("selectRepositories() = %v, want %v", got, tt.want)
+			}
+			if len(client.filters) != len(tt.wantFilters) || (len(tt.wantFilters) > 0 && !reflect.DeepEqual(client.filters, tt.wantFilters)) {
+				t.Errorf("Repositories() filters = %v, want %v", client.filters, tt.wantFilters)
+			}
+		})
+	}
+}
+
+func TestReadRepositoryFile(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    []string
+	}{
+		{
+			name:    "If the file lists repositories, should return one per line",
+			content: "gitops\nmetaphor\n",
+			want:    []string{"gitops", "metaphor"},
+		},
+		{
+			name:    "If the file has blank lines, comments and padding, should skip and trim them",
+			content: "# atlantis repositories\n\n  gitops\t\n   \n#metaphor\nconsole",
+			want:    []string{"gitops", "console"},
+		},
+		{
+			name:    "If the file is empty, should return no repositories",
+			content: "",
+			want:    []string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "repositories.txt")
+			err := os.WriteFile(path, []byte(tt.content), 0o644)
+			if err != nil {
+				t.Fatalf("WriteFile() error = %v", err)
+			}
+
+			got, err := readRepositoryFile(path)
+			if err != nil {
+				t.Fatalf("readRepositoryFile() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("readRepositoryFile() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	t.Run("If the file does not exist, should return an error", func(t *testing.T) {
+		_, err := readRepositoryFile(filepath.Join(t.TempDir(), "missing.txt"))
+		if err == nil {
+			t.Errorf("readRepositoryFile() expected an error")
+		}
+	})
+}
@@ -0,0 +1,156 @@
+package sync
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/kubefirst/git-helper/internal/kubernetes"
+	"github.com/kubefirst/git-helper/internal/state"
+	log "github.com/sirupsen/logrus"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+const (
+	URLSourceNgrok     string = "ngrok"
+	URLSourceIngress   string = "ingress"
+	URLSourceHTTPRoute string = "httproute"
+	URLSourceService   string = "service"
+	URLSourceURL       string = "url"
+)
+
+// AllowedURLSources lists the supported ways of discovering the public url
+var AllowedURLSources []string = []string{URLSourceNgrok, URLSourceIngress, URLSourceHTTPRoute, URLSourceService, URLSourceURL}
+
+// publicURL returns the public url to register webhooks against, discovered
+// through the source selected by the request options
+// previous and deadline describe an ngrok restart, if one was triggered
+func publicURL(req WebhookOptions, kube *kubernetes.Client, previous string, deadline time.Time) (string, error) {
+	if req.URLSource != "" && req.URLSource != URLSourceNgrok && req.URLSource != URLSourceURL && req.SourceName == "" {
+		return "", fmt.Errorf("a source name is required when using url source %s", req.URLSource)
+	}
+
+	switch req.URLSource {
+	case "", URLSourceNgrok:
+		return ngrokTunnelURL(req, previous, deadline)
+	case URLSourceIngress:
+		return kube.ReadIngressURL(req.SourceNamespace, req.SourceName)
+	case URLSourceHTTPRoute:
+		return kube.ReadHTTPRouteURL(req.SourceNamespace, req.SourceName)
+	case URLSourceService:
+		return kube.ReadServiceLoadBalancerURL(req.SourceNamespace, req.SourceName)
+	case URLSourceURL:
+		if req.Url == "" {
+			return "", fmt.Errorf("a url is required when using url source %s", req.URLSource)
+		}
+		return strings.TrimSuffix(req.Url, "/"), nil
+	default:
+		return "", fmt.Errorf("unsupported url source %s, must be one of %s", req.URLSource, AllowedURLSources)
+	}
+}
+
+// ResolvePublicURL returns the public url currently reported by the source
+// selected by the request options
+func ResolvePublicURL(req WebhookOptions, kube *kubernetes.Client) (string, error) {
+	return publicURL(req, kube, "", time.Time{})
+}
+
+// WatchAtlantisWebhook resolves the public url on an interval and synchronizes
+// the Atlantis webhook whenever it differs from the url last registered
+// It runs until ctx is cancelled, only while leading if leader election is enabled
+func WatchAtlantisWebhook(ctx context.Context, req WebhookOptions) error {
+	if req.Restart {
+		return fmt.Errorf("--restart cannot be combined with --watch")
+	}
+	if req.Cleanup {
+		return fmt.Errorf("--cleanup cannot be combined with --watch")
+	}
+
+	kube, err := newKubernetesClient(req)
+	if err != nil {
+		return err
+	}
+
+	return runAsLeader(ctx, req, kube, func(ctx context.Context) error {
+		for {
+			err := withSyncLock(ctx, req, kube, func() error {
+				return synchronizeIfChanged(req, kube)
+			})
+			if err != nil {
+				log.Errorf("error synchronizing webhook: %s", err)
+			}
+
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-time.After(req.WatchInterval):
+			}
+		}
+	})
+}
+
+// synchronizeIfChanged runs SynchronizeAtlantisWebhook when the public url no
+// longer matches the one last registered
+func synchronizeIfChanged(req WebhookOptions, kube *kubernetes.Client) error {
+	registered, err := registeredURL(req, kube)
+	if err != nil {
+		return err
+	}
+
+	current, err := ResolvePublicURL(req, kube)
+	if err != nil {
+		return err
+	}
+	if current == registered {
+		log.Debugf("public url %s unchanged", current)
+		return nil
+	}
+
+	log.Infof("public url changed from %s to %s", registered, current)
+	return synchronizeAtlantisWebhook(req, kube)
+}
+
+// registeredURL returns the public url the webhook was last registered with,
+// from the state store or else the ngrok ConfigMap
+func registeredURL(req WebhookOptions, kube *kubernetes.Client) (string, error) {
+	store, err := newStateStore(req, kube)
+	if err != nil {
+		return "", err
+	}
+	existing, err := store.Get(state.Key(req.Provider, req.Owner, req.Repository))
+	if err == nil && req.URLSource == URLSourceURL {
+		return existing.URL, nil
+	}
+	if err == nil {
+		return strings.TrimSuffix(existing.URL, "/events"), nil
+	}
+	if err != state.ErrNotFound {
+		return "", err
+	}
+
+	return readTunnelURL(kube)
+}
+
+// readTunnelURL returns the url recorded in the ngrok ConfigMap, or no url if
+// the ConfigMap does not exist, as on clusters using another url source
+func readTunnelURL(kube *kubernetes.Client) (string, error) {
+	configmap, err := kube.ReadConfigMap(atlantisNamespace, ngrokConfigMapName)
+	if apierrors.IsNotFound(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return configmap[ngrokExistingTunnelKey], nil
+}
+
+// hookURL returns the webhook url registered for a public url, which is
+// taken as is with the url source so it can point at any path, e.g. a relay
+// channel
+func hookURL(req WebhookOptions, endpoint string) string {
+	if req.URLSource == URLSourceURL {
+		return endpoint
+	}
+	return fmt.Sprintf("%s/events", endpoint)
+}
@@ -0,0 +1,180 @@
+package sync
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/kubefirst/git-helper/internal/kubernetes"
+	"github.com/kubefirst/git-helper/internal/state"
+	v1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// newSourceKube returns a Kubernetes client holding an Ingress, a
+// LoadBalancer Service and an HTTPRoute named atlantis
+func newSourceKube(t *testing.T) *kubernetes.Client {
+	dynamic := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme())
+	route := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "gateway.networking.k8s.io/v1",
+		"kind":       "HTTPRoute",
+		"metadata":   map[string]interface{}{"name": "atlantis", "namespace": atlantisNamespace},
+		"spec":       map[string]interface{}{"hostnames": []interface{}{"route.example.com"}},
+	}}
+	gvr := schema.GroupVersionResource{Group: "gateway.networking.k8s.io", Version: "v1", Resource: "httproutes"}
+	_, err := dynamic.Resource(gvr).Namespace(atlantisNamespace).Create(context.Background(), route, metav1.CreateOptions{})
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+
+	return kubernetes.NewClientFromInterface(fake.NewSimpleClientset(
+		&networkingv1.Ingress{
+			ObjectMeta: metav1.ObjectMeta{Name: "atlantis", Namespace: atlantisNamespace},
+			Spec: networkingv1.IngressSpec{
+				Rules: []networkingv1.IngressRule{{Host: "ingress.example.com"}},
+				TLS:   []networkingv1.IngressTLS{{Hosts: []string{"ingress.example.com"}}},
+			},
+		},
+		&v1.Service{
+			ObjectMeta: metav1.ObjectMeta{Name: "atlantis", Namespace: atlantisNamespace},
+			Spec:       v1.ServiceSpec{Ports: []v1.ServicePort{{Port: 4141}}},
+			Status:     v1.ServiceStatus{LoadBalancer: v1.LoadBalancerStatus{Ingress: []v1.LoadBalancerIngress{{IP: "203.0.113.10"}}}},
+		},
+	), dynamic)
+}
+
+func TestPublicURL(t *testing.T) {
+	tests := []struct {
+		name    string
+		req     WebhookOptions
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "If the source is an Ingress, should read its host",
+			req:  WebhookOptions{URLSource: URLSourceIngress, SourceName: "atlantis", SourceNamespace: atlantisNamespace},
+			want: "https://ingress.example.com",
+		},
+		{
+			name: "If the source is an HTTPRoute, should read its hostname",
+			req:  WebhookOptions{URLSource: URLSourceHTTPRoute, SourceName: "atlantis", SourceNamespace: atlantisNamespace},
+			want: "http://route.example.com",
+		},
+		{
+			name: "If the source is a Service, should read its load balancer address",
+			req:  WebhookOptions{URLSource: URLSourceService, SourceName: "atlantis", SourceNamespace: atlantisNamespace},
+			want: "http://203.0.113.10:4141",
+		},
+		{
+			name: "If the source is a url, should use it without a trailing slash",
+			req:  WebhookOptions{URLSource: URLSourceURL, Url: "https://relay.example.com/events/"},
+			want: "https://relay.example.com/events",
+		},
+		{
+			name:    "If the source resource does not exist, should fail",
+			req:     WebhookOptions{URLSource: URLSourceIngress, SourceName: "missing", SourceNamespace: atlantisNamespace},
+			wantErr: true,
+		},
+		{
+			name:    "If a Kubernetes source has no name, should fail",
+			req:     WebhookOptions{URLSource: URLSourceService},
+			wantErr: true,
+		},
+		{
+			name:    "If the url source has no url, should fail",
+			req:     WebhookOptions{URLSource: URLSourceURL},
+			wantErr: true,
+		},
+		{
+			name:    "If the source is unknown, should fail",
+			req:     WebhookOptions{URLSource: "dns", SourceName: "atlantis"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := publicURL(tt.req, newSourceKube(t), "", time.Time{})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("publicURL() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("publicURL() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRegisteredURL(t *testing.T) {
+	tests := []struct {
+		name string
+		// tunnelURL is the url in the ngrok ConfigMap, which is left out if
+		// empty
+		tunnelURL string
+		stateURL  string
+		urlSource string
+		want      string
+	}{
+		{
+			name:      "If state is recorded, should return its public url",
+			tunnelURL: "https://old.ngrok.io",
+			stateURL:  "https://new.ngrok.io/events",
+			want:      "https://new.ngrok.io",
+		},
+		{
+			name:      "If state is recorded with the url source, should return the hook url as is",
+			stateURL:  "https://smee.io/channel",
+			urlSource: URLSourceURL,
+			want:      "https://smee.io/channel",
+		},
+		{
+			name:      "If no state is recorded, should return the ngrok configmap url",
+			tunnelURL: "https://old.ngrok.io",
+			want:      "https://old.ngrok.io",
+		},
+		{
+			name:      "If neither state nor the ngrok configmap exist, should return no url",
+			urlSource: URLSourceIngress,
+			want:      "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := WebhookOptions{
+				Provider:       "github",
+				Owner:          "kubefirst",
+				Repository:     "gitops",
+				URLSource:      tt.urlSource,
+				StateBackend:   state.BackendSecret,
+				StateNamespace: atlantisNamespace,
+				StateName:      "webhook-state",
+			}
+			kube := newTestKube(tt.tunnelURL)
+			if tt.stateURL != "" {
+				store, err := newStateStore(req, kube)
+				if err != nil {
+					t.Fatalf("newStateStore() error = %v", err)
+				}
+				err = store.Put(state.Key(req.Provider, req.Owner, req.Repository), state.State{URL: tt.stateURL})
+				if err != nil {
+					t.Fatalf("Put() error = %v", err)
+				}
+			}
+
+			got, err := registeredURL(req, kube)
+			if err != nil {
+				t.Fatalf("registeredURL() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("registeredURL() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
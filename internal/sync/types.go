@@ -1,5 +1,14 @@
 package sync
 
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/kubefirst/git-helper/internal/hooks"
+)
+
 // WebhookOptions holds generic webhook modification parameters
 type WebhookOptions struct {
 	Provider            string
@@ -13,8 +22,178 @@ type WebhookOptions struct {
 	OldUrl              string
 	Token               string
 	Cleanup             bool
+	Strict              bool
+	Hook                hooks.Options
+	GitLabRecursive     bool
 	KubeInClusterConfig bool
+	Kubeconfig          string
+	KubeContext         string
 	Restart             bool
+	RestartTimeout      time.Duration
+	NgrokDeployment     string
+
+	// Public url discovery
+	URLSource       string
+	SourceName      string
+	SourceNamespace string
+	Watch           bool
+	WatchInterval   time.Duration
+
+	// State store
+	StateBackend   string
+	StateNamespace string
+	StateName      string
+	StatePath      string
+	HistoryLimit   int
+
+	// Events and sync status
+	RecordEvents    bool
+	EventObjectKind string
+	EventObjectName string
+
+	// Locking and leader election
+	Lock             bool
+	LockName         string
+	LockNamespace    string
+	LockTimeout      time.Duration
+	LeaderElect      bool
+	LeaderElectionID string
+
+	// ngrok tunnel selection
+	NgrokAPIURL string
+	TunnelName  string
+	TunnelProto string
+	TunnelAddr  string
+	Wait        bool
+	WaitTimeout time.Duration
+
+	// Verify pings the new hook after a sync and fails unless the endpoint
+	// accepts the ping within VerifyTimeout
+	Verify        bool
+	VerifyTimeout time.Duration
+
+	// Preflight checks that PreflightPath answers PreflightStatus through the
+	// new public url before the existing hook is replaced
+	Preflight        bool
+	PreflightPath    string
+	PreflightStatus  int
+	PreflightTimeout time.Duration
+
+	// Transport carries GitHub and GitLab api requests, such as a cassette
+	// recorder or replayer, http.DefaultTransport if nil
+	Transport http.RoundTripper
+}
+
+// RepositorySelector selects the repositories a command operates on
+// Explicit repositories and those read from File are combined with the
+// repositories listed by AllRepositories, Topic, Team or Subgroup, then
+// filtered by Match
+type RepositorySelector struct {
+	Repositories []string
+	// File lists one repository per line, blank lines and # comments are ignored
+	File            string
+	AllRepositories bool
+	// Topic selects repositories tagged with a GitHub or GitLab topic
+	Topic string
+	// Team selects the repositories of a GitHub team
+	Team string
+	// Subgroup selects the projects of a GitLab subgroup of the owner
+	Subgroup string
+	// Match is a regular expression repository names must match
+	Match string
+}
+
+// BulkOptions selects the repositories and operation of a bulk run
+type BulkOptions struct {
+	RepositorySelector
+	// Operation is one of create, update, delete or sync
+	Operation string
+	// Concurrency bounds the number of repositories processed at once
+	Concurrency int
+}
+
+// BulkResult describes the outcome of a bulk operation on one repository
+type BulkResult struct {
+	Repository string
+	// Action is created, updated, deleted or unchanged
+	Action  string
+	HookIDs []int64
+	Err     error
+}
+
+// GCOptions selects the repositories and hooks considered by webhook garbage
+// collection
+type GCOptions struct {
+	RepositorySelector
+	// Host is a glob matched against the hook url host, e.g. *.ngrok.io
+	Host string
+	// Match is a regular expression matched against the full hook url
+	Match string
+	// KeepURLs are never treated as orphaned
+	KeepURLs []string
+	// FromState keeps the url registered in the state store for each repository
+	FromState bool
+}
+
+// DedupeOptions selects the repositories and hooks considered when collapsing
+// duplicate webhooks
+type DedupeOptions struct {
+	RepositorySelector
+	// URL limits deduplication to hooks delivering to this url
+	URL string
+	// FromState keeps the hook ID registered in the state store when it is
+	// one of the duplicates
+	FromState bool
+}
+
+// RepositoryWebhook describes a hook registered on a repository or project
+type RepositoryWebhook struct {
+	Repository string
+	HookID     int64
+	URL        string
+}
+
+// DuplicateWebhook describes a hook that delivers to the same url as the
+// canonical hook kept for its repository
+type DuplicateWebhook struct {
+	RepositoryWebhook
+	KeptHookID int64
+}
+
+// DeliveriesOptions selects the hook whose deliveries are inspected
+// Without HookID or URL, the repository must have a single hook
+type DeliveriesOptions struct {
+	HookID int64
+	URL    string
+	// Limit is the number of most recent deliveries considered
+	Limit int
+	// FailedOnly hides successful deliveries
+	FailedOnly bool
+}
+
+// RedeliverOptions selects the failed deliveries sent again
+type RedeliverOptions struct {
+	DeliveriesOptions
+	// Since limits redelivery to deliveries made within this duration
+	Since time.Duration
+}
+
+// Delivery is an attempt to deliver an event to a webhook
+type Delivery struct {
+	ID int64
+	// GUID identifies the event across redeliveries
+	GUID        string
+	Event       string
+	StatusCode  int
+	Status      string
+	DeliveredAt time.Time
+	Duration    time.Duration
+	Redelivery  bool
+}
+
+// Failed reports whether the receiver did not accept the delivery
+func (d Delivery) Failed() bool {
+	return d.StatusCode < 200 || d.StatusCode >= 300
 }
 
 // NgrokTunnelResponse describes the response from the ngrok api
@@ -32,3 +211,35 @@ type NgrokTunnelDefinition struct {
 	Config     map[string]interface{} `json:"config"`
 	Metrics    map[string]interface{} `json:"metrics"`
 }
+
+// Addr returns the upstream address the tunnel forwards to
+func (t NgrokTunnelDefinition) Addr() string {
+	addr, _ := t.Config["addr"].(string)
+	return addr
+}
+
+// NgrokTunnelSelector describes which tunnel to pick from the ngrok api
+// Empty fields match any tunnel
+type NgrokTunnelSelector struct {
+	Name  string
+	Proto string
+	Addr  string
+}
+
+// String returns a readable description of the selector for use in errors
+func (s NgrokTunnelSelector) String() string {
+	var parts []string
+	if s.Name != "" {
+		parts = append(parts, fmt.Sprintf("name=%s", s.Name))
+	}
+	if s.Proto != "" {
+		parts = append(parts, fmt.Sprintf("proto=%s", s.Proto))
+	}
+	if s.Addr != "" {
+		parts = append(parts, fmt.Sprintf("addr=%s", s.Addr))
+	}
+	if len(parts) == 0 {
+		return "[any]"
+	}
+	return fmt.Sprintf("[%s]", strings.Join(parts, " "))
+}
@@ -0,0 +1,69 @@
+package sync
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// verifyPollInterval is how often deliveries are listed while waiting
+	// for a ping to be delivered
+	verifyPollInterval time.Duration = 2 * time.Second
+
+	// verifyDeliveryLimit is the number of recent deliveries searched for
+	// the ping
+	verifyDeliveryLimit int = 30
+)
+
+// errVerifyInconclusive is returned when the provider could not send a test
+// event, so the hook was never called
+var errVerifyInconclusive error = errors.New("test event could not be sent")
+
+// verifyWebhook pings a hook and fails unless the endpoint answers with a
+// 2xx status code within timeout
+// An error wrapping errVerifyInconclusive means the endpoint was not tested
+func verifyWebhook(provider webhookProvider, hookID int64, timeout time.Duration) error {
+	delivery, err := provider.PingWebhook(hookID, timeout)
+	if errors.Is(err, errVerifyInconclusive) {
+		return fmt.Errorf("could not verify webhook %d: %w", hookID, err)
+	}
+	if err != nil {
+		return fmt.Errorf("error verifying webhook %d: %s", hookID, err)
+	}
+	if delivery.Failed() {
+		return fmt.Errorf("webhook %d did not accept the %s event, endpoint answered %d: %s", hookID, delivery.Event, delivery.StatusCode, delivery.Status)
+	}
+	log.Infof("webhook %d accepted the %s event with status %d", hookID, delivery.Event, delivery.StatusCode)
+
+	return nil
+}
+
+// waitForDelivery polls the deliveries of a hook until one for event shows up
+// that is not among previous, or the timeout is reached
+func waitForDelivery(provider webhookProvider, hookID int64, event string, previous []Delivery, timeout time.Duration) (Delivery, error) {
+	seen := make(map[int64]bool, len(previous))
+	for _, delivery := range previous {
+		seen[delivery.ID] = true
+	}
+
+	deadline := time.Now().Add(timeout)
+	for {
+		deliveries, err := provider.ListDeliveries(hookID, verifyDeliveryLimit)
+		if err != nil {
+			return Delivery{}, err
+		}
+		for _, delivery := range deliveries {
+			if delivery.Event == event && !seen[delivery.ID] {
+				return delivery, nil
+			}
+		}
+		if time.Now().Add(verifyPollInterval).After(deadline) {
+			return Delivery{}, fmt.Errorf("timed out after %s waiting for the %s delivery", timeout, event)
+		}
+		log.Infof("waiting for the %s delivery of webhook %d", event, hookID)
+		time.Sleep(verifyPollInterval)
+	}
+}
@@ -0,0 +1,53 @@
+package sync
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestVerifyWebhook(t *testing.T) {
+	tests := []struct {
+		name             string
+		ping             Delivery
+		pingErr          error
+		wantErr          bool
+		wantInconclusive bool
+	}{
+		{
+			name: "If the endpoint accepts the test event, should succeed",
+			ping: Delivery{Event: "ping", StatusCode: 204},
+		},
+		{
+			name:    "If the endpoint rejects the test event, should fail",
+			ping:    Delivery{Event: "ping", StatusCode: 502},
+			wantErr: true,
+		},
+		{
+			name:    "If the ping fails, should fail",
+			pingErr: errors.New("hook api unavailable"),
+			wantErr: true,
+		},
+		{
+			name:             "If the test event could not be sent, should be inconclusive",
+			pingErr:          fmt.Errorf("%w: Ensure the project has at least one commit.", errVerifyInconclusive),
+			wantErr:          true,
+			wantInconclusive: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &fakeClient{hooks: map[string][]webhook{"gitops": {}}, ping: tt.ping, pingErr: tt.pingErr}
+
+			err := verifyWebhook(client.Repository("gitops"), 1, time.Second)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("verifyWebhook() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if errors.Is(err, errVerifyInconclusive) != tt.wantInconclusive {
+				t.Errorf("verifyWebhook() error = %v, want inconclusive %v", err, tt.wantInconclusive)
+			}
+		})
+	}
+}